@@ -1,8 +1,10 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/escoutdoor/social/internal/cache"
 	"github.com/escoutdoor/social/internal/config"
@@ -11,7 +13,9 @@ import (
 	"github.com/escoutdoor/social/internal/repository/postgres"
 	"github.com/escoutdoor/social/internal/s3"
 	"github.com/escoutdoor/social/internal/service"
+	"github.com/escoutdoor/social/pkg/jwtkeys"
 	"github.com/escoutdoor/social/pkg/logger"
+	"github.com/escoutdoor/social/pkg/oidc"
 	"github.com/escoutdoor/social/pkg/validator"
 )
 
@@ -31,13 +35,14 @@ func Run() error {
 	repo := repository.New(db)
 
 	s3, err := s3.New(s3.Opts{
-		MinIOBucketName: cfg.MinIOBucketName,
-		MinIOEndpoint:   cfg.MinIOEndpoint,
-		MinIOHost:       cfg.MinIOHost,
-		MinIOUser:       cfg.MinIOUser,
-		MinIOPw:         cfg.MinIOPw,
-		MinIOUseSSL:     cfg.MinIOUseSSL,
-		MinIORegion:     cfg.MinIORegion,
+		MinIOBucketName:       cfg.MinIOBucketName,
+		MinIOExportBucketName: cfg.MinIOExportBucketName,
+		MinIOEndpoint:         cfg.MinIOEndpoint,
+		MinIOHost:             cfg.MinIOHost,
+		MinIOUser:             cfg.MinIOUser,
+		MinIOPw:               cfg.MinIOPw,
+		MinIOUseSSL:           cfg.MinIOUseSSL,
+		MinIORegion:           cfg.MinIORegion,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to connect to s3: %w", err)
@@ -50,16 +55,112 @@ func Run() error {
 	}
 	slog.Info("successfully connected to redis")
 
-	validator := validator.New()
+	keys, err := loadSigningKeys(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load jwt signing keys: %w", err)
+	}
+
+	validator := validator.New(validator.WithMaxPageLimit(cfg.PageMaxLimit))
+
+	var mailer service.Mailer = service.NewLogMailer()
+	if cfg.SMTPHost != "" {
+		mailer = service.NewSMTPMailer(service.SMTPOpts{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			User:     cfg.SMTPUser,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+	}
+
+	guardOpts := service.DefaultLoginGuardOpts
+	guardOpts.MaxAccountFailures = cfg.LoginMaxAccountFailures
+	guardOpts.MaxIPFailures = cfg.LoginMaxIPFailures
+	guardOpts.LockoutDuration = cfg.LoginLockoutDuration
+
+	feedOpts := service.FeedOpts{
+		Mode:         service.FeedMode(cfg.FeedMode),
+		TimelineSize: cfg.FeedTimelineSize,
+		TimelineTTL:  cfg.FeedTimelineTTL,
+	}
+	if !feedOpts.Mode.Valid() {
+		return fmt.Errorf("invalid feed mode %q", cfg.FeedMode)
+	}
+
+	providers := make(map[string]*oidc.Provider, len(cfg.OIDCProviders))
+	for _, p := range cfg.OIDCProviders {
+		scopes := p.Scopes
+		if len(scopes) == 0 {
+			scopes = []string{"email", "profile"}
+		}
+		providers[p.Name] = oidc.NewProvider(oidc.Config{
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  fmt.Sprintf("%s/oauth/%s/callback", cfg.AppURL, p.Name),
+			Scopes:       scopes,
+		}, nil)
+	}
+
+	usernameOpts := service.UsernameOpts{
+		ChangeCooldown: cfg.UsernameChangeCooldown,
+		RedirectTTL:    cfg.UsernameRedirectTTL,
+	}
+
+	exportOpts := service.ExportOpts{
+		LinkTTL: cfg.ExportLinkTTL,
+		Timeout: cfg.ExportTimeout,
+	}
+	if exportOpts.LinkTTL <= 0 || exportOpts.LinkTTL > time.Hour*24*7 {
+		return fmt.Errorf("invalid export link ttl %s, it must be positive and at most 7 days", cfg.ExportLinkTTL)
+	}
+
+	purgeOpts := service.DefaultPurgeOpts
+	purgeOpts.Grace = cfg.AccountDeletionGrace
+	purgeOpts.Interval = cfg.AccountPurgeInterval
+
+	uploadOpts := service.UploadOpts{
+		MaxSizes:    make(map[string]int64, len(cfg.UploadMaxSizesMB)),
+		Renditions:  cfg.ImageRenditions,
+		JPEGQuality: cfg.ImageJPEGQuality,
+	}
+	for contentType, mb := range cfg.UploadMaxSizesMB {
+		if _, ok := service.UploadTypes[contentType]; !ok || mb <= 0 {
+			return fmt.Errorf("invalid upload limit %s:%d", contentType, mb)
+		}
+		uploadOpts.MaxSizes[contentType] = mb << 20
+	}
+	for name, size := range cfg.ImageRenditions {
+		if size <= 0 {
+			return fmt.Errorf("invalid image rendition %s:%d", name, size)
+		}
+	}
+	if cfg.ImageJPEGQuality < 1 || cfg.ImageJPEGQuality > 100 {
+		return fmt.Errorf("invalid image jpeg quality %d, it must be from 1 to 100", cfg.ImageJPEGQuality)
+	}
 
 	services := service.NewServices(service.Opts{
 		Repository: repo,
 		Cache:      cache,
 		S3:         s3,
 		Validator:  validator,
-		SignKey:    cfg.SignKey,
+		Mailer:     mailer,
+		Keys:       keys,
+		AppURL:     cfg.AppURL,
+		TOTPIssuer: cfg.TOTPIssuer,
+		LoginGuard: guardOpts,
+		Feed:       feedOpts,
+		Username:   usernameOpts,
+		Export:     exportOpts,
+		Purge:      purgeOpts,
+		Upload:     uploadOpts,
+		Providers:  providers,
 	})
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go services.Purger.Run(ctx)
+
 	slog.Info("server is running", slog.Int("port", cfg.Port))
 	s := httpserver.New(httpserver.Opts{
 		Config:    cfg,
@@ -72,3 +173,22 @@ func Run() error {
 	slog.Info("shutting down..")
 	return nil
 }
+
+// loadSigningKeys builds the key set from the PEM files in JWT_KEYS_DIR. Every
+// key except JWT_ACTIVE_KID is retired and only verifies tokens. The legacy
+// JWT_SIGN_KEY secret is kept without a kid so tokens issued before keys were
+// rotated stay valid.
+func loadSigningKeys(cfg *config.Config) (*jwtkeys.KeySet, error) {
+	var keys []*jwtkeys.Key
+	if cfg.JWTKeysDir != "" {
+		loaded, err := jwtkeys.LoadDir(cfg.JWTKeysDir)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, loaded...)
+	}
+	if cfg.SignKey != "" {
+		keys = append(keys, jwtkeys.NewHMACKey("", []byte(cfg.SignKey)))
+	}
+	return jwtkeys.New(cfg.JWTActiveID, keys...)
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -20,10 +21,26 @@ type Cache struct {
 }
 
 type Repository interface {
-	GetPost(ctx context.Context, key string) (*types.Post, error)
+	GetPost(ctx context.Context, key string, audiences ...string) (*types.Post, error)
+	SetPost(ctx context.Context, key string, audience string, post types.Post, expiration time.Duration) error
 	GetPosts(ctx context.Context, key string) ([]types.Post, error)
 	SetPosts(ctx context.Context, key string, posts []types.Post, expiration time.Duration) error
 
+	IncrFailures(ctx context.Context, kind types.LockoutKind, subject string, window time.Duration) (int64, error)
+	ResetFailures(ctx context.Context, kind types.LockoutKind, subject string) error
+	IncrRequests(ctx context.Context, kind types.LockoutKind, subject string, window time.Duration) (int64, time.Duration, error)
+	SetBackoff(ctx context.Context, kind types.LockoutKind, subject string, d time.Duration) error
+	GetBackoff(ctx context.Context, kind types.LockoutKind, subject string) (time.Duration, error)
+	SetLockout(ctx context.Context, lockout types.Lockout) error
+	GetLockout(ctx context.Context, kind types.LockoutKind, subject string) (*types.Lockout, error)
+	GetLockouts(ctx context.Context) ([]types.Lockout, error)
+	DeleteLockout(ctx context.Context, kind types.LockoutKind, subject string) error
+
+	SetTimeline(ctx context.Context, userID uuid.UUID, entries []types.TimelineEntry, ttl time.Duration) error
+	PushToTimelines(ctx context.Context, userIDs []uuid.UUID, entries []types.TimelineEntry, maxSize int) error
+	RemoveFromTimelines(ctx context.Context, userIDs []uuid.UUID, postIDs []uuid.UUID) error
+	GetTimeline(ctx context.Context, userID uuid.UUID, before *time.Time, offset, count int, ttl time.Duration) ([]types.TimelineEntry, error)
+
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
 	Del(ctx context.Context, keys ...string) *redis.IntCmd
 }
@@ -42,16 +59,37 @@ func New(redisURL string) (*Cache, error) {
 	return &Cache{Client: client}, nil
 }
 
-func (c *Cache) GetPost(ctx context.Context, key string) (*types.Post, error) {
-	var post types.Post
-	val, err := c.Get(ctx, key).Result()
+// GetPost returns the copy of a post cached for the first of the audiences
+// that has one, or redis.Nil. Deleting the key drops every copy.
+func (c *Cache) GetPost(ctx context.Context, key string, audiences ...string) (*types.Post, error) {
+	vals, err := c.HMGet(ctx, key, audiences...).Result()
 	if err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal([]byte(val), &post); err != nil {
-		return nil, fmt.Errorf("%s: %w", ErrUnmarshalFailed, err)
+	for _, val := range vals {
+		s, ok := val.(string)
+		if !ok {
+			continue
+		}
+		var post types.Post
+		if err := json.Unmarshal([]byte(s), &post); err != nil {
+			return nil, fmt.Errorf("%s: %w", ErrUnmarshalFailed, err)
+		}
+		return &post, nil
 	}
-	return &post, nil
+	return nil, redis.Nil
+}
+
+// SetPost caches a copy of the post for the audience. The expiration applies
+// to every copy of the post.
+func (c *Cache) SetPost(ctx context.Context, key string, audience string, post types.Post, expiration time.Duration) error {
+	pipe := c.TxPipeline()
+	pipe.HSet(ctx, key, audience, post)
+	pipe.Expire(ctx, key, expiration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to set cache: %w", err)
+	}
+	return nil
 }
 
 func (c *Cache) GetPosts(ctx context.Context, key string) ([]types.Post, error) {
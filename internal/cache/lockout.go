@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	failuresKeyPrefix = "login:failures"
+	backoffKeyPrefix  = "login:backoff"
+	lockoutKeyPrefix  = "login:lockout"
+	requestsKeyPrefix = "login:requests"
+)
+
+func loginKey(prefix string, kind types.LockoutKind, subject string) string {
+	return fmt.Sprintf("%s:%s:%s", prefix, kind, subject)
+}
+
+// IncrFailures counts a failed sign in attempt. The counter expires after
+// window without new failures.
+func (c *Cache) IncrFailures(ctx context.Context, kind types.LockoutKind, subject string, window time.Duration) (int64, error) {
+	key := loginKey(failuresKeyPrefix, kind, subject)
+
+	pipe := c.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to count failed attempt: %w", err)
+	}
+	return incr.Val(), nil
+}
+
+// IncrRequests counts a request that is limited regardless of its outcome,
+// such as asking for a sign in link. The counter expires window after the
+// first request. It returns the count and how long until it expires.
+func (c *Cache) IncrRequests(ctx context.Context, kind types.LockoutKind, subject string, window time.Duration) (int64, time.Duration, error) {
+	key := loginKey(requestsKeyPrefix, kind, subject)
+
+	// the counter starts with the window, later requests leave it be
+	pipe := c.TxPipeline()
+	pipe.SetNX(ctx, key, 0, window)
+	incr := pipe.Incr(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, fmt.Errorf("failed to count request: %w", err)
+	}
+	return incr.Val(), ttl.Val(), nil
+}
+
+func (c *Cache) ResetFailures(ctx context.Context, kind types.LockoutKind, subject string) error {
+	return c.Del(ctx,
+		loginKey(failuresKeyPrefix, kind, subject),
+		loginKey(backoffKeyPrefix, kind, subject),
+	).Err()
+}
+
+func (c *Cache) SetBackoff(ctx context.Context, kind types.LockoutKind, subject string, d time.Duration) error {
+	return c.Set(ctx, loginKey(backoffKeyPrefix, kind, subject), 1, d).Err()
+}
+
+// GetBackoff returns how long the subject has to wait before the next
+// attempt, or zero when it may try right away.
+func (c *Cache) GetBackoff(ctx context.Context, kind types.LockoutKind, subject string) (time.Duration, error) {
+	ttl, err := c.PTTL(ctx, loginKey(backoffKeyPrefix, kind, subject)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (c *Cache) SetLockout(ctx context.Context, lockout types.Lockout) error {
+	data, err := json.Marshal(lockout)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lockout: %w", err)
+	}
+
+	key := loginKey(lockoutKeyPrefix, lockout.Kind, lockout.Subject)
+	if err := c.Set(ctx, key, data, time.Until(lockout.ExpiresAt)).Err(); err != nil {
+		return fmt.Errorf("failed to set cache: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) GetLockout(ctx context.Context, kind types.LockoutKind, subject string) (*types.Lockout, error) {
+	val, err := c.Get(ctx, loginKey(lockoutKeyPrefix, kind, subject)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var lockout types.Lockout
+	if err := json.Unmarshal([]byte(val), &lockout); err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrUnmarshalFailed, err)
+	}
+	return &lockout, nil
+}
+
+// GetLockouts returns every active lockout.
+func (c *Cache) GetLockouts(ctx context.Context) ([]types.Lockout, error) {
+	lockouts := []types.Lockout{}
+	iter := c.Scan(ctx, 0, lockoutKeyPrefix+":*", 100).Iterator()
+	for iter.Next(ctx) {
+		val, err := c.Get(ctx, iter.Val()).Result()
+		if err != nil {
+			// The lockout may have expired since the key was scanned.
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			return nil, err
+		}
+
+		var lockout types.Lockout
+		if err := json.Unmarshal([]byte(val), &lockout); err != nil {
+			return nil, fmt.Errorf("%s: %w", ErrUnmarshalFailed, err)
+		}
+		lockouts = append(lockouts, lockout)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return lockouts, nil
+}
+
+// DeleteLockout lifts the lockout and forgets the failed attempts. It
+// returns redis.Nil when there was no such lockout.
+func (c *Cache) DeleteLockout(ctx context.Context, kind types.LockoutKind, subject string) error {
+	deleted, err := c.Del(ctx, loginKey(lockoutKeyPrefix, kind, subject)).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return redis.Nil
+	}
+	return c.ResetFailures(ctx, kind, subject)
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const timelineKeyPrefix = "timeline"
+
+func timelineKey(userID uuid.UUID) string {
+	return fmt.Sprintf("%s:%s", timelineKeyPrefix, userID)
+}
+
+func timelineScore(t time.Time) float64 {
+	return float64(t.UnixMicro())
+}
+
+// pushScript adds entries to a timeline only when it exists. A missing
+// timeline is rebuilt from the database on the next read, and adding a single
+// post to it would hide everything older.
+var pushScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+for i = 2, #ARGV, 2 do
+	redis.call("ZADD", KEYS[1], ARGV[i], ARGV[i + 1])
+end
+redis.call("ZREMRANGEBYRANK", KEYS[1], 0, -tonumber(ARGV[1]) - 1)
+return 1
+`)
+
+// SetTimeline replaces the timeline of the user.
+func (c *Cache) SetTimeline(ctx context.Context, userID uuid.UUID, entries []types.TimelineEntry, ttl time.Duration) error {
+	key := timelineKey(userID)
+
+	pipe := c.TxPipeline()
+	pipe.Del(ctx, key)
+	if len(entries) > 0 {
+		pipe.ZAdd(ctx, key, timelineMembers(entries)...)
+		pipe.Expire(ctx, key, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to set timeline: %w", err)
+	}
+	return nil
+}
+
+// PushToTimelines adds the entries to the existing timelines of the users and
+// trims each of them to maxSize entries.
+func (c *Cache) PushToTimelines(ctx context.Context, userIDs []uuid.UUID, entries []types.TimelineEntry, maxSize int) error {
+	if len(userIDs) == 0 || len(entries) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, 1+len(entries)*2)
+	args = append(args, maxSize)
+	for _, e := range entries {
+		args = append(args, timelineScore(e.CreatedAt), e.PostID.String())
+	}
+
+	pipe := c.Pipeline()
+	for _, id := range userIDs {
+		pushScript.Run(ctx, pipe, []string{timelineKey(id)}, args...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to push to timelines: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) RemoveFromTimelines(ctx context.Context, userIDs []uuid.UUID, postIDs []uuid.UUID) error {
+	if len(userIDs) == 0 || len(postIDs) == 0 {
+		return nil
+	}
+
+	members := make([]interface{}, len(postIDs))
+	for i, id := range postIDs {
+		members[i] = id.String()
+	}
+
+	pipe := c.Pipeline()
+	for _, id := range userIDs {
+		pipe.ZRem(ctx, timelineKey(id), members...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to remove from timelines: %w", err)
+	}
+	return nil
+}
+
+// GetTimeline returns up to count entries, newest first, created at or
+// before the given time, skipping the first offset ones. A nil before starts
+// at the newest entry. It returns redis.Nil when the user has no timeline
+// and refreshes its expiry otherwise.
+func (c *Cache) GetTimeline(ctx context.Context, userID uuid.UUID, before *time.Time, offset, count int, ttl time.Duration) ([]types.TimelineEntry, error) {
+	key := timelineKey(userID)
+	max := "+inf"
+	if before != nil {
+		max = strconv.FormatFloat(timelineScore(*before), 'f', -1, 64)
+	}
+
+	pipe := c.Pipeline()
+	exists := pipe.Expire(ctx, key, ttl)
+	zs := pipe.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+		Max:    max,
+		Min:    "-inf",
+		Offset: int64(offset),
+		Count:  int64(count),
+	})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	if !exists.Val() {
+		return nil, redis.Nil
+	}
+
+	entries := make([]types.TimelineEntry, 0, len(zs.Val()))
+	for _, z := range zs.Val() {
+		id, err := uuid.Parse(z.Member.(string))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeline entry %v: %w", z.Member, err)
+		}
+		entries = append(entries, types.TimelineEntry{
+			PostID:    id,
+			CreatedAt: time.UnixMicro(int64(z.Score)).UTC(),
+		})
+	}
+	return entries, nil
+}
+
+func timelineMembers(entries []types.TimelineEntry) []redis.Z {
+	members := make([]redis.Z, len(entries))
+	for i, e := range entries {
+		members[i] = redis.Z{Score: timelineScore(e.CreatedAt), Member: e.PostID.String()}
+	}
+	return members
+}
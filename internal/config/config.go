@@ -1,6 +1,9 @@
 package config
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/kelseyhightower/envconfig"
 )
 
@@ -9,15 +12,91 @@ type Config struct {
 	Port        int    `envconfig:"PORT" default:"8080"`
 	PostgresURL string `envconfig:"POSTGRES_URL" required:"true"`
 	RedisURL    string `envconfig:"REDIS_URL" required:"true"`
-	SignKey     string `envconfig:"JWT_SIGN_KEY" required:"true"`
+	// SignKey is the legacy HS256 secret. It signs tokens when no JWTKeysDir
+	// is configured, otherwise it only verifies tokens issued without a kid.
+	SignKey     string `envconfig:"JWT_SIGN_KEY"`
+	JWTKeysDir  string `envconfig:"JWT_KEYS_DIR"`
+	JWTActiveID string `envconfig:"JWT_ACTIVE_KID"`
+	AppURL      string `envconfig:"APP_URL" default:"http://localhost:3000"`
+	TOTPIssuer  string `envconfig:"TOTP_ISSUER" default:"Social"`
+
+	// RequireVerifiedEmail forbids users with an unconfirmed email from
+	// publishing posts.
+	RequireVerifiedEmail bool `envconfig:"REQUIRE_VERIFIED_EMAIL" default:"false"`
+
+	// Sign in attempts allowed before an account or a client IP is locked
+	// out for LoginLockoutDuration.
+	LoginMaxAccountFailures int64         `envconfig:"LOGIN_MAX_ACCOUNT_FAILURES" default:"10"`
+	LoginMaxIPFailures      int64         `envconfig:"LOGIN_MAX_IP_FAILURES" default:"100"`
+	LoginLockoutDuration    time.Duration `envconfig:"LOGIN_LOCKOUT_DURATION" default:"15m"`
+
+	// FeedMode is "read" to build home timelines with a join on every
+	// request or "write" to push posts into per-user Redis timelines.
+	FeedMode         string        `envconfig:"FEED_MODE" default:"read"`
+	FeedTimelineSize int           `envconfig:"FEED_TIMELINE_SIZE" default:"800"`
+	FeedTimelineTTL  time.Duration `envconfig:"FEED_TIMELINE_TTL" default:"168h"`
+
+	// A user may rename themselves once per UsernameChangeCooldown. The old
+	// username redirects to the new one for UsernameRedirectTTL.
+	UsernameChangeCooldown time.Duration `envconfig:"USERNAME_CHANGE_COOLDOWN" default:"720h"`
+	UsernameRedirectTTL    time.Duration `envconfig:"USERNAME_REDIRECT_TTL" default:"336h"`
+
+	// A deleted account can be restored by signing in for AccountDeletionGrace.
+	// The purge job removes it for good afterwards and runs every
+	// AccountPurgeInterval.
+	AccountDeletionGrace time.Duration `envconfig:"ACCOUNT_DELETION_GRACE" default:"720h"`
+	AccountPurgeInterval time.Duration `envconfig:"ACCOUNT_PURGE_INTERVAL" default:"1h"`
+
+	// ExportLinkTTL is how long the download link of a data export works, at
+	// most seven days. ExportTimeout bounds the time to build one.
+	ExportLinkTTL time.Duration `envconfig:"EXPORT_LINK_TTL" default:"48h"`
+	ExportTimeout time.Duration `envconfig:"EXPORT_TIMEOUT" default:"30m"`
+
+	// UploadMaxSizesMB lists the content types that can be uploaded, each
+	// with the largest size of such a file in megabytes.
+	UploadMaxSizesMB map[string]int64 `envconfig:"UPLOAD_MAX_SIZES_MB" default:"image/jpeg:10,image/png:10,image/webp:10,image/gif:10,video/mp4:100"`
+	// ImageRenditions names the scaled down copies made of uploaded images,
+	// each with the size in pixels of its longer side. They are encoded as
+	// JPEG of ImageJPEGQuality.
+	ImageRenditions  map[string]int `envconfig:"IMAGE_RENDITIONS" default:"avatar:128,small:640,large:1280"`
+	ImageJPEGQuality int            `envconfig:"IMAGE_JPEG_QUALITY" default:"85"`
+
+	// PageMaxLimit is the largest page size list endpoints accept.
+	PageMaxLimit int `envconfig:"PAGE_MAX_LIMIT" default:"100"`
 
 	MinIOHost       string `envconfig:"MINIO_HOST" required:"true"`
 	MinIOEndpoint   string `envconfig:"MINIO_SERVER_URL" required:"true"`
 	MinIOUser       string `envconfig:"MINIO_ROOT_USER" required:"true"`
 	MinIOPw         string `envconfig:"MINIO_ROOT_PASSWORD" required:"true"`
 	MinIOBucketName string `envconfig:"MINIO_BUCKET_NAME" required:"true"`
-	MinIOUseSSL     bool   `envconfig:"MINIO_USE_SSL" default:"false"`
-	MinIORegion     string `envconfig:"MINIO_REGION" default:"auto"`
+	// MinIOExportBucketName is a private bucket for data exports.
+	MinIOExportBucketName string `envconfig:"MINIO_EXPORT_BUCKET_NAME" default:"exports"`
+	MinIOUseSSL           bool   `envconfig:"MINIO_USE_SSL" default:"false"`
+	MinIORegion           string `envconfig:"MINIO_REGION" default:"auto"`
+
+	// OIDCProviders is a JSON array of OpenID Connect providers users can
+	// sign in with.
+	OIDCProviders OIDCProviders `envconfig:"OIDC_PROVIDERS"`
+
+	SMTPHost     string `envconfig:"SMTP_HOST"`
+	SMTPPort     int    `envconfig:"SMTP_PORT" default:"587"`
+	SMTPUser     string `envconfig:"SMTP_USER"`
+	SMTPPassword string `envconfig:"SMTP_PASSWORD"`
+	SMTPFrom     string `envconfig:"SMTP_FROM" default:"no-reply@social.local"`
+}
+
+type OIDCProvider struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+}
+
+type OIDCProviders []OIDCProvider
+
+func (p *OIDCProviders) Decode(value string) error {
+	return json.Unmarshal([]byte(value), p)
 }
 
 func New() (*Config, error) {
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/escoutdoor/social/internal/httpserver/middlewares"
+	"github.com/escoutdoor/social/internal/httpserver/responses"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/service"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/validator"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminHandler struct {
+	lockoutSvc    service.Lockout
+	moderationSvc service.Moderation
+	userSvc       service.User
+	validator     *validator.Validator
+}
+
+func NewAdminHandler(lockoutSvc service.Lockout, moderationSvc service.Moderation, userSvc service.User, v *validator.Validator) AdminHandler {
+	return AdminHandler{
+		lockoutSvc:    lockoutSvc,
+		moderationSvc: moderationSvc,
+		userSvc:       userSvc,
+		validator:     v,
+	}
+}
+
+// Router serves the moderation log to moderators and admins, everything else
+// is for admins only, as the permissions of their roles say.
+func (h *AdminHandler) Router() *chi.Mux {
+	r := chi.NewRouter()
+	r.With(middlewares.RequirePermission(service.PermViewModeration)).Get("/moderation-actions", h.handleGetModerationActions)
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.RequirePermission(service.PermManageLockouts))
+		r.Get("/lockouts", h.handleGetLockouts)
+		r.Delete("/lockouts/{kind}/{subject}", h.handleUnlock)
+	})
+	r.With(middlewares.RequirePermission(service.PermManageRoles)).Put("/users/{id}/role", h.handleUpdateRole)
+	return r
+}
+
+func (h *AdminHandler) handleGetModerationActions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actions, err := h.moderationSvc.GetActions(ctx)
+	if err != nil {
+		slog.Error("AdminHandler.handleGetModerationActions - ModerationService.GetActions", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"moderation_actions": actions})
+}
+
+func (h *AdminHandler) handleUpdateRole(w http.ResponseWriter, r *http.Request) {
+	admin, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	var input types.UpdateRoleReq
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		responses.BadRequestResponse(w, ErrInvalidRequestBody)
+		return
+	}
+	if err := h.validator.Validate(input); err != nil {
+		responses.FailedValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := h.userSvc.UpdateRole(ctx, id, input.Role, admin.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrCannotDemoteSelf):
+			responses.ForbiddenResponse(w, err)
+			return
+		case errors.Is(err, repoerrs.ErrUserNotFound):
+			responses.NotFoundResponse(w, err)
+			return
+		default:
+			slog.Error("AdminHandler.handleUpdateRole - UserService.UpdateRole", "error", err)
+			responses.InternalServerResponse(w, ErrInternalServer)
+			return
+		}
+	}
+	responses.JSON(w, http.StatusOK, envelope{"user": user})
+}
+
+func (h *AdminHandler) handleGetLockouts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	lockouts, err := h.lockoutSvc.GetLockouts(ctx)
+	if err != nil {
+		slog.Error("AdminHandler.handleGetLockouts - LoginGuard.GetLockouts", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"lockouts": lockouts})
+}
+
+func (h *AdminHandler) handleUnlock(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+
+	kind := types.LockoutKind(chi.URLParam(r, "kind"))
+	if kind != types.LockoutKindAccount && kind != types.LockoutKindIP {
+		responses.BadRequestResponse(w, ErrInvalidLockoutKind)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.lockoutSvc.Unlock(ctx, kind, chi.URLParam(r, "subject"), user.ID); err != nil {
+		if errors.Is(err, service.ErrLockoutNotFound) {
+			responses.NotFoundResponse(w, err)
+			return
+		}
+		slog.Error("AdminHandler.handleUnlock - LoginGuard.Unlock", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "lockout successfully lifted"})
+}
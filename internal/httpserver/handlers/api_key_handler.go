@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/escoutdoor/social/internal/httpserver/responses"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/service"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/validator"
+	"github.com/go-chi/chi/v5"
+)
+
+type APIKeyHandler struct {
+	svc       service.APIKey
+	validator *validator.Validator
+}
+
+func NewAPIKeyHandler(svc service.APIKey, v *validator.Validator) APIKeyHandler {
+	return APIKeyHandler{
+		svc:       svc,
+		validator: v,
+	}
+}
+
+func (h *APIKeyHandler) Router() *chi.Mux {
+	r := chi.NewRouter()
+	r.Post("/", h.handleCreate)
+	r.Get("/", h.handleGetAll)
+	r.Delete("/{id}", h.handleRevoke)
+	return r
+}
+
+func (h *APIKeyHandler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+
+	var input types.CreateAPIKeyReq
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		responses.BadRequestResponse(w, ErrInvalidRequestBody)
+		return
+	}
+
+	if err := h.validator.Validate(input); err != nil {
+		responses.FailedValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	key, err := h.svc.Create(ctx, user.ID, input)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidExpiry) {
+			responses.BadRequestResponse(w, err)
+			return
+		}
+		slog.Error("APIKeyHandler.handleCreate - APIKeyService.Create", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusCreated, envelope{"api_key": key})
+}
+
+func (h *APIKeyHandler) handleGetAll(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	keys, err := h.svc.GetAll(ctx, user.ID)
+	if err != nil {
+		slog.Error("APIKeyHandler.handleGetAll - APIKeyService.GetAll", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"api_keys": keys})
+}
+
+func (h *APIKeyHandler) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.svc.Revoke(ctx, user.ID, id); err != nil {
+		if errors.Is(err, repoerrs.ErrAPIKeyNotFound) {
+			responses.NotFoundResponse(w, err)
+			return
+		}
+		slog.Error("APIKeyHandler.handleRevoke - APIKeyService.Revoke", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "api key successfully revoked"})
+}
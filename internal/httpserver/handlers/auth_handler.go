@@ -26,10 +26,30 @@ func NewAuthHandler(svc service.Auth, v *validator.Validator) AuthHandler {
 	}
 }
 
-func (h *AuthHandler) Router() *chi.Mux {
+func (h *AuthHandler) Router(auth func(http.Handler) http.Handler) *chi.Mux {
 	r := chi.NewRouter()
 	r.Post("/sign-up", h.handleSignUp)
 	r.Post("/sign-in", h.handleSignIn)
+	r.Post("/refresh", h.handleRefresh)
+	r.Post("/logout", h.handleLogout)
+	r.Post("/password/forgot", h.handleForgotPassword)
+	r.Post("/password/reset", h.handleResetPassword)
+	r.Post("/magic-link", h.handleRequestMagicLink)
+	r.Get("/magic-link/verify", h.handleVerifyMagicLink)
+	r.Post("/email/verify", h.handleVerifyEmail)
+	r.Post("/2fa/verify", h.handleVerifyTwoFactor)
+	r.Post("/oidc/{provider}/start", h.handleStartOIDC)
+	r.Post("/oidc/{provider}/callback", h.handleFinishOIDC)
+	r.Group(func(r chi.Router) {
+		r.Use(auth)
+		r.Post("/email/resend", h.handleResendVerification)
+		r.Post("/2fa/setup", h.handleSetupTwoFactor)
+		r.Post("/2fa/confirm", h.handleConfirmTwoFactor)
+		r.Delete("/2fa", h.handleDisableTwoFactor)
+		r.Get("/sessions", h.handleGetSessions)
+		r.Delete("/sessions", h.handleRevokeOtherSessions)
+		r.Delete("/sessions/{id}", h.handleRevokeSession)
+	})
 	return r
 }
 
@@ -48,7 +68,7 @@ func (h *AuthHandler) handleSignUp(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id, err := h.svc.SignUp(ctx, input)
 	if err != nil {
-		if errors.Is(err, repoerrs.ErrUserAlreadyExists) {
+		if errors.Is(err, repoerrs.ErrUserAlreadyExists) || errors.Is(err, repoerrs.ErrUsernameTaken) {
 			responses.BadRequestResponse(w, err)
 			return
 		}
@@ -75,17 +95,492 @@ func (h *AuthHandler) handleSignIn(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	token, err := h.svc.SignIn(ctx, input)
+	meta := types.SessionMeta{
+		Device:    input.Device,
+		UserAgent: r.UserAgent(),
+		IP:        getClientIP(r),
+	}
+	result, err := h.svc.SignIn(ctx, input, meta)
 	if err != nil {
-		if errors.Is(err, service.ErrInvalidEmailOrPw) {
+		switch {
+		case errors.Is(err, service.ErrInvalidEmailOrPw):
 			responses.BadRequestResponse(w, err)
 			return
+		case errors.Is(err, service.ErrAccountDeleted):
+			responses.UnauthorizedResponse(w, err)
+			return
+		case writeRetryError(w, err):
+			return
 		}
 		slog.Error("AuthHandler.handleSignIn - AuthService.SignIn", "error", err)
 		responses.InternalServerResponse(w, ErrInternalServer)
 		return
 	}
+	responses.JSON(w, http.StatusOK, result)
+}
+
+func (h *AuthHandler) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	var input types.RefreshTokenReq
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		responses.BadRequestResponse(w, ErrInvalidRequestBody)
+		return
+	}
+
+	if err := h.validator.Validate(input); err != nil {
+		responses.FailedValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	tokens, err := h.svc.Refresh(ctx, input.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidToken),
+			errors.Is(err, service.ErrSessionRevoked),
+			errors.Is(err, service.ErrRefreshTokenReused):
+			responses.UnauthorizedResponse(w, err)
+			return
+		default:
+			slog.Error("AuthHandler.handleRefresh - AuthService.Refresh", "error", err)
+			responses.InternalServerResponse(w, ErrInternalServer)
+			return
+		}
+	}
+	responses.JSON(w, http.StatusOK, tokens)
+}
+
+func (h *AuthHandler) handleLogout(w http.ResponseWriter, r *http.Request) {
+	var input types.RefreshTokenReq
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		responses.BadRequestResponse(w, ErrInvalidRequestBody)
+		return
+	}
+
+	if err := h.validator.Validate(input); err != nil {
+		responses.FailedValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.svc.Logout(ctx, input.RefreshToken); err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			responses.UnauthorizedResponse(w, err)
+			return
+		}
+		slog.Error("AuthHandler.handleLogout - AuthService.Logout", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "successfully logged out"})
+}
+
+func (h *AuthHandler) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var input types.ForgotPasswordReq
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		responses.BadRequestResponse(w, ErrInvalidRequestBody)
+		return
+	}
+
+	if err := h.validator.Validate(input); err != nil {
+		responses.FailedValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.svc.ForgotPassword(ctx, input.Email); err != nil {
+		slog.Error("AuthHandler.handleForgotPassword - AuthService.ForgotPassword", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{
+		"message": "if an account with this email exists, a password reset link has been sent",
+	})
+}
+
+func (h *AuthHandler) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var input types.ResetPasswordReq
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		responses.BadRequestResponse(w, ErrInvalidRequestBody)
+		return
+	}
+
+	if err := h.validator.Validate(input); err != nil {
+		responses.FailedValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.svc.ResetPassword(ctx, input); err != nil {
+		if errors.Is(err, service.ErrInvalidToken) {
+			responses.BadRequestResponse(w, err)
+			return
+		}
+		slog.Error("AuthHandler.handleResetPassword - AuthService.ResetPassword", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "password successfully changed"})
+}
+
+func (h *AuthHandler) handleRequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	var input types.MagicLinkReq
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		responses.BadRequestResponse(w, ErrInvalidRequestBody)
+		return
+	}
+
+	if err := h.validator.Validate(input); err != nil {
+		responses.FailedValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.svc.RequestMagicLink(ctx, input.Email, getClientIP(r)); err != nil {
+		if writeRetryError(w, err) {
+			return
+		}
+		slog.Error("AuthHandler.handleRequestMagicLink - AuthService.RequestMagicLink", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
 	responses.JSON(w, http.StatusOK, envelope{
-		"token": token,
+		"message": "if an account with this email exists, a sign in link has been sent",
 	})
 }
+
+func (h *AuthHandler) handleVerifyMagicLink(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		responses.BadRequestResponse(w, ErrMissingToken)
+		return
+	}
+
+	ctx := r.Context()
+	meta := types.SessionMeta{
+		Device:    r.URL.Query().Get("device"),
+		UserAgent: r.UserAgent(),
+		IP:        getClientIP(r),
+	}
+	result, err := h.svc.SignInWithMagicLink(ctx, token, meta)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidToken) || errors.Is(err, service.ErrAccountDeleted) {
+			responses.UnauthorizedResponse(w, err)
+			return
+		}
+		slog.Error("AuthHandler.handleVerifyMagicLink - AuthService.SignInWithMagicLink", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, result)
+}
+
+func (h *AuthHandler) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var input types.VerifyEmailReq
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		responses.BadRequestResponse(w, ErrInvalidRequestBody)
+		return
+	}
+
+	if err := h.validator.Validate(input); err != nil {
+		responses.FailedValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.svc.VerifyEmail(ctx, input.Token); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidToken),
+			errors.Is(err, repoerrs.ErrEmailAlreadyExists):
+			responses.BadRequestResponse(w, err)
+			return
+		default:
+			slog.Error("AuthHandler.handleVerifyEmail - AuthService.VerifyEmail", "error", err)
+			responses.InternalServerResponse(w, ErrInternalServer)
+			return
+		}
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "email successfully verified"})
+}
+
+func (h *AuthHandler) handleResendVerification(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.svc.ResendVerification(ctx, *user); err != nil {
+		if errors.Is(err, service.ErrEmailAlreadyVerified) {
+			responses.BadRequestResponse(w, err)
+			return
+		}
+		slog.Error("AuthHandler.handleResendVerification - AuthService.ResendVerification", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "verification email sent"})
+}
+
+func (h *AuthHandler) handleGetSessions(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	sessionID, err := getSessionIDFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	sessions, err := h.svc.GetSessions(ctx, user.ID, sessionID)
+	if err != nil {
+		slog.Error("AuthHandler.handleGetSessions - AuthService.GetSessions", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"sessions": sessions})
+}
+
+func (h *AuthHandler) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	sessionID, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.svc.RevokeSession(ctx, user.ID, sessionID); err != nil {
+		if errors.Is(err, repoerrs.ErrSessionNotFound) {
+			responses.NotFoundResponse(w, err)
+			return
+		}
+		slog.Error("AuthHandler.handleRevokeSession - AuthService.RevokeSession", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "session successfully revoked"})
+}
+
+func (h *AuthHandler) handleRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	sessionID, err := getSessionIDFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.svc.RevokeOtherSessions(ctx, user.ID, sessionID); err != nil {
+		slog.Error("AuthHandler.handleRevokeOtherSessions - AuthService.RevokeOtherSessions", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "all other sessions successfully revoked"})
+}
+
+func (h *AuthHandler) handleSetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	setup, err := h.svc.SetupTwoFactor(ctx, *user)
+	if err != nil {
+		if errors.Is(err, service.ErrTwoFactorAlreadyEnabled) {
+			responses.BadRequestResponse(w, err)
+			return
+		}
+		slog.Error("AuthHandler.handleSetupTwoFactor - AuthService.SetupTwoFactor", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, setup)
+}
+
+func (h *AuthHandler) handleConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+
+	var input types.TwoFactorCodeReq
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		responses.BadRequestResponse(w, ErrInvalidRequestBody)
+		return
+	}
+
+	if err := h.validator.Validate(input); err != nil {
+		responses.FailedValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	codes, err := h.svc.ConfirmTwoFactor(ctx, user.ID, input.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTwoFactorAlreadyEnabled),
+			errors.Is(err, service.ErrInvalidTwoFactorCode),
+			errors.Is(err, repoerrs.ErrTwoFactorNotFound):
+			responses.BadRequestResponse(w, err)
+			return
+		default:
+			slog.Error("AuthHandler.handleConfirmTwoFactor - AuthService.ConfirmTwoFactor", "error", err)
+			responses.InternalServerResponse(w, ErrInternalServer)
+			return
+		}
+	}
+	responses.JSON(w, http.StatusOK, envelope{"recovery_codes": codes})
+}
+
+func (h *AuthHandler) handleDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+
+	var input types.TwoFactorCodeReq
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		responses.BadRequestResponse(w, ErrInvalidRequestBody)
+		return
+	}
+
+	if err := h.validator.Validate(input); err != nil {
+		responses.FailedValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.svc.DisableTwoFactor(ctx, user.ID, input.Code); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTwoFactorCode),
+			errors.Is(err, repoerrs.ErrTwoFactorNotFound):
+			responses.BadRequestResponse(w, err)
+			return
+		case writeRetryError(w, err):
+			return
+		default:
+			slog.Error("AuthHandler.handleDisableTwoFactor - AuthService.DisableTwoFactor", "error", err)
+			responses.InternalServerResponse(w, ErrInternalServer)
+			return
+		}
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "two-factor authentication disabled"})
+}
+
+func (h *AuthHandler) handleVerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var input types.TwoFactorVerifyReq
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		responses.BadRequestResponse(w, ErrInvalidRequestBody)
+		return
+	}
+
+	if err := h.validator.Validate(input); err != nil {
+		responses.FailedValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	meta := types.SessionMeta{
+		Device:    input.Device,
+		UserAgent: r.UserAgent(),
+		IP:        getClientIP(r),
+	}
+	tokens, err := h.svc.VerifyTwoFactor(ctx, input, meta)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidToken),
+			errors.Is(err, service.ErrInvalidTwoFactorCode):
+			responses.UnauthorizedResponse(w, err)
+			return
+		case writeRetryError(w, err):
+			return
+		default:
+			slog.Error("AuthHandler.handleVerifyTwoFactor - AuthService.VerifyTwoFactor", "error", err)
+			responses.InternalServerResponse(w, ErrInternalServer)
+			return
+		}
+	}
+	responses.JSON(w, http.StatusOK, tokens)
+}
+
+// HandleJWKS publishes the public keys access tokens are signed with. It is
+// mounted outside of /v1 at the well-known path.
+func (h *AuthHandler) HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	responses.JSON(w, http.StatusOK, h.svc.JWKS())
+}
+
+func (h *AuthHandler) handleStartOIDC(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	url, err := h.svc.StartOIDC(ctx, chi.URLParam(r, "provider"))
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownProvider) {
+			responses.NotFoundResponse(w, err)
+			return
+		}
+		slog.Error("AuthHandler.handleStartOIDC - AuthService.StartOIDC", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"url": url})
+}
+
+func (h *AuthHandler) handleFinishOIDC(w http.ResponseWriter, r *http.Request) {
+	var input types.OIDCCallbackReq
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		responses.BadRequestResponse(w, ErrInvalidRequestBody)
+		return
+	}
+
+	if err := h.validator.Validate(input); err != nil {
+		responses.FailedValidationError(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	meta := types.SessionMeta{
+		Device:    input.Device,
+		UserAgent: r.UserAgent(),
+		IP:        getClientIP(r),
+	}
+	result, err := h.svc.FinishOIDC(ctx, chi.URLParam(r, "provider"), input, meta)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnknownProvider):
+			responses.NotFoundResponse(w, err)
+			return
+		case errors.Is(err, service.ErrInvalidToken),
+			errors.Is(err, service.ErrOIDCLoginFailed):
+			responses.UnauthorizedResponse(w, service.ErrOIDCLoginFailed)
+			return
+		case errors.Is(err, service.ErrAccountDeleted):
+			responses.UnauthorizedResponse(w, err)
+			return
+		case errors.Is(err, service.ErrOIDCEmailMissing),
+			errors.Is(err, repoerrs.ErrEmailAlreadyExists):
+			responses.BadRequestResponse(w, err)
+			return
+		default:
+			slog.Error("AuthHandler.handleFinishOIDC - AuthService.FinishOIDC", "error", err)
+			responses.InternalServerResponse(w, ErrInternalServer)
+			return
+		}
+	}
+	responses.JSON(w, http.StatusOK, result)
+}
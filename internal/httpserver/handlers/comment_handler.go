@@ -67,6 +67,9 @@ func (h *CommentHandler) handleCreateComment(w http.ResponseWriter, r *http.Requ
 		case errors.Is(err, repoerrs.ErrCommentNotFound):
 			responses.NotFoundResponse(w, err)
 			return
+		case errors.Is(err, service.ErrBlocked):
+			responses.ForbiddenResponse(w, err)
+			return
 		default:
 			slog.Error("CommentHandler.handleCreateComment - CommentService.Create", "error", err)
 			responses.InternalServerResponse(w, ErrInternalServer)
@@ -77,6 +80,11 @@ func (h *CommentHandler) handleCreateComment(w http.ResponseWriter, r *http.Requ
 }
 
 func (h *CommentHandler) handleGetByID(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
 	id, err := getIDParam(r)
 	if err != nil {
 		responses.BadRequestResponse(w, err)
@@ -84,7 +92,7 @@ func (h *CommentHandler) handleGetByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	comment, err := h.svc.GetByID(ctx, id)
+	comment, err := h.svc.GetByID(ctx, id, user.ID)
 	if err != nil {
 		if errors.Is(err, repoerrs.ErrCommentNotFound) {
 			responses.NotFoundResponse(w, repoerrs.ErrCommentNotFound)
@@ -98,14 +106,24 @@ func (h *CommentHandler) handleGetByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *CommentHandler) handleGetAll(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
 	postID, err := getIDParam(r)
 	if err != nil {
 		responses.BadRequestResponse(w, err)
 		return
 	}
+	page, err := getCursorPage(r, h.validator)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
 
 	ctx := r.Context()
-	comments, err := h.svc.GetAll(ctx, postID)
+	comments, err := h.svc.GetAll(ctx, postID, user.ID, page)
 	if err != nil {
 		if errors.Is(err, repoerrs.ErrPostNotFound) {
 			responses.NotFoundResponse(w, err)
@@ -115,7 +133,7 @@ func (h *CommentHandler) handleGetAll(w http.ResponseWriter, r *http.Request) {
 		responses.InternalServerResponse(w, ErrInternalServer)
 		return
 	}
-	responses.JSON(w, http.StatusOK, envelope{"comments": comments})
+	responses.JSON(w, http.StatusOK, pageEnvelope("comments", comments))
 }
 
 func (h *CommentHandler) handleDeleteComment(w http.ResponseWriter, r *http.Request) {
@@ -131,7 +149,7 @@ func (h *CommentHandler) handleDeleteComment(w http.ResponseWriter, r *http.Requ
 	}
 
 	ctx := r.Context()
-	err = h.svc.Delete(ctx, commentID, user.ID)
+	err = h.svc.Delete(ctx, commentID, user.Actor())
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrAccessDenied):
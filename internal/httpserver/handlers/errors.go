@@ -1,12 +1,19 @@
 package handlers
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrInternalServer     = errors.New("internal server error")
 	ErrInvalidRequestBody = errors.New("invalid request body")
+	ErrMissingToken       = errors.New("token query parameter is required")
+	ErrInvalidSearchQuery = fmt.Errorf("q query parameter must be 1 to %d characters", maxSearchQueryLength)
 
 	ErrFileNotReceived = errors.New("no file received")
 	ErrFileReadFailed  = errors.New("failed to read the file")
 	ErrFileSaveFailed  = errors.New("failed to save the file")
+
+	ErrInvalidLockoutKind = errors.New("invalid lockout kind")
 )
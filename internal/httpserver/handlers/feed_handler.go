@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/escoutdoor/social/internal/httpserver/responses"
+	"github.com/escoutdoor/social/internal/service"
+	"github.com/escoutdoor/social/pkg/validator"
+	"github.com/go-chi/chi/v5"
+)
+
+type FeedHandler struct {
+	svc       service.Feed
+	validator *validator.Validator
+}
+
+func NewFeedHandler(svc service.Feed, v *validator.Validator) FeedHandler {
+	return FeedHandler{
+		svc:       svc,
+		validator: v,
+	}
+}
+
+func (h *FeedHandler) Router() *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/", h.handleGetFeed)
+	return r
+}
+
+func (h *FeedHandler) handleGetFeed(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	page, err := getCursorPage(r, h.validator)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	posts, err := h.svc.Get(ctx, user.ID, page)
+	if err != nil {
+		slog.Error("FeedHandler.handleGetFeed - FeedService.Get", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, pageEnvelope("posts", posts))
+}
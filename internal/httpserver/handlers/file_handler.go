@@ -1,14 +1,20 @@
 package handlers
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 
 	"github.com/escoutdoor/social/internal/httpserver/responses"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
 	"github.com/escoutdoor/social/internal/service"
 	"github.com/go-chi/chi/v5"
 )
 
+// multipartOverhead leaves room in a request for the multipart boundaries
+// and headers around the file.
+const multipartOverhead = 1 << 20
+
 type FileHandler struct {
 	svc service.File
 }
@@ -22,23 +28,101 @@ func NewFileHandler(svc service.File) FileHandler {
 func (h *FileHandler) Router() *chi.Mux {
 	r := chi.NewRouter()
 	r.Post("/", h.create)
+	r.Get("/{id}", h.get)
+	r.Delete("/{id}", h.delete)
 	return r
 }
 
 func (h *FileHandler) create(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	// the exact limit depends on the type of the file, which is only known
+	// once it is read, so this only cuts off bodies no file could fit in
+	r.Body = http.MaxBytesReader(w, r.Body, h.svc.MaxSize()+multipartOverhead)
 	src, hdr, err := r.FormFile("file")
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			responses.ErrorResponse(w, http.StatusRequestEntityTooLarge, service.ErrFileTooLarge.Error())
+			return
+		}
 		responses.BadRequestResponse(w, ErrFileNotReceived)
 		return
 	}
 	defer src.Close()
 
 	ctx := r.Context()
-	url, err := h.svc.Create(ctx, src, hdr)
+	file, err := h.svc.Create(ctx, user.ID, src, hdr)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrFileTooLarge):
+			responses.ErrorResponse(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		case errors.Is(err, service.ErrUnsupportedFileType):
+			responses.ErrorResponse(w, http.StatusUnsupportedMediaType, err.Error())
+			return
+		case errors.Is(err, service.ErrFileExtensionMismatch):
+			responses.BadRequestResponse(w, err)
+			return
+		default:
+			slog.Error("FileHandler.Create - FileService.Create", "error", err)
+			responses.InternalServerResponse(w, ErrFileSaveFailed)
+			return
+		}
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "file successfully uploaded", "id": file.ID, "url": file.URL})
+}
+
+func (h *FileHandler) get(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	file, err := h.svc.GetByID(ctx, id, user.ID)
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrFileNotFound) {
+			responses.NotFoundResponse(w, err)
+			return
+		}
+		slog.Error("FileHandler.get - FileService.GetByID", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"file": file})
+}
+
+func (h *FileHandler) delete(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
 	if err != nil {
-		slog.Error("FileHandler.Create - FileService.Create", "error", err)
-		responses.InternalServerResponse(w, ErrFileSaveFailed)
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.svc.Delete(ctx, id, user.ID); err != nil {
+		if errors.Is(err, repoerrs.ErrFileNotFound) {
+			responses.NotFoundResponse(w, err)
+			return
+		}
+		slog.Error("FileHandler.delete - FileService.Delete", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
 		return
 	}
-	responses.JSON(w, http.StatusOK, envelope{"message": "file successfully uploaded", "url": url})
+	responses.JSON(w, http.StatusOK, envelope{"message": "file successfully deleted"})
 }
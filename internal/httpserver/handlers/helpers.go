@@ -3,10 +3,14 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 
 	"github.com/escoutdoor/social/internal/httpserver/middlewares"
+	"github.com/escoutdoor/social/internal/httpserver/responses"
+	"github.com/escoutdoor/social/internal/service"
 	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/validator"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
@@ -19,6 +23,25 @@ func getIDParam(r *http.Request) (uuid.UUID, error) {
 	return id, nil
 }
 
+// getCursorPage reads the limit and cursor query parameters.
+func getCursorPage(r *http.Request, v *validator.Validator) (types.CursorPage, error) {
+	q := r.URL.Query()
+	limit, err := v.ValidateLimit(q.Get("limit"))
+	if err != nil {
+		return types.CursorPage{}, err
+	}
+
+	page := types.CursorPage{Limit: limit}
+	if c := q.Get("cursor"); c != "" {
+		cursor, err := types.DecodeCursor(c)
+		if err != nil {
+			return types.CursorPage{}, err
+		}
+		page.After = cursor
+	}
+	return page, nil
+}
+
 func getUserFromCtx(r *http.Request) (*types.User, error) {
 	user, ok := r.Context().Value(middlewares.UserCtxKey).(*types.User)
 	if !ok {
@@ -27,4 +50,62 @@ func getUserFromCtx(r *http.Request) (*types.User, error) {
 	return user, nil
 }
 
+func getSessionIDFromCtx(r *http.Request) (uuid.UUID, error) {
+	id, ok := r.Context().Value(middlewares.SessionCtxKey).(uuid.UUID)
+	if !ok {
+		return uuid.Nil, fmt.Errorf("failed to get session from context")
+	}
+	return id, nil
+}
+
+// getClientIP returns the client address set by middleware.RealIP, with the
+// port stripped when the request came without a forwarding header.
+func getClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 type envelope map[string]interface{}
+
+// publicUsers turns a page of users into one that is safe to show others.
+func publicUsers(page types.Page[types.User]) types.Page[types.PublicUser] {
+	users := make([]types.PublicUser, len(page.Items))
+	for i, u := range page.Items {
+		users[i] = u.Public()
+	}
+	return types.Page[types.PublicUser]{Items: users, NextCursor: page.NextCursor}
+}
+
+// pageEnvelope puts the page items under key next to the cursor of the next
+// page.
+func pageEnvelope[T any](key string, page types.Page[T]) envelope {
+	var next *string
+	if page.NextCursor != nil {
+		encoded := page.NextCursor.Encode()
+		next = &encoded
+	}
+	return envelope{
+		key:           page.Items,
+		"next_cursor": next,
+		"has_more":    next != nil,
+	}
+}
+
+// writeRetryError responds to an error that tells when to try again with 429,
+// or 423 for a locked account, and the Retry-After header. It reports
+// whether err was such an error.
+func writeRetryError(w http.ResponseWriter, err error) bool {
+	var retryErr *service.RetryError
+	if !errors.As(err, &retryErr) {
+		return false
+	}
+	status := http.StatusTooManyRequests
+	if errors.Is(err, service.ErrAccountLocked) {
+		status = http.StatusLocked
+	}
+	responses.RetryAfterResponse(w, status, retryErr.RetryAfter, err)
+	return true
+}
@@ -8,26 +8,31 @@ import (
 	"github.com/escoutdoor/social/internal/httpserver/responses"
 	"github.com/escoutdoor/social/internal/repository/repoerrs"
 	"github.com/escoutdoor/social/internal/service"
+	"github.com/escoutdoor/social/pkg/validator"
 	"github.com/go-chi/chi/v5"
 )
 
 type LikeHandler struct {
-	svc service.Like
+	svc       service.Like
+	validator *validator.Validator
 }
 
-func NewLikeHandler(svc service.Like) LikeHandler {
+func NewLikeHandler(svc service.Like, v *validator.Validator) LikeHandler {
 	return LikeHandler{
-		svc: svc,
+		svc:       svc,
+		validator: v,
 	}
 }
 
 func (h *LikeHandler) Router() *chi.Mux {
 	r := chi.NewRouter()
 	r.Route("/posts", func(r chi.Router) {
+		r.Get("/{id}", h.handleGetPostLikers)
 		r.Post("/{id}", h.handleLikePost)
 		r.Delete("/{id}", h.handleRemoveLikeFromPost)
 	})
 	r.Route("/comments", func(r chi.Router) {
+		r.Get("/{id}", h.handleGetCommentLikers)
 		r.Post("/{id}", h.handleLikeComment)
 		r.Delete("/{id}", h.handleRemoveLikeFromComment)
 	})
@@ -55,6 +60,9 @@ func (h *LikeHandler) handleLikePost(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, repoerrs.ErrPostNotFound):
 			responses.NotFoundResponse(w, err)
 			return
+		case errors.Is(err, service.ErrBlocked):
+			responses.ForbiddenResponse(w, err)
+			return
 		default:
 			slog.Error("LikeHandler.handleLikePost - LikeService.LikePost", "error", err)
 			responses.InternalServerResponse(w, ErrInternalServer)
@@ -111,6 +119,9 @@ func (h *LikeHandler) handleLikeComment(w http.ResponseWriter, r *http.Request)
 		case errors.Is(err, repoerrs.ErrCommentNotFound):
 			responses.NotFoundResponse(w, err)
 			return
+		case errors.Is(err, service.ErrBlocked):
+			responses.ForbiddenResponse(w, err)
+			return
 		default:
 			slog.Error("LikeHandler.handleLikeComment - LikeService.LikeComment", "error", err)
 			responses.InternalServerResponse(w, ErrInternalServer)
@@ -144,3 +155,65 @@ func (h *LikeHandler) handleRemoveLikeFromComment(w http.ResponseWriter, r *http
 	}
 	responses.JSON(w, http.StatusOK, envelope{"message": "you removed the like from the comment"})
 }
+
+func (h *LikeHandler) handleGetPostLikers(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	postID, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+	page, err := getCursorPage(r, h.validator)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	users, err := h.svc.GetPostLikers(ctx, postID, user.ID, page)
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrPostNotFound) {
+			responses.NotFoundResponse(w, err)
+			return
+		}
+		slog.Error("LikeHandler.handleGetPostLikers - LikeService.GetPostLikers", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, pageEnvelope("users", publicUsers(users)))
+}
+
+func (h *LikeHandler) handleGetCommentLikers(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	commentID, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+	page, err := getCursorPage(r, h.validator)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	users, err := h.svc.GetCommentLikers(ctx, commentID, user.ID, page)
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrCommentNotFound) {
+			responses.NotFoundResponse(w, err)
+			return
+		}
+		slog.Error("LikeHandler.handleGetCommentLikers - LikeService.GetCommentLikers", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, pageEnvelope("users", publicUsers(users)))
+}
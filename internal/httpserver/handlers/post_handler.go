@@ -26,13 +26,18 @@ func NewPostHandler(svc service.Post, v *validator.Validator) PostHandler {
 	}
 }
 
-func (h *PostHandler) Router() *chi.Mux {
+// Router registers post routes. Middlewares passed in guard only the routes
+// that publish content.
+func (h *PostHandler) Router(publish ...func(http.Handler) http.Handler) *chi.Mux {
 	r := chi.NewRouter()
-	r.Post("/", h.handleCreatePost)
 	r.Get("/", h.handleGetAll)
 	r.Get("/{id}", h.handleGetByID)
-	r.Patch("/{id}", h.handleUpdatePost)
 	r.Delete("/{id}", h.handleDeletePost)
+	r.Group(func(r chi.Router) {
+		r.Use(publish...)
+		r.Post("/", h.handleCreatePost)
+		r.Patch("/{id}", h.handleUpdatePost)
+	})
 
 	return r
 }
@@ -57,9 +62,17 @@ func (h *PostHandler) handleCreatePost(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	post, err := h.svc.Create(ctx, user.ID, input)
 	if err != nil {
-		slog.Error("PostHandler.handleCreatePost - PostService.Create", "error", err)
-		responses.InternalServerResponse(w, ErrInternalServer)
-		return
+		switch {
+		case errors.Is(err, service.ErrTooManyMedia),
+			errors.Is(err, service.ErrDuplicateMedia),
+			errors.Is(err, service.ErrMediaNotFound):
+			responses.BadRequestResponse(w, err)
+			return
+		default:
+			slog.Error("PostHandler.handleCreatePost - PostService.Create", "error", err)
+			responses.InternalServerResponse(w, ErrInternalServer)
+			return
+		}
 	}
 	responses.JSON(w, http.StatusCreated, envelope{"post": post})
 }
@@ -87,7 +100,7 @@ func (h *PostHandler) handleUpdatePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	post, err := h.svc.Update(ctx, postID, user.ID, input)
+	post, err := h.svc.Update(ctx, postID, user.Actor(), input)
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrAccessDenied):
@@ -96,6 +109,11 @@ func (h *PostHandler) handleUpdatePost(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, repoerrs.ErrPostNotFound):
 			responses.NotFoundResponse(w, err)
 			return
+		case errors.Is(err, service.ErrTooManyMedia),
+			errors.Is(err, service.ErrDuplicateMedia),
+			errors.Is(err, service.ErrMediaNotFound):
+			responses.BadRequestResponse(w, err)
+			return
 		default:
 			slog.Error("PostHandler.handleUpdatePost - PostService.Update", "error", err)
 			responses.InternalServerResponse(w, ErrInternalServer)
@@ -106,6 +124,11 @@ func (h *PostHandler) handleUpdatePost(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *PostHandler) handleGetByID(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
 	id, err := getIDParam(r)
 	if err != nil {
 		responses.BadRequestResponse(w, err)
@@ -113,7 +136,7 @@ func (h *PostHandler) handleGetByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	post, err := h.svc.GetByID(ctx, id)
+	post, err := h.svc.GetByID(ctx, id, user.ID)
 	if err != nil {
 		if errors.Is(err, repoerrs.ErrPostNotFound) {
 			responses.NotFoundResponse(w, err)
@@ -127,14 +150,25 @@ func (h *PostHandler) handleGetByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *PostHandler) handleGetAll(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	page, err := getCursorPage(r, h.validator)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
 	ctx := r.Context()
-	posts, err := h.svc.GetAll(ctx)
+	posts, err := h.svc.GetAll(ctx, user.ID, page)
 	if err != nil {
 		slog.Error("PostHandler.handleGetAll - PostService.GetAll", "error", err)
 		responses.InternalServerResponse(w, ErrInternalServer)
 		return
 	}
-	responses.JSON(w, http.StatusOK, envelope{"posts": posts})
+	responses.JSON(w, http.StatusOK, pageEnvelope("posts", posts))
 }
 
 func (h *PostHandler) handleDeletePost(w http.ResponseWriter, r *http.Request) {
@@ -150,7 +184,7 @@ func (h *PostHandler) handleDeletePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	err = h.svc.Delete(ctx, postID, user.ID)
+	err = h.svc.Delete(ctx, postID, user.Actor())
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrAccessDenied):
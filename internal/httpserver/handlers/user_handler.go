@@ -5,7 +5,11 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
 
+	"github.com/escoutdoor/social/internal/httpserver/middlewares"
 	"github.com/escoutdoor/social/internal/httpserver/responses"
 	"github.com/escoutdoor/social/internal/repository/repoerrs"
 	"github.com/escoutdoor/social/internal/service"
@@ -14,28 +18,83 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
+const maxSearchQueryLength = 100
+
 type UserHandler struct {
 	svc       service.User
+	followSvc service.Follow
+	blockSvc  service.Block
+	exportSvc service.Export
 	validator *validator.Validator
 }
 
-func NewUserHandler(svc service.User, v *validator.Validator) UserHandler {
+func NewUserHandler(svc service.User, followSvc service.Follow, blockSvc service.Block, exportSvc service.Export, v *validator.Validator) UserHandler {
 	return UserHandler{
 		svc:       svc,
+		followSvc: followSvc,
+		blockSvc:  blockSvc,
+		exportSvc: exportSvc,
 		validator: v,
 	}
 }
 
 func (h *UserHandler) Router() *chi.Mux {
 	r := chi.NewRouter()
-	r.Patch("/", h.handleUpdateUser)
-	r.Delete("/", h.handleDeleteUser)
+	// an API key must not take over or delete the account, nor get hold of
+	// all of its data through an export
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.RequireSession)
+		r.Patch("/", h.handleUpdateUser)
+		r.Delete("/", h.handleDeleteUser)
+		r.Post("/me/export", h.handleRequestExport)
+		r.Get("/me/export/{id}", h.handleGetExport)
+	})
+	r.Get("/me", h.handleGetMe)
 	r.Get("/{id}", h.handleGetByID)
+	r.Get("/by-username/{username}", h.handleGetByUsername)
+	r.Get("/search", h.handleSearch)
+	r.Post("/{id}/follow", h.handleFollow)
+	r.Delete("/{id}/follow", h.handleUnfollow)
+	r.Get("/{id}/followers", h.handleGetFollowers)
+	r.Get("/{id}/following", h.handleGetFollowing)
+	r.Get("/follow-requests", h.handleGetFollowRequests)
+	r.Post("/follow-requests/{id}", h.handleApproveFollowRequest)
+	r.Delete("/follow-requests/{id}", h.handleRejectFollowRequest)
+	r.Get("/blocks", h.handleGetBlocked)
+	r.Post("/{id}/block", h.handleBlock)
+	r.Delete("/{id}/block", h.handleUnblock)
+	r.Get("/mutes", h.handleGetMuted)
+	r.Post("/{id}/mute", h.handleMute)
+	r.Delete("/{id}/mute", h.handleUnmute)
 
 	return r
 }
 
+// handleGetMe returns the account of the user, which tells them the state
+// of their email address as well.
+func (h *UserHandler) handleGetMe(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	me, err := h.svc.GetProfile(ctx, user.ID, user.ID)
+	if err != nil {
+		slog.Error("UserHandler.handleGetMe - UserService.GetProfile", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"user": types.NewAccount(*me)})
+}
+
 func (h *UserHandler) handleGetByID(w http.ResponseWriter, r *http.Request) {
+	viewer, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
 	id, err := getIDParam(r)
 	if err != nil {
 		responses.BadRequestResponse(w, err)
@@ -43,19 +102,76 @@ func (h *UserHandler) handleGetByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	user, err := h.svc.GetByID(ctx, id)
+	user, err := h.svc.GetProfile(ctx, id, viewer.ID)
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrUserNotFound) {
+			responses.NotFoundResponse(w, err)
+			return
+		}
+		slog.Error("UserHandler.handleGetByID - UserService.GetProfile", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"user": user})
+}
+
+// handleGetByUsername looks the user up by their handle. An old handle that
+// is still within its grace period redirects to the current one.
+func (h *UserHandler) handleGetByUsername(w http.ResponseWriter, r *http.Request) {
+	viewer, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	username := chi.URLParam(r, "username")
+
+	ctx := r.Context()
+	user, err := h.svc.GetByUsername(ctx, username, viewer.ID)
 	if err != nil {
 		if errors.Is(err, repoerrs.ErrUserNotFound) {
 			responses.NotFoundResponse(w, err)
 			return
 		}
-		slog.Error("UserHandler.handleGetByID - UserService.GetByID", "error", err)
+		slog.Error("UserHandler.handleGetByUsername - UserService.GetByUsername", "error", err)
 		responses.InternalServerResponse(w, ErrInternalServer)
 		return
 	}
+	if !strings.EqualFold(user.Username, username) {
+		// the old handle may be claimed by someone else once it expires, so
+		// the redirect must not be cached for good
+		http.Redirect(w, r, path.Join(path.Dir(r.URL.Path), user.Username), http.StatusTemporaryRedirect)
+		return
+	}
 	responses.JSON(w, http.StatusOK, envelope{"user": user})
 }
 
+func (h *UserHandler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	viewer, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" || utf8.RuneCountInString(query) > maxSearchQueryLength {
+		responses.BadRequestResponse(w, ErrInvalidSearchQuery)
+		return
+	}
+	page, err := getCursorPage(r, h.validator)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	users, err := h.svc.Search(ctx, query, viewer.ID, page)
+	if err != nil {
+		slog.Error("UserHandler.handleSearch - UserService.Search", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, pageEnvelope("users", publicUsers(users)))
+}
+
 func (h *UserHandler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 	user, err := getUserFromCtx(r)
 	if err != nil {
@@ -76,16 +192,67 @@ func (h *UserHandler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	uu, err := h.svc.Update(ctx, *user, input)
 	if err != nil {
-		if errors.Is(err, validator.ErrInvalidDateFormat) {
+		switch {
+		case errors.Is(err, validator.ErrInvalidDateFormat),
+			errors.Is(err, repoerrs.ErrEmailAlreadyExists),
+			errors.Is(err, repoerrs.ErrUsernameTaken):
 			responses.BadRequestResponse(w, err)
 			return
+		case errors.Is(err, service.ErrUsernameCooldown):
+			responses.ErrorResponse(w, http.StatusConflict, err.Error())
+			return
 		}
 
 		slog.Error("UserHandler.handleUpdateUser - UserService.Update", "error", err)
 		responses.InternalServerResponse(w, ErrInternalServer)
 		return
 	}
-	responses.JSON(w, http.StatusOK, envelope{"user": uu})
+	responses.JSON(w, http.StatusOK, envelope{"user": types.NewAccount(*uu)})
+}
+
+// handleRequestExport starts building an archive of the data of the user.
+// The user is mailed a download link once it is ready.
+func (h *UserHandler) handleRequestExport(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	export, err := h.exportSvc.Request(ctx, *user)
+	if err != nil {
+		slog.Error("UserHandler.handleRequestExport - ExportService.Request", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusAccepted, envelope{"export": export})
+}
+
+func (h *UserHandler) handleGetExport(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	export, err := h.exportSvc.Get(ctx, id, user.ID)
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrExportNotFound) {
+			responses.NotFoundResponse(w, err)
+			return
+		}
+		slog.Error("UserHandler.handleGetExport - ExportService.Get", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"export": export})
 }
 
 func (h *UserHandler) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
@@ -108,3 +275,348 @@ func (h *UserHandler) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 	}
 	responses.JSON(w, http.StatusOK, envelope{"message": "user successfully deleted"})
 }
+
+func (h *UserHandler) handleFollow(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	status, err := h.followSvc.Follow(ctx, user.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repoerrs.ErrSelfFollow),
+			errors.Is(err, repoerrs.ErrAlreadyFollowing),
+			errors.Is(err, repoerrs.ErrAlreadyRequested):
+			responses.BadRequestResponse(w, err)
+			return
+		case errors.Is(err, repoerrs.ErrUserNotFound):
+			responses.NotFoundResponse(w, err)
+			return
+		case errors.Is(err, service.ErrBlocked):
+			responses.ForbiddenResponse(w, err)
+			return
+		default:
+			slog.Error("UserHandler.handleFollow - FollowService.Follow", "error", err)
+			responses.InternalServerResponse(w, ErrInternalServer)
+			return
+		}
+	}
+	if status == types.FollowStatusRequested {
+		responses.JSON(w, http.StatusAccepted, envelope{"message": "follow request sent", "status": status})
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "user successfully followed", "status": status})
+}
+
+func (h *UserHandler) handleUnfollow(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.followSvc.Unfollow(ctx, user.ID, id); err != nil {
+		if errors.Is(err, repoerrs.ErrNotFollowing) {
+			responses.BadRequestResponse(w, err)
+			return
+		}
+		slog.Error("UserHandler.handleUnfollow - FollowService.Unfollow", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "user successfully unfollowed"})
+}
+
+func (h *UserHandler) handleGetFollowers(w http.ResponseWriter, r *http.Request) {
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+	page, err := getCursorPage(r, h.validator)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	users, err := h.followSvc.GetFollowers(ctx, id, page)
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrUserNotFound) {
+			responses.NotFoundResponse(w, err)
+			return
+		}
+		slog.Error("UserHandler.handleGetFollowers - FollowService.GetFollowers", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, pageEnvelope("followers", publicUsers(users)))
+}
+
+func (h *UserHandler) handleGetFollowing(w http.ResponseWriter, r *http.Request) {
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+	page, err := getCursorPage(r, h.validator)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	users, err := h.followSvc.GetFollowing(ctx, id, page)
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrUserNotFound) {
+			responses.NotFoundResponse(w, err)
+			return
+		}
+		slog.Error("UserHandler.handleGetFollowing - FollowService.GetFollowing", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, pageEnvelope("following", publicUsers(users)))
+}
+
+func (h *UserHandler) handleGetFollowRequests(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	page, err := getCursorPage(r, h.validator)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	users, err := h.followSvc.GetRequests(ctx, user.ID, page)
+	if err != nil {
+		slog.Error("UserHandler.handleGetFollowRequests - FollowService.GetRequests", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, pageEnvelope("follow_requests", publicUsers(users)))
+}
+
+func (h *UserHandler) handleApproveFollowRequest(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.followSvc.ApproveRequest(ctx, user.ID, id); err != nil {
+		if errors.Is(err, repoerrs.ErrFollowRequestNotFound) {
+			responses.NotFoundResponse(w, err)
+			return
+		}
+		slog.Error("UserHandler.handleApproveFollowRequest - FollowService.ApproveRequest", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "follow request approved"})
+}
+
+func (h *UserHandler) handleRejectFollowRequest(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.followSvc.RejectRequest(ctx, user.ID, id); err != nil {
+		if errors.Is(err, repoerrs.ErrFollowRequestNotFound) {
+			responses.NotFoundResponse(w, err)
+			return
+		}
+		slog.Error("UserHandler.handleRejectFollowRequest - FollowService.RejectRequest", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "follow request rejected"})
+}
+
+func (h *UserHandler) handleBlock(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.blockSvc.Block(ctx, user.ID, id); err != nil {
+		switch {
+		case errors.Is(err, repoerrs.ErrSelfBlock),
+			errors.Is(err, repoerrs.ErrAlreadyBlocked):
+			responses.BadRequestResponse(w, err)
+			return
+		case errors.Is(err, repoerrs.ErrUserNotFound):
+			responses.NotFoundResponse(w, err)
+			return
+		default:
+			slog.Error("UserHandler.handleBlock - BlockService.Block", "error", err)
+			responses.InternalServerResponse(w, ErrInternalServer)
+			return
+		}
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "user successfully blocked"})
+}
+
+func (h *UserHandler) handleUnblock(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.blockSvc.Unblock(ctx, user.ID, id); err != nil {
+		if errors.Is(err, repoerrs.ErrNotBlocked) {
+			responses.BadRequestResponse(w, err)
+			return
+		}
+		slog.Error("UserHandler.handleUnblock - BlockService.Unblock", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "user successfully unblocked"})
+}
+
+func (h *UserHandler) handleGetBlocked(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	page, err := getCursorPage(r, h.validator)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	users, err := h.blockSvc.GetBlocked(ctx, user.ID, page)
+	if err != nil {
+		slog.Error("UserHandler.handleGetBlocked - BlockService.GetBlocked", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, pageEnvelope("blocked", publicUsers(users)))
+}
+
+func (h *UserHandler) handleMute(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.blockSvc.Mute(ctx, user.ID, id); err != nil {
+		switch {
+		case errors.Is(err, repoerrs.ErrSelfMute),
+			errors.Is(err, repoerrs.ErrAlreadyMuted):
+			responses.BadRequestResponse(w, err)
+			return
+		case errors.Is(err, repoerrs.ErrUserNotFound):
+			responses.NotFoundResponse(w, err)
+			return
+		default:
+			slog.Error("UserHandler.handleMute - BlockService.Mute", "error", err)
+			responses.InternalServerResponse(w, ErrInternalServer)
+			return
+		}
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "user successfully muted"})
+}
+
+func (h *UserHandler) handleUnmute(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	id, err := getIDParam(r)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.blockSvc.Unmute(ctx, user.ID, id); err != nil {
+		if errors.Is(err, repoerrs.ErrNotMuted) {
+			responses.BadRequestResponse(w, err)
+			return
+		}
+		slog.Error("UserHandler.handleUnmute - BlockService.Unmute", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, envelope{"message": "user successfully unmuted"})
+}
+
+func (h *UserHandler) handleGetMuted(w http.ResponseWriter, r *http.Request) {
+	user, err := getUserFromCtx(r)
+	if err != nil {
+		responses.UnauthorizedResponse(w, err)
+		return
+	}
+	page, err := getCursorPage(r, h.validator)
+	if err != nil {
+		responses.BadRequestResponse(w, err)
+		return
+	}
+
+	ctx := r.Context()
+	users, err := h.blockSvc.GetMuted(ctx, user.ID, page)
+	if err != nil {
+		slog.Error("UserHandler.handleGetMuted - BlockService.GetMuted", "error", err)
+		responses.InternalServerResponse(w, ErrInternalServer)
+		return
+	}
+	responses.JSON(w, http.StatusOK, pageEnvelope("muted", publicUsers(users)))
+}
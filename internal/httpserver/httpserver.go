@@ -17,24 +17,31 @@ type Opts struct {
 }
 
 func New(opts Opts) *http.Server {
-	user := handlers.NewUserHandler(opts.Services.User, opts.Validator)
+	user := handlers.NewUserHandler(opts.Services.User, opts.Services.Follow, opts.Services.Block, opts.Services.Export, opts.Validator)
 	auth := handlers.NewAuthHandler(opts.Services.Auth, opts.Validator)
+	feed := handlers.NewFeedHandler(opts.Services.Feed, opts.Validator)
 	post := handlers.NewPostHandler(opts.Services.Post, opts.Validator)
-	like := handlers.NewLikeHandler(opts.Services.Like)
+	like := handlers.NewLikeHandler(opts.Services.Like, opts.Validator)
 	comment := handlers.NewCommentHandler(opts.Services.Comment, opts.Validator)
 	file := handlers.NewFileHandler(opts.Services.File)
+	apiKey := handlers.NewAPIKeyHandler(opts.Services.APIKey, opts.Validator)
+	admin := handlers.NewAdminHandler(opts.Services.Lockout, opts.Services.Moderation, opts.Services.User, opts.Validator)
 
 	api := &Server{
-		user:    user,
-		auth:    auth,
-		post:    post,
-		like:    like,
-		comment: comment,
-		file:    file,
+		user:                 user,
+		auth:                 auth,
+		feed:                 feed,
+		post:                 post,
+		like:                 like,
+		comment:              comment,
+		file:                 file,
+		apiKey:               apiKey,
+		admin:                admin,
+		requireVerifiedEmail: opts.Config.RequireVerifiedEmail,
 	}
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%d", opts.Config.Port),
-		Handler: api.NewRouter(opts.Services.Auth, opts.Services.User),
+		Handler: api.NewRouter(opts.Services.Auth, opts.Services.User, opts.Services.APIKey),
 	}
 	return server
 }
@@ -42,8 +49,13 @@ func New(opts Opts) *http.Server {
 type Server struct {
 	user    handlers.UserHandler
 	auth    handlers.AuthHandler
+	feed    handlers.FeedHandler
 	post    handlers.PostHandler
 	like    handlers.LikeHandler
 	comment handlers.CommentHandler
 	file    handlers.FileHandler
+	apiKey  handlers.APIKeyHandler
+	admin   handlers.AdminHandler
+
+	requireVerifiedEmail bool
 }
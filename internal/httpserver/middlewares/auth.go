@@ -6,43 +6,69 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/escoutdoor/social/internal/httpserver/responses"
 	"github.com/escoutdoor/social/internal/repository/repoerrs"
 	"github.com/escoutdoor/social/internal/service"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
 )
 
-const UserCtxKey string = "user"
+const (
+	UserCtxKey    string = "user"
+	SessionCtxKey string = "session"
+	APIKeyCtxKey  string = "api_key"
+)
 
 type AuthMiddleware struct {
-	authSvc service.Auth
-	userSvc service.User
+	authSvc   service.Auth
+	userSvc   service.User
+	apiKeySvc service.APIKey
 }
 
-func NewAuthMiddleware(authSvc service.Auth, userSvc service.User) *AuthMiddleware {
+func NewAuthMiddleware(authSvc service.Auth, userSvc service.User, apiKeySvc service.APIKey) *AuthMiddleware {
 	return &AuthMiddleware{
-		authSvc: authSvc,
-		userSvc: userSvc,
+		authSvc:   authSvc,
+		userSvc:   userSvc,
+		apiKeySvc: apiKeySvc,
 	}
 }
 
+// Auth accepts either a JWT access token or an API key as the bearer token.
+// Requests made with an API key carry the key in the context and no session.
 func (m *AuthMiddleware) Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		jwtToken := r.Header.Get("Authorization")
-		if len(jwtToken) == 0 {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || len(token) == 0 {
 			slog.Error("AuthMiddleware: missing bearer token", "error", ErrInvalidAuthorizationHeader.Error())
 			responses.UnauthorizedResponse(w, ErrInvalidAuthorizationHeader)
 			return
 		}
-		jwtToken = jwtToken[len("Bearer "):]
-		id, err := m.authSvc.ParseToken(jwtToken)
-		if err != nil {
-			slog.Error("AuthMiddleware: failed to parse token", "error", err.Error())
-			responses.UnauthorizedResponse(w, fmt.Errorf("failed to parse token: %w", err))
-			return
+
+		ctx := r.Context()
+		var userID uuid.UUID
+		if strings.HasPrefix(token, types.APIKeyPrefix) {
+			apiKey, err := m.apiKeySvc.Authenticate(ctx, token)
+			if err != nil {
+				slog.Error("AuthMiddleware: failed to authenticate api key", "error", err.Error())
+				responses.UnauthorizedResponse(w, fmt.Errorf("failed to authenticate api key: %w", err))
+				return
+			}
+			userID = apiKey.UserID
+			ctx = context.WithValue(ctx, APIKeyCtxKey, apiKey)
+		} else {
+			claims, err := m.authSvc.ParseToken(ctx, token)
+			if err != nil {
+				slog.Error("AuthMiddleware: failed to parse token", "error", err.Error())
+				responses.UnauthorizedResponse(w, fmt.Errorf("failed to parse token: %w", err))
+				return
+			}
+			userID = claims.UserID
+			ctx = context.WithValue(ctx, SessionCtxKey, claims.SessionID)
 		}
 
-		user, err := m.userSvc.GetByID(r.Context(), id)
+		user, err := m.userSvc.GetByID(ctx, userID)
 		if err != nil {
 			if errors.Is(err, repoerrs.ErrUserNotFound) {
 				responses.UnauthorizedResponse(w, err)
@@ -52,9 +78,86 @@ func (m *AuthMiddleware) Auth(next http.Handler) http.Handler {
 			responses.InternalServerResponse(w, fmt.Errorf("failed to authorize"))
 			return
 		}
+		// a deleted account only comes back through signing in again
+		if user.IsDeactivated() {
+			responses.UnauthorizedResponse(w, ErrAccountDeactivated)
+			return
+		}
 
-		ctx := context.WithValue(r.Context(), UserCtxKey, user)
-		req := r.WithContext(ctx)
-		next.ServeHTTP(w, req)
+		ctx = context.WithValue(ctx, UserCtxKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// RequireSession rejects requests authenticated with an API key, so keys
+// can't manage sessions, other keys or the account itself. It must run after
+// Auth.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(APIKeyCtxKey).(*types.APIKey); ok {
+			responses.ForbiddenResponse(w, ErrSessionRequired)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WriteScope requires API keys to have the scope for anything but reads.
+// Requests made with a session are not limited. It must run after Auth.
+func WriteScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey, ok := r.Context().Value(APIKeyCtxKey).(*types.APIKey)
+			if !ok || isSafeMethod(r.Method) || apiKey.HasScope(scope) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			responses.ForbiddenResponse(w, fmt.Errorf("%w: %s", ErrMissingScope, scope))
+		})
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// VerifiedEmail rejects requests from users that have not confirmed their
+// email address. It must run after Auth.
+func VerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(UserCtxKey).(*types.User)
+		if !ok {
+			responses.UnauthorizedResponse(w, ErrUserNotInContext)
+			return
+		}
+		if !user.IsEmailVerified() {
+			responses.ForbiddenResponse(w, service.ErrEmailNotVerified)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequirePermission rejects requests from users whose role does not grant
+// perm. It must run after Auth.
+func RequirePermission(perm service.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := r.Context().Value(UserCtxKey).(*types.User)
+			if !ok {
+				responses.UnauthorizedResponse(w, ErrUserNotInContext)
+				return
+			}
+			if !service.HasPermission(user.Role, perm) {
+				responses.ForbiddenResponse(w, ErrInsufficientRole)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -4,4 +4,9 @@ import "errors"
 
 var (
 	ErrInvalidAuthorizationHeader = errors.New("invalid authorization header")
+	ErrUserNotInContext           = errors.New("failed to get user from context")
+	ErrAccountDeactivated         = errors.New("account is deactivated")
+	ErrInsufficientRole           = errors.New("your role does not allow access to this resource")
+	ErrSessionRequired            = errors.New("this resource can't be accessed with an api key")
+	ErrMissingScope               = errors.New("api key is missing scope")
 )
@@ -2,7 +2,10 @@ package responses
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type ErrResponse struct {
@@ -42,3 +45,11 @@ func UnauthorizedResponse(w http.ResponseWriter, err error) {
 func ForbiddenResponse(w http.ResponseWriter, err error) {
 	ErrorResponse(w, http.StatusForbidden, err.Error())
 }
+
+// RetryAfterResponse writes an error with a Retry-After header rounded up to
+// whole seconds.
+func RetryAfterResponse(w http.ResponseWriter, status int, retryAfter time.Duration, err error) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	ErrorResponse(w, status, err.Error())
+}
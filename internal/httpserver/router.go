@@ -7,11 +7,12 @@ import (
 	"github.com/escoutdoor/social/internal/httpserver/middlewares"
 	"github.com/escoutdoor/social/internal/httpserver/responses"
 	"github.com/escoutdoor/social/internal/service"
+	"github.com/escoutdoor/social/internal/types"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-func (s *Server) NewRouter(authSvc service.Auth, userSvc service.User) *chi.Mux {
+func (s *Server) NewRouter(authSvc service.Auth, userSvc service.User, apiKeySvc service.APIKey) *chi.Mux {
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
 	router.Use(middleware.RealIP)
@@ -19,21 +20,35 @@ func (s *Server) NewRouter(authSvc service.Auth, userSvc service.User) *chi.Mux
 	router.Use(middleware.CleanPath)
 	router.MethodNotAllowed(methodNotAllowed)
 
-	authMiddleware := middlewares.NewAuthMiddleware(authSvc, userSvc)
+	authMiddleware := middlewares.NewAuthMiddleware(authSvc, userSvc, apiKeySvc)
+	sessionAuth := chi.Chain(authMiddleware.Auth, middlewares.RequireSession).Handler
+	var publish []func(http.Handler) http.Handler
+	if s.requireVerifiedEmail {
+		publish = append(publish, middlewares.VerifiedEmail)
+	}
+	router.Get("/.well-known/jwks.json", s.auth.HandleJWKS)
 	router.Route("/v1", func(r chi.Router) {
 		r.Get("/healthcheck", func(w http.ResponseWriter, r *http.Request) {
 			responses.JSON(w, http.StatusOK, map[string]string{
 				"status": "ok",
 			})
 		})
-		r.Mount("/auth", s.auth.Router())
+		r.Mount("/auth", s.auth.Router(sessionAuth))
 		r.Group(func(r chi.Router) {
 			r.Use(authMiddleware.Auth)
-			r.Mount("/users", s.user.Router())
-			r.Mount("/posts", s.post.Router())
-			r.Mount("/likes", s.like.Router())
-			r.Mount("/comments", s.comment.Router())
-			r.Mount("/files", s.file.Router())
+			// the account itself can only be managed with a session, the
+			// scope covers follows, blocks and mutes
+			r.With(middlewares.WriteScope(types.ScopeUsersWrite)).Mount("/users", s.user.Router())
+			r.Mount("/feed", s.feed.Router())
+			r.With(middlewares.WriteScope(types.ScopePostsWrite)).Mount("/posts", s.post.Router(publish...))
+			r.With(middlewares.WriteScope(types.ScopeLikesWrite)).Mount("/likes", s.like.Router())
+			r.With(middlewares.WriteScope(types.ScopeCommentsWrite)).Mount("/comments", s.comment.Router())
+			r.With(middlewares.WriteScope(types.ScopeFilesWrite)).Mount("/files", s.file.Router())
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(sessionAuth)
+			r.Mount("/api-keys", s.apiKey.Router())
+			r.Mount("/admin", s.admin.Router())
 		})
 	})
 	return router
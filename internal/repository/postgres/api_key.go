@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{
+		db: db,
+	}
+}
+
+func (s *APIKeyRepository) Create(ctx context.Context, input types.APIKey) (*types.APIKey, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO API_KEYS(USER_ID, NAME, PREFIX, TOKEN_HASH, SCOPES, EXPIRES_AT)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ID, CREATED_AT
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	args := []interface{}{
+		input.UserID,
+		input.Name,
+		input.Prefix,
+		input.TokenHash,
+		pq.Array(input.Scopes),
+		input.ExpiresAt,
+	}
+	if err := stmt.QueryRowContext(ctx, args...).Scan(&input.ID, &input.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &input, nil
+}
+
+func (s *APIKeyRepository) GetByHash(ctx context.Context, hash string) (*types.APIKey, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			ID,
+			USER_ID,
+			NAME,
+			PREFIX,
+			TOKEN_HASH,
+			SCOPES,
+			EXPIRES_AT,
+			LAST_USED_AT,
+			REVOKED_AT,
+			CREATED_AT
+		FROM API_KEYS
+		WHERE TOKEN_HASH = $1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanAPIKey(rows)
+	}
+	return nil, repoerrs.ErrAPIKeyNotFound
+}
+
+func (s *APIKeyRepository) GetAll(ctx context.Context, userID uuid.UUID) ([]types.APIKey, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			ID,
+			USER_ID,
+			NAME,
+			PREFIX,
+			TOKEN_HASH,
+			SCOPES,
+			EXPIRES_AT,
+			LAST_USED_AT,
+			REVOKED_AT,
+			CREATED_AT
+		FROM API_KEYS
+		WHERE USER_ID = $1 AND REVOKED_AT IS NULL
+		ORDER BY CREATED_AT DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []types.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, nil
+}
+
+func (s *APIKeyRepository) Touch(ctx context.Context, id uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE API_KEYS SET LAST_USED_AT = now() WHERE ID = $1
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *APIKeyRepository) Revoke(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE API_KEYS SET REVOKED_AT = now()
+		WHERE ID = $1 AND USER_ID = $2 AND REVOKED_AT IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func scanAPIKey(rows *sql.Rows) (*types.APIKey, error) {
+	var key types.APIKey
+	if err := rows.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.TokenHash,
+		pq.Array(&key.Scopes),
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+		&key.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
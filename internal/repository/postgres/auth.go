@@ -23,9 +23,15 @@ func NewAuthRepository(db *sql.DB) *AuthRepository {
 
 func (s *AuthRepository) Create(ctx context.Context, input types.CreateUserReq) (uuid.UUID, error) {
 	var id uuid.UUID
+	// handles released by a rename stay reserved for their old owner until
+	// they expire
 	stmt, err := s.db.PrepareContext(ctx, `
-		INSERT INTO USERS(FIRST_NAME, LAST_NAME, EMAIL, PASSWORD)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO USERS(FIRST_NAME, LAST_NAME, EMAIL, PASSWORD, USERNAME)
+		SELECT $1, $2, $3, $4, $5
+		WHERE NOT EXISTS (
+			SELECT 1 FROM USERNAME_HISTORY
+			WHERE LOWER(USERNAME) = LOWER($5) AND EXPIRES_AT > now()
+		)
 		RETURNING ID
 	`)
 	if err != nil {
@@ -33,11 +39,17 @@ func (s *AuthRepository) Create(ctx context.Context, input types.CreateUserReq)
 	}
 	defer stmt.Close()
 
-	args := []interface{}{input.FirstName, input.LastName, input.Email, input.Password}
+	args := []interface{}{input.FirstName, input.LastName, input.Email, input.Password, input.Username}
 	err = stmt.QueryRowContext(ctx, args...).Scan(&id)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return id, repoerrs.ErrUsernameTaken
+		}
 		var pqErr *pq.Error
 		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			if pqErr.Constraint == "users_username_idx" {
+				return id, repoerrs.ErrUsernameTaken
+			}
 			return id, repoerrs.ErrUserAlreadyExists
 		}
 		return id, err
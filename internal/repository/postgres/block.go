@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type BlockRepository struct {
+	db *sql.DB
+}
+
+func NewBlockRepository(db *sql.DB) *BlockRepository {
+	return &BlockRepository{
+		db: db,
+	}
+}
+
+func (s *BlockRepository) Block(ctx context.Context, blockerID uuid.UUID, blockedID uuid.UUID) error {
+	if blockerID == blockedID {
+		return repoerrs.ErrSelfBlock
+	}
+
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO BLOCKS(BLOCKER_ID, BLOCKED_ID) VALUES ($1, $2)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, blockerID, blockedID); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505":
+				return repoerrs.ErrAlreadyBlocked
+			case "23503":
+				return repoerrs.ErrUserNotFound
+			case "23514":
+				return repoerrs.ErrSelfBlock
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *BlockRepository) Unblock(ctx context.Context, blockerID uuid.UUID, blockedID uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		DELETE FROM BLOCKS WHERE BLOCKER_ID = $1 AND BLOCKED_ID = $2
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrNotBlocked
+	}
+	return nil
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func (s *BlockRepository) IsBlocked(ctx context.Context, userID uuid.UUID, otherID uuid.UUID) (bool, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM BLOCKS
+			WHERE (BLOCKER_ID = $1 AND BLOCKED_ID = $2)
+			OR (BLOCKER_ID = $2 AND BLOCKED_ID = $1)
+		)
+	`)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+
+	var blocked bool
+	if err := stmt.QueryRowContext(ctx, userID, otherID).Scan(&blocked); err != nil {
+		return false, err
+	}
+	return blocked, nil
+}
+
+func (s *BlockRepository) GetBlocked(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	return getUsersPage(ctx, s.db, `
+		SELECT U.*, B.CREATED_AT FROM BLOCKS B
+		JOIN USERS U ON U.ID = B.BLOCKED_ID
+		WHERE B.BLOCKER_ID = $1
+		AND ($2::TIMESTAMP IS NULL OR (B.CREATED_AT, U.ID) < ($2, $3))
+		ORDER BY B.CREATED_AT DESC, U.ID DESC
+		LIMIT $4
+	`, userID, page)
+}
+
+func (s *BlockRepository) Mute(ctx context.Context, muterID uuid.UUID, mutedID uuid.UUID) error {
+	if muterID == mutedID {
+		return repoerrs.ErrSelfMute
+	}
+
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO MUTES(MUTER_ID, MUTED_ID) VALUES ($1, $2)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, muterID, mutedID); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505":
+				return repoerrs.ErrAlreadyMuted
+			case "23503":
+				return repoerrs.ErrUserNotFound
+			case "23514":
+				return repoerrs.ErrSelfMute
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *BlockRepository) Unmute(ctx context.Context, muterID uuid.UUID, mutedID uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		DELETE FROM MUTES WHERE MUTER_ID = $1 AND MUTED_ID = $2
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, muterID, mutedID)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrNotMuted
+	}
+	return nil
+}
+
+func (s *BlockRepository) GetMuted(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	return getUsersPage(ctx, s.db, `
+		SELECT U.*, M.CREATED_AT FROM MUTES M
+		JOIN USERS U ON U.ID = M.MUTED_ID
+		WHERE M.MUTER_ID = $1
+		AND ($2::TIMESTAMP IS NULL OR (M.CREATED_AT, U.ID) < ($2, $3))
+		ORDER BY M.CREATED_AT DESC, U.ID DESC
+		LIMIT $4
+	`, userID, page)
+}
@@ -64,8 +64,9 @@ func (s *CommentRepository) GetByID(ctx context.Context, id uuid.UUID) (*types.C
 			c.UPDATED_AT,
 			c.CREATED_AT
 		FROM COMMENTS c
+		JOIN USERS u ON c.USER_ID = u.ID
 		LEFT JOIN COMMENT_LIKES l ON c.ID = l.COMMENT_ID
-		WHERE c.ID = $1
+		WHERE c.ID = $1 AND u.DEACTIVATED_AT IS NULL
 		GROUP BY c.ID
 	`)
 	if err != nil {
@@ -93,8 +94,30 @@ func (s *CommentRepository) GetByID(ctx context.Context, id uuid.UUID) (*types.C
 	return &comment, err
 }
 
-func (s *CommentRepository) GetAll(ctx context.Context, postID uuid.UUID) ([]types.Comment, error) {
+// GetAll returns a page of the top-level comments of the post, newest first,
+// each with all of its replies. Comments of users the viewer muted or shares
+// a block with, and of deactivated users, are left out together with the
+// replies to them.
+func (s *CommentRepository) GetAll(ctx context.Context, postID uuid.UUID, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.Comment], error) {
 	stmt, err := s.db.PrepareContext(ctx, `
+		WITH RECURSIVE HIDDEN AS (
+			SELECT MUTED_ID AS ID FROM MUTES WHERE MUTER_ID = $5
+			UNION SELECT BLOCKED_ID FROM BLOCKS WHERE BLOCKER_ID = $5
+			UNION SELECT BLOCKER_ID FROM BLOCKS WHERE BLOCKED_ID = $5
+			UNION SELECT ID FROM USERS WHERE DEACTIVATED_AT IS NOT NULL
+		), PAGE AS (
+			SELECT ID FROM COMMENTS
+			WHERE POST_ID = $1 AND PARENT_COMMENT_ID IS NULL
+			AND USER_ID NOT IN (SELECT ID FROM HIDDEN)
+			AND ($2::TIMESTAMP IS NULL OR (CREATED_AT, ID) < ($2, $3))
+			ORDER BY CREATED_AT DESC, ID DESC
+			LIMIT $4
+		), THREAD AS (
+			SELECT ID FROM PAGE
+			UNION ALL
+			SELECT c.ID FROM COMMENTS c JOIN THREAD t ON c.PARENT_COMMENT_ID = t.ID
+			WHERE c.USER_ID NOT IN (SELECT ID FROM HIDDEN)
+		)
 		SELECT 
 			cm.ID,
 			cm.CONTENT,
@@ -105,19 +128,20 @@ func (s *CommentRepository) GetAll(ctx context.Context, postID uuid.UUID) ([]typ
 			cm.CREATED_AT,
 			cm.UPDATED_AT
 		FROM COMMENTS cm
+		JOIN THREAD t ON cm.ID = t.ID
 		LEFT JOIN COMMENT_LIKES cl ON cm.ID = cl.COMMENT_ID
-		WHERE cm.POST_ID = $1
 		GROUP BY cm.ID
-		ORDER BY LIKES, CREATED_AT
+		ORDER BY cm.CREATED_AT DESC, cm.ID DESC
 	`)
 	if err != nil {
-		return nil, err
+		return types.Page[types.Comment]{}, err
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx, postID)
+	after, afterID := cursorArgs(page)
+	rows, err := stmt.QueryContext(ctx, postID, after, afterID, page.Limit+1, viewerID)
 	if err != nil {
-		return nil, err
+		return types.Page[types.Comment]{}, err
 	}
 	defer rows.Close()
 
@@ -140,7 +164,7 @@ func (s *CommentRepository) GetAll(ctx context.Context, postID uuid.UUID) ([]typ
 			&c.CreatedAt,
 			&c.UpdatedAt,
 		); err != nil {
-			return nil, err
+			return types.Page[types.Comment]{}, err
 		}
 		if pcid.Valid {
 			c.ParentCommentID = &pcid.UUID
@@ -155,23 +179,33 @@ func (s *CommentRepository) GetAll(ctx context.Context, postID uuid.UUID) ([]typ
 	for i := range comments {
 		comments[i].Replies = getReplies(comments[i].ID, commentsMap)
 	}
-	return comments, nil
+	return types.NewPage(comments, page.Limit, func(i int) types.Cursor {
+		return comments[i].Cursor()
+	}), nil
 }
 
-func (s *CommentRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	stmt, err := s.db.PrepareContext(ctx, `
-		DELETE FROM COMMENTS WHERE ID = $1
-	`)
+// Delete removes the comment. A moderation action, if given, is recorded
+// along with it, see PostRepository.Delete.
+func (s *CommentRepository) Delete(ctx context.Context, id uuid.UUID, moderation *types.ModerationAction) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	result, err := stmt.ExecContext(ctx, id)
+	result, err := tx.ExecContext(ctx, `DELETE FROM COMMENTS WHERE ID = $1`, id)
+	if err != nil {
+		return err
+	}
 	if v, _ := result.RowsAffected(); v == 0 {
 		return repoerrs.ErrCommentNotFound
 	}
-	return nil
+	if moderation != nil {
+		if err := createModerationAction(ctx, tx, *moderation); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 func getReplies(id uuid.UUID, commentsMap map[uuid.UUID]*types.Comment) []types.Comment {
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+)
+
+type EmailVerificationRepository struct {
+	db *sql.DB
+}
+
+func NewEmailVerificationRepository(db *sql.DB) *EmailVerificationRepository {
+	return &EmailVerificationRepository{
+		db: db,
+	}
+}
+
+func (s *EmailVerificationRepository) Create(ctx context.Context, input types.EmailVerificationToken) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO EMAIL_VERIFICATION_TOKENS(USER_ID, EMAIL, TOKEN_HASH, EXPIRES_AT)
+		VALUES ($1, $2, $3, $4)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := []interface{}{input.UserID, input.Email, input.TokenHash, input.ExpiresAt}
+	if _, err := stmt.ExecContext(ctx, args...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *EmailVerificationRepository) GetByHash(ctx context.Context, hash string) (*types.EmailVerificationToken, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			ID,
+			USER_ID,
+			EMAIL,
+			TOKEN_HASH,
+			EXPIRES_AT,
+			USED_AT,
+			CREATED_AT
+		FROM EMAIL_VERIFICATION_TOKENS
+		WHERE TOKEN_HASH = $1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var token types.EmailVerificationToken
+	err = stmt.QueryRowContext(ctx, hash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Email,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repoerrs.ErrVerifyTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed consumes the token. It fails with ErrVerifyTokenNotFound when the
+// token has already been used.
+func (s *EmailVerificationRepository) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE EMAIL_VERIFICATION_TOKENS SET USED_AT = now()
+		WHERE ID = $1 AND USED_AT IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrVerifyTokenNotFound
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+)
+
+type ExportRepository struct {
+	db *sql.DB
+}
+
+func NewExportRepository(db *sql.DB) *ExportRepository {
+	return &ExportRepository{
+		db: db,
+	}
+}
+
+func (s *ExportRepository) Create(ctx context.Context, userID uuid.UUID) (*types.DataExport, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO DATA_EXPORTS(USER_ID) VALUES ($1)
+		RETURNING ID, USER_ID, STATUS, OBJECT_KEY, EXPIRES_AT, COMPLETED_AT, CREATED_AT
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	return scanExport(stmt.QueryRowContext(ctx, userID))
+}
+
+func (s *ExportRepository) GetByID(ctx context.Context, id uuid.UUID) (*types.DataExport, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT ID, USER_ID, STATUS, OBJECT_KEY, EXPIRES_AT, COMPLETED_AT, CREATED_AT
+		FROM DATA_EXPORTS WHERE ID = $1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	return scanExport(stmt.QueryRowContext(ctx, id))
+}
+
+// GetPending returns the newest export of the user that is still being built
+// and was started after since.
+func (s *ExportRepository) GetPending(ctx context.Context, userID uuid.UUID, since time.Time) (*types.DataExport, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT ID, USER_ID, STATUS, OBJECT_KEY, EXPIRES_AT, COMPLETED_AT, CREATED_AT
+		FROM DATA_EXPORTS
+		WHERE USER_ID = $1 AND STATUS = 'pending' AND CREATED_AT > $2
+		ORDER BY CREATED_AT DESC
+		LIMIT 1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	return scanExport(stmt.QueryRowContext(ctx, userID, since))
+}
+
+func (s *ExportRepository) Complete(ctx context.Context, id uuid.UUID, objectKey string, expiresAt time.Time) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE DATA_EXPORTS SET STATUS = 'ready', OBJECT_KEY = $1, EXPIRES_AT = $2, COMPLETED_AT = now()
+		WHERE ID = $3
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, objectKey, expiresAt, id)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrExportNotFound
+	}
+	return nil
+}
+
+func (s *ExportRepository) Fail(ctx context.Context, id uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE DATA_EXPORTS SET STATUS = 'failed', COMPLETED_AT = now() WHERE ID = $1
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrExportNotFound
+	}
+	return nil
+}
+
+// GetPosts returns every post of the user, oldest first.
+func (s *ExportRepository) GetPosts(ctx context.Context, userID uuid.UUID) ([]types.Post, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			p.ID,
+			p.CONTENT,
+			p.USER_ID,
+			p.VISIBILITY,
+			COUNT(l.ID) AS LIKES,
+			p.CREATED_AT,
+			p.UPDATED_AT
+		FROM POSTS p
+		LEFT JOIN POST_LIKES l ON p.ID = l.POST_ID
+		WHERE p.USER_ID = $1
+		GROUP BY p.ID
+		ORDER BY p.CREATED_AT, p.ID
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts, err := scanPostsWithLikes(rows)
+	if err != nil {
+		return nil, err
+	}
+	return withMedia(ctx, s.db, posts)
+}
+
+// GetComments returns every comment the user wrote, oldest first.
+func (s *ExportRepository) GetComments(ctx context.Context, userID uuid.UUID) ([]types.Comment, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			c.ID,
+			c.CONTENT,
+			c.USER_ID,
+			c.POST_ID,
+			c.PARENT_COMMENT_ID,
+			COUNT(l.ID) AS LIKES,
+			c.CREATED_AT,
+			c.UPDATED_AT
+		FROM COMMENTS c
+		LEFT JOIN COMMENT_LIKES l ON c.ID = l.COMMENT_ID
+		WHERE c.USER_ID = $1
+		GROUP BY c.ID
+		ORDER BY c.CREATED_AT, c.ID
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []types.Comment{}
+	for rows.Next() {
+		var c types.Comment
+		err := rows.Scan(
+			&c.ID,
+			&c.Content,
+			&c.UserID,
+			&c.PostID,
+			&c.ParentCommentID,
+			&c.Likes,
+			&c.CreatedAt,
+			&c.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, nil
+}
+
+// GetLikes returns every like the user gave to posts and comments, oldest
+// first.
+func (s *ExportRepository) GetLikes(ctx context.Context, userID uuid.UUID) ([]types.ExportedLike, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT POST_ID, NULL::UUID, CREATED_AT FROM POST_LIKES WHERE USER_ID = $1
+		UNION ALL
+		SELECT NULL::UUID, COMMENT_ID, CREATED_AT FROM COMMENT_LIKES WHERE USER_ID = $1
+		ORDER BY CREATED_AT
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	likes := []types.ExportedLike{}
+	for rows.Next() {
+		var like types.ExportedLike
+		if err := rows.Scan(&like.PostID, &like.CommentID, &like.CreatedAt); err != nil {
+			return nil, err
+		}
+		likes = append(likes, like)
+	}
+	return likes, nil
+}
+
+func scanExport(row *sql.Row) (*types.DataExport, error) {
+	var export types.DataExport
+	err := row.Scan(
+		&export.ID,
+		&export.UserID,
+		&export.Status,
+		&export.ObjectKey,
+		&export.ExpiresAt,
+		&export.CompletedAt,
+		&export.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repoerrs.ErrExportNotFound
+		}
+		return nil, err
+	}
+	return &export, nil
+}
@@ -0,0 +1,188 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type FileRepository struct {
+	db *sql.DB
+}
+
+func NewFileRepository(db *sql.DB) *FileRepository {
+	return &FileRepository{
+		db: db,
+	}
+}
+
+func (s *FileRepository) Create(ctx context.Context, file types.FileRecord) (*types.FileRecord, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO FILES(USER_ID, OBJECT_KEY, URL, CONTENT_TYPE, SIZE, SHA256, WIDTH, HEIGHT, RENDITIONS)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ID, USER_ID, OBJECT_KEY, URL, CONTENT_TYPE, SIZE, SHA256, WIDTH, HEIGHT, RENDITIONS, CREATED_AT
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	args := []interface{}{
+		file.UserID,
+		file.ObjectKey,
+		file.URL,
+		file.ContentType,
+		file.Size,
+		file.SHA256,
+		file.Width,
+		file.Height,
+		file.Renditions,
+	}
+	return scanFile(stmt.QueryRowContext(ctx, args...))
+}
+
+func (s *FileRepository) GetByID(ctx context.Context, id uuid.UUID) (*types.FileRecord, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT ID, USER_ID, OBJECT_KEY, URL, CONTENT_TYPE, SIZE, SHA256, WIDTH, HEIGHT, RENDITIONS, CREATED_AT
+		FROM FILES WHERE ID = $1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	return scanFile(stmt.QueryRowContext(ctx, id))
+}
+
+// GetByURL returns the file of the user that is served at url.
+func (s *FileRepository) GetByURL(ctx context.Context, userID uuid.UUID, url string) (*types.FileRecord, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT ID, USER_ID, OBJECT_KEY, URL, CONTENT_TYPE, SIZE, SHA256, WIDTH, HEIGHT, RENDITIONS, CREATED_AT
+		FROM FILES WHERE USER_ID = $1 AND URL = $2
+		ORDER BY CREATED_AT DESC
+		LIMIT 1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	return scanFile(stmt.QueryRowContext(ctx, userID, url))
+}
+
+// GetByIDs returns the files with the given IDs in no particular order,
+// skipping ones that do not exist.
+func (s *FileRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]types.FileRecord, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT ID, USER_ID, OBJECT_KEY, URL, CONTENT_TYPE, SIZE, SHA256, WIDTH, HEIGHT, RENDITIONS, CREATED_AT
+		FROM FILES WHERE ID = ANY($1)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFiles(rows)
+}
+
+// GetByUser returns every file the user uploaded, attached to a post or not,
+// oldest first.
+func (s *FileRepository) GetByUser(ctx context.Context, userID uuid.UUID) ([]types.FileRecord, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT ID, USER_ID, OBJECT_KEY, URL, CONTENT_TYPE, SIZE, SHA256, WIDTH, HEIGHT, RENDITIONS, CREATED_AT
+		FROM FILES WHERE USER_ID = $1
+		ORDER BY CREATED_AT
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFiles(rows)
+}
+
+// Delete removes the file, which detaches it from every post it is on.
+func (s *FileRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		DELETE FROM FILES WHERE ID = $1
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrFileNotFound
+	}
+	return nil
+}
+
+func scanFile(row *sql.Row) (*types.FileRecord, error) {
+	var f types.FileRecord
+	err := row.Scan(
+		&f.ID,
+		&f.UserID,
+		&f.ObjectKey,
+		&f.URL,
+		&f.ContentType,
+		&f.Size,
+		&f.SHA256,
+		&f.Width,
+		&f.Height,
+		&f.Renditions,
+		&f.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repoerrs.ErrFileNotFound
+		}
+		return nil, err
+	}
+	return &f, nil
+}
+
+func scanFiles(rows *sql.Rows) ([]types.FileRecord, error) {
+	files := []types.FileRecord{}
+	for rows.Next() {
+		var f types.FileRecord
+		err := rows.Scan(
+			&f.ID,
+			&f.UserID,
+			&f.ObjectKey,
+			&f.URL,
+			&f.ContentType,
+			&f.Size,
+			&f.SHA256,
+			&f.Width,
+			&f.Height,
+			&f.Renditions,
+			&f.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
@@ -0,0 +1,249 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type FollowRepository struct {
+	db *sql.DB
+}
+
+func NewFollowRepository(db *sql.DB) *FollowRepository {
+	return &FollowRepository{
+		db: db,
+	}
+}
+
+func (s *FollowRepository) Create(ctx context.Context, followerID uuid.UUID, followeeID uuid.UUID) error {
+	if followerID == followeeID {
+		return repoerrs.ErrSelfFollow
+	}
+
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO FOLLOWS(FOLLOWER_ID, FOLLOWEE_ID) VALUES ($1, $2)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, followerID, followeeID); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505":
+				return repoerrs.ErrAlreadyFollowing
+			case "23503":
+				return repoerrs.ErrUserNotFound
+			case "23514":
+				return repoerrs.ErrSelfFollow
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FollowRepository) Delete(ctx context.Context, followerID uuid.UUID, followeeID uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		DELETE FROM FOLLOWS WHERE FOLLOWER_ID = $1 AND FOLLOWEE_ID = $2
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, followerID, followeeID)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrNotFollowing
+	}
+	return nil
+}
+
+func (s *FollowRepository) GetFollowers(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	return getUsersPage(ctx, s.db, `
+		SELECT U.*, F.CREATED_AT FROM FOLLOWS F
+		JOIN USERS U ON U.ID = F.FOLLOWER_ID
+		WHERE F.FOLLOWEE_ID = $1 AND U.DEACTIVATED_AT IS NULL
+		AND ($2::TIMESTAMP IS NULL OR (F.CREATED_AT, U.ID) < ($2, $3))
+		ORDER BY F.CREATED_AT DESC, U.ID DESC
+		LIMIT $4
+	`, userID, page)
+}
+
+func (s *FollowRepository) GetFollowing(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	return getUsersPage(ctx, s.db, `
+		SELECT U.*, F.CREATED_AT FROM FOLLOWS F
+		JOIN USERS U ON U.ID = F.FOLLOWEE_ID
+		WHERE F.FOLLOWER_ID = $1 AND U.DEACTIVATED_AT IS NULL
+		AND ($2::TIMESTAMP IS NULL OR (F.CREATED_AT, U.ID) < ($2, $3))
+		ORDER BY F.CREATED_AT DESC, U.ID DESC
+		LIMIT $4
+	`, userID, page)
+}
+
+// GetFollowerIDs returns the followers who should see posts of the user in
+// their feed, that is every follower who has not muted the user.
+func (s *FollowRepository) GetFollowerIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT FOLLOWER_ID FROM FOLLOWS WHERE FOLLOWEE_ID = $1
+		EXCEPT SELECT MUTER_ID FROM MUTES WHERE MUTED_ID = $1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (s *FollowRepository) IsFollowing(ctx context.Context, followerID uuid.UUID, followeeID uuid.UUID) (bool, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM FOLLOWS WHERE FOLLOWER_ID = $1 AND FOLLOWEE_ID = $2
+		)
+	`)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+
+	var following bool
+	if err := stmt.QueryRowContext(ctx, followerID, followeeID).Scan(&following); err != nil {
+		return false, err
+	}
+	return following, nil
+}
+
+func (s *FollowRepository) CreateRequest(ctx context.Context, requesterID uuid.UUID, targetID uuid.UUID) error {
+	if requesterID == targetID {
+		return repoerrs.ErrSelfFollow
+	}
+
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO FOLLOW_REQUESTS(REQUESTER_ID, TARGET_ID) VALUES ($1, $2)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, requesterID, targetID); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505":
+				return repoerrs.ErrAlreadyRequested
+			case "23503":
+				return repoerrs.ErrUserNotFound
+			case "23514":
+				return repoerrs.ErrSelfFollow
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FollowRepository) DeleteRequest(ctx context.Context, requesterID uuid.UUID, targetID uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		DELETE FROM FOLLOW_REQUESTS WHERE REQUESTER_ID = $1 AND TARGET_ID = $2
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, requesterID, targetID)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrFollowRequestNotFound
+	}
+	return nil
+}
+
+// ApproveRequest turns the follow request into a follow.
+func (s *FollowRepository) ApproveRequest(ctx context.Context, requesterID uuid.UUID, targetID uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		WITH REQUEST AS (
+			DELETE FROM FOLLOW_REQUESTS WHERE REQUESTER_ID = $1 AND TARGET_ID = $2
+			RETURNING REQUESTER_ID, TARGET_ID
+		)
+		INSERT INTO FOLLOWS(FOLLOWER_ID, FOLLOWEE_ID)
+		SELECT REQUESTER_ID, TARGET_ID FROM REQUEST
+		ON CONFLICT DO NOTHING
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, requesterID, targetID)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrFollowRequestNotFound
+	}
+	return nil
+}
+
+// GetRequests returns the users waiting for the user to approve their
+// follow request, newest request first.
+func (s *FollowRepository) GetRequests(ctx context.Context, targetID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	return getUsersPage(ctx, s.db, `
+		SELECT U.*, R.CREATED_AT FROM FOLLOW_REQUESTS R
+		JOIN USERS U ON U.ID = R.REQUESTER_ID
+		WHERE R.TARGET_ID = $1 AND U.DEACTIVATED_AT IS NULL
+		AND ($2::TIMESTAMP IS NULL OR (R.CREATED_AT, U.ID) < ($2, $3))
+		ORDER BY R.CREATED_AT DESC, U.ID DESC
+		LIMIT $4
+	`, targetID, page)
+}
+
+// Counts returns how many users follow the user and how many the user
+// follows. Deactivated users are not counted.
+func (s *FollowRepository) Counts(ctx context.Context, userID uuid.UUID) (followers int, following int, err error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM FOLLOWS F JOIN USERS U ON U.ID = F.FOLLOWER_ID
+				WHERE F.FOLLOWEE_ID = $1 AND U.DEACTIVATED_AT IS NULL),
+			(SELECT COUNT(*) FROM FOLLOWS F JOIN USERS U ON U.ID = F.FOLLOWEE_ID
+				WHERE F.FOLLOWER_ID = $1 AND U.DEACTIVATED_AT IS NULL)
+	`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stmt.Close()
+
+	if err := stmt.QueryRowContext(ctx, userID).Scan(&followers, &following); err != nil {
+		return 0, 0, err
+	}
+	return followers, following, nil
+}
@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/lib/pq"
+)
+
+type IdentityRepository struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) *IdentityRepository {
+	return &IdentityRepository{
+		db: db,
+	}
+}
+
+func (s *IdentityRepository) Create(ctx context.Context, input types.Identity) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO IDENTITIES(USER_ID, PROVIDER, SUBJECT, EMAIL)
+		VALUES ($1, $2, $3, $4)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := []interface{}{input.UserID, input.Provider, input.Subject, input.Email}
+	if _, err := stmt.ExecContext(ctx, args...); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return repoerrs.ErrIdentityAlreadyExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *IdentityRepository) GetByProviderSubject(ctx context.Context, provider, subject string) (*types.Identity, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			ID,
+			USER_ID,
+			PROVIDER,
+			SUBJECT,
+			EMAIL,
+			CREATED_AT
+		FROM IDENTITIES
+		WHERE PROVIDER = $1 AND SUBJECT = $2
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, provider, subject)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanIdentity(rows)
+	}
+	return nil, repoerrs.ErrIdentityNotFound
+}
+
+func (s *IdentityRepository) CreateState(ctx context.Context, input types.OIDCState) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO OIDC_STATES(STATE_HASH, PROVIDER, VERIFIER, NONCE, EXPIRES_AT)
+		VALUES ($1, $2, $3, $4, $5)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := []interface{}{input.StateHash, input.Provider, input.Verifier, input.Nonce, input.ExpiresAt}
+	if _, err := stmt.ExecContext(ctx, args...); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ConsumeState deletes the state and returns it, so a state can only finish
+// one login.
+func (s *IdentityRepository) ConsumeState(ctx context.Context, hash string) (*types.OIDCState, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		DELETE FROM OIDC_STATES WHERE STATE_HASH = $1
+		RETURNING STATE_HASH, PROVIDER, VERIFIER, NONCE, EXPIRES_AT
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var state types.OIDCState
+	err = stmt.QueryRowContext(ctx, hash).Scan(
+		&state.StateHash,
+		&state.Provider,
+		&state.Verifier,
+		&state.Nonce,
+		&state.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repoerrs.ErrOIDCStateNotFound
+		}
+		return nil, err
+	}
+	return &state, nil
+}
+
+func scanIdentity(rows *sql.Rows) (*types.Identity, error) {
+	var identity types.Identity
+	if err := rows.Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
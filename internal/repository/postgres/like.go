@@ -6,6 +6,7 @@ import (
 	"errors"
 
 	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
@@ -131,3 +132,27 @@ func (s *LikeRepository) IsCommentLiked(ctx context.Context, commentID uuid.UUID
 	}
 	return count != 0, nil
 }
+
+// GetPostLikers returns the users who liked the post, most recent like
+// first.
+func (s *LikeRepository) GetPostLikers(ctx context.Context, postID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	return getUsersPage(ctx, s.db, `
+		SELECT U.*, L.CREATED_AT FROM POST_LIKES L
+		JOIN USERS U ON U.ID = L.USER_ID
+		WHERE L.POST_ID = $1 AND U.DEACTIVATED_AT IS NULL
+		AND ($2::TIMESTAMP IS NULL OR (L.CREATED_AT, U.ID) < ($2, $3))
+		ORDER BY L.CREATED_AT DESC, U.ID DESC
+		LIMIT $4
+	`, postID, page)
+}
+
+func (s *LikeRepository) GetCommentLikers(ctx context.Context, commentID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	return getUsersPage(ctx, s.db, `
+		SELECT U.*, L.CREATED_AT FROM COMMENT_LIKES L
+		JOIN USERS U ON U.ID = L.USER_ID
+		WHERE L.COMMENT_ID = $1 AND U.DEACTIVATED_AT IS NULL
+		AND ($2::TIMESTAMP IS NULL OR (L.CREATED_AT, U.ID) < ($2, $3))
+		ORDER BY L.CREATED_AT DESC, U.ID DESC
+		LIMIT $4
+	`, commentID, page)
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+)
+
+type MagicLinkRepository struct {
+	db *sql.DB
+}
+
+func NewMagicLinkRepository(db *sql.DB) *MagicLinkRepository {
+	return &MagicLinkRepository{
+		db: db,
+	}
+}
+
+func (s *MagicLinkRepository) Create(ctx context.Context, input types.MagicLinkToken) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO MAGIC_LINK_TOKENS(USER_ID, TOKEN_HASH, EXPIRES_AT)
+		VALUES ($1, $2, $3)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := []interface{}{input.UserID, input.TokenHash, input.ExpiresAt}
+	if _, err := stmt.ExecContext(ctx, args...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *MagicLinkRepository) GetByHash(ctx context.Context, hash string) (*types.MagicLinkToken, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			ID,
+			USER_ID,
+			TOKEN_HASH,
+			EXPIRES_AT,
+			USED_AT,
+			CREATED_AT
+		FROM MAGIC_LINK_TOKENS
+		WHERE TOKEN_HASH = $1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var token types.MagicLinkToken
+	err = stmt.QueryRowContext(ctx, hash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repoerrs.ErrMagicLinkNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed consumes the token. It fails with ErrMagicLinkNotFound when the
+// token has already been used.
+func (s *MagicLinkRepository) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE MAGIC_LINK_TOKENS SET USED_AT = now()
+		WHERE ID = $1 AND USED_AT IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrMagicLinkNotFound
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/escoutdoor/social/internal/types"
+)
+
+type ModerationRepository struct {
+	db *sql.DB
+}
+
+func NewModerationRepository(db *sql.DB) *ModerationRepository {
+	return &ModerationRepository{
+		db: db,
+	}
+}
+
+// createModerationAction records an action in the transaction that carries
+// it out.
+func createModerationAction(ctx context.Context, tx *sql.Tx, input types.ModerationAction) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO MODERATION_ACTIONS(MODERATOR_ID, ACTION, TARGET_ID, TARGET_USER_ID)
+		VALUES ($1, $2, $3, $4)
+	`, input.ModeratorID, input.Action, input.TargetID, input.TargetUserID)
+	return err
+}
+
+func (s *ModerationRepository) GetAll(ctx context.Context) ([]types.ModerationAction, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			ID,
+			MODERATOR_ID,
+			ACTION,
+			TARGET_ID,
+			TARGET_USER_ID,
+			CREATED_AT
+		FROM MODERATION_ACTIONS
+		ORDER BY CREATED_AT DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []types.ModerationAction{}
+	for rows.Next() {
+		var a types.ModerationAction
+		if err := rows.Scan(
+			&a.ID,
+			&a.ModeratorID,
+			&a.Action,
+			&a.TargetID,
+			&a.TargetUserID,
+			&a.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
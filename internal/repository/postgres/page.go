@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+)
+
+// cursorArgs returns the query arguments of the keyset condition
+// `($n::TIMESTAMP IS NULL OR (CREATED_AT, ID) < ($n, $n+1))`, which holds for
+// every row when there is no cursor.
+func cursorArgs(page types.CursorPage) (*time.Time, uuid.UUID) {
+	if page.After == nil {
+		return nil, uuid.Nil
+	}
+	return &page.After.CreatedAt, page.After.ID
+}
+
+// getUsersPage runs a query listing users related to id, such as followers
+// or likers. The query takes id, the cursor and the limit as arguments and
+// selects every user column followed by the time the relation was created,
+// which is what the list is ordered by.
+func getUsersPage(ctx context.Context, db *sql.DB, query string, id uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return types.Page[types.User]{}, err
+	}
+	defer stmt.Close()
+
+	after, afterID := cursorArgs(page)
+	rows, err := stmt.QueryContext(ctx, id, after, afterID, page.Limit+1)
+	if err != nil {
+		return types.Page[types.User]{}, err
+	}
+	defer rows.Close()
+
+	var (
+		users   []types.User
+		cursors []types.Cursor
+	)
+	for rows.Next() {
+		var (
+			user      types.User
+			createdAt time.Time
+		)
+		if err := rows.Scan(append(userFields(&user), &createdAt)...); err != nil {
+			return types.Page[types.User]{}, err
+		}
+		users = append(users, user)
+		cursors = append(cursors, types.Cursor{CreatedAt: createdAt, ID: user.ID})
+	}
+	return types.NewPage(users, page.Limit, func(i int) types.Cursor {
+		return cursors[i]
+	}), nil
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+)
+
+type PasswordResetRepository struct {
+	db *sql.DB
+}
+
+func NewPasswordResetRepository(db *sql.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{
+		db: db,
+	}
+}
+
+func (s *PasswordResetRepository) Create(ctx context.Context, input types.PasswordResetToken) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO PASSWORD_RESET_TOKENS(USER_ID, TOKEN_HASH, EXPIRES_AT)
+		VALUES ($1, $2, $3)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := []interface{}{input.UserID, input.TokenHash, input.ExpiresAt}
+	if _, err := stmt.ExecContext(ctx, args...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *PasswordResetRepository) GetByHash(ctx context.Context, hash string) (*types.PasswordResetToken, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			ID,
+			USER_ID,
+			TOKEN_HASH,
+			EXPIRES_AT,
+			USED_AT,
+			CREATED_AT
+		FROM PASSWORD_RESET_TOKENS
+		WHERE TOKEN_HASH = $1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var token types.PasswordResetToken
+	err = stmt.QueryRowContext(ctx, hash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repoerrs.ErrResetTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed consumes the token. It fails with ErrResetTokenNotFound when the
+// token has already been used.
+func (s *PasswordResetRepository) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE PASSWORD_RESET_TOKENS SET USED_AT = now()
+		WHERE ID = $1 AND USED_AT IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrResetTokenNotFound
+	}
+	return nil
+}
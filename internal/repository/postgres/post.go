@@ -8,6 +8,7 @@ import (
 	"github.com/escoutdoor/social/internal/repository/repoerrs"
 	"github.com/escoutdoor/social/internal/types"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type PostRepository struct {
@@ -20,45 +21,199 @@ func NewPostRepository(db *sql.DB) *PostRepository {
 	}
 }
 
-func (s *PostRepository) Create(ctx context.Context, userID uuid.UUID, input types.CreatePostReq) (*types.Post, error) {
-	stmt, err := s.db.PrepareContext(ctx, `
-		INSERT INTO POSTS(CONTENT, USER_ID, PHOTO_URL) VALUES($1, $2, $3)
-		RETURNING ID, CONTENT, USER_ID, PHOTO_URL, CREATED_AT, UPDATED_AT
-	`)
+// Create stores the post with its media along with mentions of the given
+// lowercase usernames. Usernames nobody has and the author themselves are
+// skipped. A post without a visibility is public.
+func (s *PostRepository) Create(ctx context.Context, userID uuid.UUID, input types.CreatePostReq, mentions []string) (*types.Post, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	args := []interface{}{input.Content, userID, input.PhotoURL}
-	rows, err := stmt.QueryContext(ctx, args...)
+	var post types.Post
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO POSTS(CONTENT, USER_ID, VISIBILITY) VALUES($1, $2, COALESCE(NULLIF($3, ''), 'public'))
+		RETURNING ID, CONTENT, USER_ID, VISIBILITY, CREATED_AT, UPDATED_AT
+	`, input.Content, userID, input.Visibility).Scan(
+		&post.ID,
+		&post.Content,
+		&post.UserID,
+		&post.Visibility,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+	)
 	if err != nil {
 		return nil, err
 	}
+	if post.MentionIDs, err = setMentions(ctx, tx, post.ID, userID, mentions); err != nil {
+		return nil, err
+	}
+	media := make([]types.PostMedia, len(input.Media))
+	for i, m := range input.Media {
+		media[i] = types.PostMedia{FileID: m.FileID, AltText: m.AltText}
+	}
+	if err := setMedia(ctx, tx, post.ID, media); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
-	if rows.Next() {
-		return scanPost(rows)
+	posts, err := withMedia(ctx, s.db, []types.Post{post})
+	if err != nil {
+		return nil, err
 	}
-	return nil, repoerrs.ErrPostNotFound
+	return &posts[0], nil
 }
 
-func (s *PostRepository) Update(ctx context.Context, postID uuid.UUID, input types.Post) (*types.Post, error) {
-	stmt, err := s.db.PrepareContext(ctx, `
+// Update stores the content and visibility of the post and replaces its
+// media and mentions, see Create.
+func (s *PostRepository) Update(ctx context.Context, postID uuid.UUID, input types.Post, mentions []string) (*types.Post, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
 		UPDATE POSTS SET
 			CONTENT = $1,
-			PHOTO_URL = $2
+			VISIBILITY = $2
 		WHERE ID = $3
+	`, input.Content, input.Visibility, postID)
+	if err != nil {
+		return nil, err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return nil, repoerrs.ErrPostNotFound
+	}
+	mentionIDs, err := setMentions(ctx, tx, postID, input.UserID, mentions)
+	if err != nil {
+		return nil, err
+	}
+	if err := setMedia(ctx, tx, postID, input.Media); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	post, err := s.GetByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	post.MentionIDs = mentionIDs
+	return post, nil
+}
+
+// setMentions replaces the mentions of the post and returns the IDs of the
+// mentioned users.
+func setMentions(ctx context.Context, tx *sql.Tx, postID uuid.UUID, authorID uuid.UUID, usernames []string) ([]uuid.UUID, error) {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM POST_MENTIONS WHERE POST_ID = $1`, postID); err != nil {
+		return nil, err
+	}
+	ids := []uuid.UUID{}
+	if len(usernames) == 0 {
+		return ids, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		INSERT INTO POST_MENTIONS(POST_ID, USER_ID)
+		SELECT $1, ID FROM USERS WHERE LOWER(USERNAME) = ANY($2) AND ID != $3
+		RETURNING USER_ID
+	`, postID, pq.Array(usernames), authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// setMedia replaces the media of the post, keeping their order.
+func setMedia(ctx context.Context, tx *sql.Tx, postID uuid.UUID, media []types.PostMedia) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM POST_MEDIA WHERE POST_ID = $1`, postID); err != nil {
+		return err
+	}
+	for i, m := range media {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO POST_MEDIA(POST_ID, FILE_ID, POSITION, ALT_TEXT) VALUES ($1, $2, $3, $4)
+		`, postID, m.FileID, i, m.AltText); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withMedia fills in the media of the posts.
+func withMedia(ctx context.Context, db *sql.DB, posts []types.Post) ([]types.Post, error) {
+	if len(posts) == 0 {
+		return posts, nil
+	}
+	ids := make([]uuid.UUID, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+
+	stmt, err := db.PrepareContext(ctx, `
+		SELECT m.POST_ID, f.ID, f.URL, f.RENDITIONS, m.ALT_TEXT
+		FROM POST_MEDIA m
+		JOIN FILES f ON f.ID = m.FILE_ID
+		WHERE m.POST_ID = ANY($1)
+		ORDER BY m.POSITION
 	`)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	args := []interface{}{input.Content, input.PhotoURL, postID}
-	if _, err = stmt.ExecContext(ctx, args...); err != nil {
+	rows, err := stmt.QueryContext(ctx, pq.Array(ids))
+	if err != nil {
 		return nil, err
 	}
-	return s.GetByID(ctx, postID)
+	defer rows.Close()
+
+	media := make(map[uuid.UUID][]types.PostMedia, len(posts))
+	for rows.Next() {
+		var (
+			postID uuid.UUID
+			m      types.PostMedia
+		)
+		if err := rows.Scan(&postID, &m.FileID, &m.URL, &m.Renditions, &m.AltText); err != nil {
+			return nil, err
+		}
+		media[postID] = append(media[postID], m)
+	}
+	for i := range posts {
+		posts[i].Media = media[posts[i].ID]
+		if posts[i].Media == nil {
+			posts[i].Media = []types.PostMedia{}
+		}
+	}
+	return posts, nil
+}
+
+// IsMentioned reports whether the post mentions the user.
+func (s *PostRepository) IsMentioned(ctx context.Context, postID uuid.UUID, userID uuid.UUID) (bool, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM POST_MENTIONS WHERE POST_ID = $1 AND USER_ID = $2)
+	`)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+
+	var mentioned bool
+	err = stmt.QueryRowContext(ctx, postID, userID).Scan(&mentioned)
+	return mentioned, err
 }
 
 func (s *PostRepository) GetByID(ctx context.Context, id uuid.UUID) (*types.Post, error) {
@@ -67,13 +222,14 @@ func (s *PostRepository) GetByID(ctx context.Context, id uuid.UUID) (*types.Post
 			p.ID,
 			p.CONTENT,
 			p.USER_ID,
-			p.PHOTO_URL,
+			p.VISIBILITY,
 			COUNT(l.ID) as LIKES,
 			p.UPDATED_AT,
 			p.CREATED_AT
 		FROM POSTS p 
+		JOIN USERS u ON p.USER_ID = u.ID
 		LEFT JOIN POST_LIKES l ON p.ID = l.POST_ID
-		WHERE p.ID = $1
+		WHERE p.ID = $1 AND u.DEACTIVATED_AT IS NULL
 		GROUP BY p.ID
 	`)
 	if err != nil {
@@ -86,7 +242,7 @@ func (s *PostRepository) GetByID(ctx context.Context, id uuid.UUID) (*types.Post
 		&post.ID,
 		&post.Content,
 		&post.UserID,
-		&post.PhotoURL,
+		&post.Visibility,
 		&post.Likes,
 		&post.CreatedAt,
 		&post.UpdatedAt,
@@ -97,83 +253,249 @@ func (s *PostRepository) GetByID(ctx context.Context, id uuid.UUID) (*types.Post
 		}
 		return nil, err
 	}
-	return &post, err
+
+	posts, err := withMedia(ctx, s.db, []types.Post{post})
+	if err != nil {
+		return nil, err
+	}
+	return &posts[0], nil
 }
 
-func (s *PostRepository) GetAll(ctx context.Context) ([]types.Post, error) {
+// GetAll returns the posts the viewer may see, newest first. Posts of private
+// accounts are only listed for the author and their followers, posts of
+// deactivated accounts not at all. On top of that, followers-only posts take
+// following the author and mentioned-only ones being mentioned.
+func (s *PostRepository) GetAll(ctx context.Context, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.Post], error) {
 	stmt, err := s.db.PrepareContext(ctx, `
-		SELECT 
+		SELECT
 			p.ID,
 			p.CONTENT,
 			p.USER_ID,
-			p.PHOTO_URL,
+			p.VISIBILITY,
 			COUNT(l.ID) AS LIKES,
 			p.CREATED_AT,
 			p.UPDATED_AT
 		FROM POSTS p
+		JOIN USERS u ON p.USER_ID = u.ID
 		LEFT JOIN POST_LIKES l ON p.ID = l.POST_ID
+		LEFT JOIN FOLLOWS f ON f.FOLLOWER_ID = $4 AND f.FOLLOWEE_ID = p.USER_ID
+		WHERE (p.USER_ID = $4 OR (
+			(NOT u.IS_PRIVATE OR f.FOLLOWER_ID IS NOT NULL) AND (
+				p.VISIBILITY = 'public'
+				OR (p.VISIBILITY = 'followers' AND f.FOLLOWER_ID IS NOT NULL)
+				OR (p.VISIBILITY = 'mentioned' AND EXISTS (
+					SELECT 1 FROM POST_MENTIONS m WHERE m.POST_ID = p.ID AND m.USER_ID = $4
+				))
+			)
+		))
+		AND u.DEACTIVATED_AT IS NULL
+		AND ($1::TIMESTAMP IS NULL OR (p.CREATED_AT, p.ID) < ($1, $2))
 		GROUP BY p.ID
-		ORDER BY p.CREATED_AT
+		ORDER BY p.CREATED_AT DESC, p.ID DESC
+		LIMIT $3
+	`)
+	if err != nil {
+		return types.Page[types.Post]{}, err
+	}
+	defer stmt.Close()
+
+	after, afterID := cursorArgs(page)
+	rows, err := stmt.QueryContext(ctx, after, afterID, page.Limit+1, viewerID)
+	if err != nil {
+		return types.Page[types.Post]{}, err
+	}
+	defer rows.Close()
+
+	posts, err := scanPostsWithLikes(rows)
+	if err != nil {
+		return types.Page[types.Post]{}, err
+	}
+	if posts, err = withMedia(ctx, s.db, posts); err != nil {
+		return types.Page[types.Post]{}, err
+	}
+	return types.NewPage(posts, page.Limit, func(i int) types.Cursor {
+		return posts[i].Cursor()
+	}), nil
+}
+
+// GetFeed returns posts of the user and of the accounts the user follows and
+// has not muted, newest first. Mentioned-only posts are left out unless they
+// mention the user.
+func (s *PostRepository) GetFeed(ctx context.Context, userID uuid.UUID, page types.CursorPage) ([]types.Post, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			p.ID,
+			p.CONTENT,
+			p.USER_ID,
+			p.VISIBILITY,
+			COUNT(l.ID) AS LIKES,
+			p.CREATED_AT,
+			p.UPDATED_AT
+		FROM POSTS p
+		JOIN USERS u ON p.USER_ID = u.ID
+		LEFT JOIN POST_LIKES l ON p.ID = l.POST_ID
+		WHERE (p.USER_ID = $1 OR p.USER_ID IN (
+			SELECT FOLLOWEE_ID FROM FOLLOWS WHERE FOLLOWER_ID = $1
+			EXCEPT SELECT MUTED_ID FROM MUTES WHERE MUTER_ID = $1
+		))
+		AND (p.USER_ID = $1 OR p.VISIBILITY != 'mentioned' OR EXISTS (
+			SELECT 1 FROM POST_MENTIONS m WHERE m.POST_ID = p.ID AND m.USER_ID = $1
+		))
+		AND u.DEACTIVATED_AT IS NULL
+		AND ($2::TIMESTAMP IS NULL OR (p.CREATED_AT, p.ID) < ($2, $3))
+		GROUP BY p.ID
+		ORDER BY p.CREATED_AT DESC, p.ID DESC
+		LIMIT $4
 	`)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx)
+	after, afterID := cursorArgs(page)
+	rows, err := stmt.QueryContext(ctx, userID, after, afterID, page.Limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var posts []types.Post
-	for rows.Next() {
-		var p types.Post
-		err = rows.Scan(
-			&p.ID,
-			&p.Content,
-			&p.UserID,
-			&p.PhotoURL,
-			&p.Likes,
-			&p.CreatedAt,
-			&p.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
+	posts, err := scanPostsWithLikes(rows)
+	if err != nil {
+		return nil, err
+	}
+	return withMedia(ctx, s.db, posts)
+}
+
+// GetByIDs returns the posts in the order of ids, skipping ones that no
+// longer exist, whose author is deactivated or that are mentioned-only and do
+// not mention the viewer.
+func (s *PostRepository) GetByIDs(ctx context.Context, ids []uuid.UUID, viewerID uuid.UUID) ([]types.Post, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			p.ID,
+			p.CONTENT,
+			p.USER_ID,
+			p.VISIBILITY,
+			COUNT(l.ID) AS LIKES,
+			p.CREATED_AT,
+			p.UPDATED_AT
+		FROM POSTS p
+		JOIN USERS u ON p.USER_ID = u.ID
+		LEFT JOIN POST_LIKES l ON p.ID = l.POST_ID
+		WHERE p.ID = ANY($1) AND u.DEACTIVATED_AT IS NULL
+		AND (p.USER_ID = $2 OR p.VISIBILITY != 'mentioned' OR EXISTS (
+			SELECT 1 FROM POST_MENTIONS m WHERE m.POST_ID = p.ID AND m.USER_ID = $2
+		))
+		GROUP BY p.ID
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, pq.Array(ids), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found, err := scanPostsWithLikes(rows)
+	if err != nil {
+		return nil, err
+	}
+	if found, err = withMedia(ctx, s.db, found); err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]types.Post, len(found))
+	for _, p := range found {
+		byID[p.ID] = p
+	}
+	posts := make([]types.Post, 0, len(found))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			posts = append(posts, p)
 		}
-		posts = append(posts, p)
 	}
 	return posts, nil
 }
 
-func (s *PostRepository) Delete(ctx context.Context, id uuid.UUID) error {
+// GetRecentByUser returns the newest posts of the user.
+func (s *PostRepository) GetRecentByUser(ctx context.Context, userID uuid.UUID, limit int) ([]types.Post, error) {
 	stmt, err := s.db.PrepareContext(ctx, `
-		DELETE FROM POSTS WHERE ID = $1
+		SELECT
+			p.ID,
+			p.CONTENT,
+			p.USER_ID,
+			p.VISIBILITY,
+			COUNT(l.ID) AS LIKES,
+			p.CREATED_AT,
+			p.UPDATED_AT
+		FROM POSTS p
+		LEFT JOIN POST_LIKES l ON p.ID = l.POST_ID
+		WHERE p.USER_ID = $1
+		GROUP BY p.ID
+		ORDER BY p.CREATED_AT DESC, p.ID DESC
+		LIMIT $2
 	`)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer stmt.Close()
 
-	res, err := stmt.ExecContext(ctx, id)
+	rows, err := stmt.QueryContext(ctx, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts, err := scanPostsWithLikes(rows)
+	if err != nil {
+		return nil, err
+	}
+	return withMedia(ctx, s.db, posts)
+}
+
+// Delete removes the post. A moderation action, if given, is recorded along
+// with it, so a post is never removed by a moderator without a trace.
+func (s *PostRepository) Delete(ctx context.Context, id uuid.UUID, moderation *types.ModerationAction) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM POSTS WHERE ID = $1`, id)
 	if err != nil {
 		return err
 	}
 	if ra, _ := res.RowsAffected(); ra == 0 {
 		return repoerrs.ErrPostNotFound
 	}
-	return nil
+	if moderation != nil {
+		if err := createModerationAction(ctx, tx, *moderation); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-func scanPost(rows *sql.Rows) (*types.Post, error) {
-	var post types.Post
-	err := rows.Scan(
-		&post.ID,
-		&post.Content,
-		&post.UserID,
-		&post.PhotoURL,
-		&post.CreatedAt,
-		&post.UpdatedAt,
-	)
-	return &post, err
+func scanPostsWithLikes(rows *sql.Rows) ([]types.Post, error) {
+	posts := []types.Post{}
+	for rows.Next() {
+		var p types.Post
+		err := rows.Scan(
+			&p.ID,
+			&p.Content,
+			&p.UserID,
+			&p.Visibility,
+			&p.Likes,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, nil
 }
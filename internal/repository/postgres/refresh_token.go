@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+)
+
+type RefreshTokenRepository struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenRepository(db *sql.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		db: db,
+	}
+}
+
+func (s *RefreshTokenRepository) Create(ctx context.Context, input types.RefreshToken) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO REFRESH_TOKENS(SESSION_ID, TOKEN_HASH, EXPIRES_AT)
+		VALUES ($1, $2, $3)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := []interface{}{input.SessionID, input.TokenHash, input.ExpiresAt}
+	if _, err := stmt.ExecContext(ctx, args...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *RefreshTokenRepository) GetByHash(ctx context.Context, hash string) (*types.RefreshToken, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			ID,
+			SESSION_ID,
+			TOKEN_HASH,
+			EXPIRES_AT,
+			USED_AT,
+			CREATED_AT
+		FROM REFRESH_TOKENS
+		WHERE TOKEN_HASH = $1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanRefreshToken(rows)
+	}
+	return nil, repoerrs.ErrRefreshTokenNotFound
+}
+
+// MarkUsed flags the token as rotated. It fails with ErrRefreshTokenNotFound
+// when the token has already been used, so concurrent refreshes of the same
+// token are detected as reuse.
+func (s *RefreshTokenRepository) MarkUsed(ctx context.Context, id uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE REFRESH_TOKENS SET USED_AT = now()
+		WHERE ID = $1 AND USED_AT IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrRefreshTokenNotFound
+	}
+	return nil
+}
+
+func scanRefreshToken(rows *sql.Rows) (*types.RefreshToken, error) {
+	var token types.RefreshToken
+	if err := rows.Scan(
+		&token.ID,
+		&token.SessionID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+)
+
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{
+		db: db,
+	}
+}
+
+func (s *SessionRepository) Create(ctx context.Context, userID uuid.UUID, meta types.SessionMeta) (uuid.UUID, error) {
+	var id uuid.UUID
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO SESSIONS(USER_ID, DEVICE, USER_AGENT, IP)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ID
+	`)
+	if err != nil {
+		return id, err
+	}
+	defer stmt.Close()
+
+	args := []interface{}{userID, nullString(meta.Device), nullString(meta.UserAgent), nullString(meta.IP)}
+	if err := stmt.QueryRowContext(ctx, args...).Scan(&id); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+func (s *SessionRepository) GetByID(ctx context.Context, id uuid.UUID) (*types.Session, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			ID,
+			USER_ID,
+			DEVICE,
+			USER_AGENT,
+			IP,
+			LAST_SEEN_AT,
+			REVOKED_AT,
+			CREATED_AT
+		FROM SESSIONS
+		WHERE ID = $1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanSession(rows)
+	}
+	return nil, repoerrs.ErrSessionNotFound
+}
+
+func (s *SessionRepository) GetAll(ctx context.Context, userID uuid.UUID) ([]types.Session, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT
+			ID,
+			USER_ID,
+			DEVICE,
+			USER_AGENT,
+			IP,
+			LAST_SEEN_AT,
+			REVOKED_AT,
+			CREATED_AT
+		FROM SESSIONS
+		WHERE USER_ID = $1 AND REVOKED_AT IS NULL
+		ORDER BY LAST_SEEN_AT DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []types.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, nil
+}
+
+func (s *SessionRepository) Touch(ctx context.Context, id uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE SESSIONS SET LAST_SEEN_AT = now() WHERE ID = $1
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SessionRepository) Revoke(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE SESSIONS SET REVOKED_AT = now()
+		WHERE ID = $1 AND USER_ID = $2 AND REVOKED_AT IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeAll revokes every active session of the user except the one with
+// exceptID. Pass uuid.Nil to revoke all of them.
+func (s *SessionRepository) RevokeAll(ctx context.Context, userID uuid.UUID, exceptID uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE SESSIONS SET REVOKED_AT = now()
+		WHERE USER_ID = $1 AND ID <> $2 AND REVOKED_AT IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, userID, exceptID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func scanSession(rows *sql.Rows) (*types.Session, error) {
+	var session types.Session
+	if err := rows.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.Device,
+		&session.UserAgent,
+		&session.IP,
+		&session.LastSeenAt,
+		&session.RevokedAt,
+		&session.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
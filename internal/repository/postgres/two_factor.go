@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+)
+
+type TwoFactorRepository struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepository(db *sql.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{
+		db: db,
+	}
+}
+
+// Upsert stores a new secret for the user. Two-factor authentication stays
+// disabled until Enable is called.
+func (s *TwoFactorRepository) Upsert(ctx context.Context, userID uuid.UUID, secret string) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		INSERT INTO USER_TOTP(USER_ID, SECRET) VALUES ($1, $2)
+		ON CONFLICT (USER_ID) DO UPDATE SET
+			SECRET = EXCLUDED.SECRET,
+			ENABLED_AT = NULL,
+			LAST_USED_STEP = NULL,
+			CREATED_AT = now()
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, userID, secret); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *TwoFactorRepository) GetByUserID(ctx context.Context, userID uuid.UUID) (*types.TwoFactor, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT USER_ID, SECRET, ENABLED_AT, CREATED_AT FROM USER_TOTP WHERE USER_ID = $1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var tf types.TwoFactor
+	err = stmt.QueryRowContext(ctx, userID).Scan(
+		&tf.UserID,
+		&tf.Secret,
+		&tf.EnabledAt,
+		&tf.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repoerrs.ErrTwoFactorNotFound
+		}
+		return nil, err
+	}
+	return &tf, nil
+}
+
+// UseStep records that a code of the time step was accepted. It fails with
+// ErrTOTPStepUsed when a code of that step or a later one was accepted
+// before.
+func (s *TwoFactorRepository) UseStep(ctx context.Context, userID uuid.UUID, step uint64) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE USER_TOTP SET LAST_USED_STEP = $2
+		WHERE USER_ID = $1 AND (LAST_USED_STEP IS NULL OR LAST_USED_STEP < $2)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, userID, int64(step))
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrTOTPStepUsed
+	}
+	return nil
+}
+
+// Enable turns two-factor authentication on and replaces the recovery codes
+// of the user with the given hashes.
+func (s *TwoFactorRepository) Enable(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE USER_TOTP SET ENABLED_AT = now() WHERE USER_ID = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrTwoFactorNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM RECOVERY_CODES WHERE USER_ID = $1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO RECOVERY_CODES(USER_ID, CODE_HASH) VALUES ($1, $2)
+		`, userID, hash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *TwoFactorRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM RECOVERY_CODES WHERE USER_ID = $1`, userID); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM USER_TOTP WHERE USER_ID = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrTwoFactorNotFound
+	}
+	return tx.Commit()
+}
+
+func (s *TwoFactorRepository) GetRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]types.RecoveryCode, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT ID, USER_ID, CODE_HASH, USED_AT FROM RECOVERY_CODES
+		WHERE USER_ID = $1 AND USED_AT IS NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []types.RecoveryCode
+	for rows.Next() {
+		var c types.RecoveryCode
+		if err := rows.Scan(&c.ID, &c.UserID, &c.CodeHash, &c.UsedAt); err != nil {
+			return nil, err
+		}
+		codes = append(codes, c)
+	}
+	return codes, nil
+}
+
+func (s *TwoFactorRepository) UseRecoveryCode(ctx context.Context, id uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE RECOVERY_CODES SET USED_AT = now() WHERE ID = $1 AND USED_AT IS NULL
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrRecoveryCodeNotFound
+	}
+	return nil
+}
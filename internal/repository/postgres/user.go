@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/escoutdoor/social/internal/repository/repoerrs"
@@ -64,6 +65,200 @@ func (s *UserRepository) GetByEmail(ctx context.Context, email string) (*types.U
 	return nil, repoerrs.ErrUserNotFound
 }
 
+func (s *UserRepository) GetByUsername(ctx context.Context, username string) (*types.User, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT * FROM USERS WHERE LOWER(USERNAME) = LOWER($1)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanUser(rows)
+	}
+	return nil, repoerrs.ErrUserNotFound
+}
+
+// GetByOldUsername returns the user who gave up the username in a rename, as
+// long as the redirect has not expired yet.
+func (s *UserRepository) GetByOldUsername(ctx context.Context, username string) (*types.User, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT U.* FROM USERNAME_HISTORY H
+		JOIN USERS U ON U.ID = H.USER_ID
+		WHERE LOWER(H.USERNAME) = LOWER($1) AND H.EXPIRES_AT > now()
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanUser(rows)
+	}
+	return nil, repoerrs.ErrUserNotFound
+}
+
+// UpdateUsername renames the user. The old username keeps redirecting to the
+// user until redirectUntil and cannot be claimed by anyone else before then.
+// A change of letter case only is not recorded.
+func (s *UserRepository) UpdateUsername(ctx context.Context, id uuid.UUID, username string, redirectUntil time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var old string
+	err = tx.QueryRowContext(ctx, `SELECT USERNAME FROM USERS WHERE ID = $1 FOR UPDATE`, id).Scan(&old)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return repoerrs.ErrUserNotFound
+		}
+		return err
+	}
+
+	var reserved bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM USERNAME_HISTORY
+			WHERE LOWER(USERNAME) = LOWER($1) AND USER_ID != $2 AND EXPIRES_AT > now()
+		)
+	`, username, id).Scan(&reserved)
+	if err != nil {
+		return err
+	}
+	if reserved {
+		return repoerrs.ErrUsernameTaken
+	}
+	// the user may take back an old username of their own, and expired
+	// redirects are free for anyone
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM USERNAME_HISTORY WHERE LOWER(USERNAME) = LOWER($1)
+	`, username); err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(old, username) {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO USERNAME_HISTORY(USERNAME, USER_ID, EXPIRES_AT) VALUES ($1, $2, $3)
+		`, old, id, redirectUntil); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE USERS SET USERNAME = $1, USERNAME_CHANGED_AT = now(), UPDATED_AT = now()
+		WHERE ID = $2
+	`, username, id)
+	if err != nil {
+		var errPq *pq.Error
+		if errors.As(err, &errPq) && errPq.Code == "23505" {
+			return repoerrs.ErrUsernameTaken
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// Search finds users whose name or username starts with the query or is
+// close to it in trigram similarity. Prefix matches rank above fuzzy ones and
+// ties go to the user with more followers. Deactivated users and users who
+// share a block with the viewer are left out.
+//
+// The cursor only carries the ID of the last user of the previous page, whose
+// rank is computed again, so the next page is empty if that user no longer
+// matches.
+func (s *UserRepository) Search(ctx context.Context, query string, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		WITH MATCHES AS (
+			SELECT U.*,
+				GREATEST(
+					similarity(LOWER(U.FIRST_NAME), $1),
+					similarity(LOWER(U.LAST_NAME), $1),
+					similarity(LOWER(U.FIRST_NAME || ' ' || U.LAST_NAME), $1),
+					similarity(LOWER(U.USERNAME), $1)
+				) + CASE WHEN
+					LOWER(U.FIRST_NAME) LIKE $2 OR
+					LOWER(U.LAST_NAME) LIKE $2 OR
+					LOWER(U.FIRST_NAME || ' ' || U.LAST_NAME) LIKE $2 OR
+					LOWER(U.USERNAME) LIKE $2
+				THEN 1 ELSE 0 END AS RANK,
+				(SELECT COUNT(*) FROM FOLLOWS F WHERE F.FOLLOWEE_ID = U.ID) AS FOLLOWERS
+			FROM USERS U
+			WHERE (
+				LOWER(U.FIRST_NAME) % $1 OR LOWER(U.FIRST_NAME) LIKE $2 OR
+				LOWER(U.LAST_NAME) % $1 OR LOWER(U.LAST_NAME) LIKE $2 OR
+				LOWER(U.FIRST_NAME || ' ' || U.LAST_NAME) % $1 OR
+				LOWER(U.FIRST_NAME || ' ' || U.LAST_NAME) LIKE $2 OR
+				LOWER(U.USERNAME) % $1 OR LOWER(U.USERNAME) LIKE $2
+			)
+			AND U.DEACTIVATED_AT IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM BLOCKS B
+				WHERE (B.BLOCKER_ID = $3 AND B.BLOCKED_ID = U.ID)
+				OR (B.BLOCKER_ID = U.ID AND B.BLOCKED_ID = $3)
+			)
+		)
+		SELECT M.* FROM MATCHES M
+		WHERE $4::UUID IS NULL
+		OR (M.RANK, M.FOLLOWERS, M.ID) < (SELECT RANK, FOLLOWERS, ID FROM MATCHES WHERE ID = $4)
+		ORDER BY M.RANK DESC, M.FOLLOWERS DESC, M.ID DESC
+		LIMIT $5
+	`)
+	if err != nil {
+		return types.Page[types.User]{}, err
+	}
+	defer stmt.Close()
+
+	var after *uuid.UUID
+	if page.After != nil {
+		after = &page.After.ID
+	}
+	query = strings.ToLower(query)
+	rows, err := stmt.QueryContext(ctx, query, escapeLike(query)+"%", viewerID, after, page.Limit+1)
+	if err != nil {
+		return types.Page[types.User]{}, err
+	}
+	defer rows.Close()
+
+	var users []types.User
+	for rows.Next() {
+		var (
+			user      types.User
+			rank      float64
+			followers int
+		)
+		if err := rows.Scan(append(userFields(&user), &rank, &followers)...); err != nil {
+			return types.Page[types.User]{}, err
+		}
+		user.FollowersCount = &followers
+		users = append(users, user)
+	}
+	return types.NewPage(users, page.Limit, func(i int) types.Cursor {
+		return types.Cursor{CreatedAt: users[i].CreatedAt, ID: users[i].ID}
+	}), nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes s match itself literally in a LIKE pattern.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
 func (s *UserRepository) Update(ctx context.Context, input types.User) (*types.User, error) {
 	stmt, err := s.db.PrepareContext(ctx, `
 		UPDATE USERS SET
@@ -73,8 +268,11 @@ func (s *UserRepository) Update(ctx context.Context, input types.User) (*types.U
 			PASSWORD = $4,
 			DATE_OF_BIRTH = $5,
 			BIO = $6,
-			AVATAR_URL = $7
-		WHERE ID = $8
+			AVATAR_URL = $7,
+			AVATAR_RENDITIONS = $8,
+			PENDING_EMAIL = $9,
+			IS_PRIVATE = $10
+		WHERE ID = $11
 	`)
 	if err != nil {
 		return nil, err
@@ -94,6 +292,9 @@ func (s *UserRepository) Update(ctx context.Context, input types.User) (*types.U
 		dob,
 		input.Bio,
 		input.AvatarURL,
+		input.AvatarRenditions,
+		input.PendingEmail,
+		input.IsPrivate,
 		input.ID,
 	}
 	_, err = stmt.ExecContext(ctx, args...)
@@ -107,6 +308,146 @@ func (s *UserRepository) Update(ctx context.Context, input types.User) (*types.U
 	return s.GetByID(ctx, input.ID)
 }
 
+func (s *UserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, password string) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE USERS SET PASSWORD = $1, UPDATED_AT = now() WHERE ID = $2
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, password, id)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrUserNotFound
+	}
+	return nil
+}
+
+// ConfirmEmail makes email the verified address of the user. The pending
+// email change is cleared only when it is to email, so confirming the
+// current address leaves a change in flight alone.
+func (s *UserRepository) ConfirmEmail(ctx context.Context, id uuid.UUID, email string) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE USERS SET
+			EMAIL = $1,
+			EMAIL_VERIFIED_AT = now(),
+			PENDING_EMAIL = NULLIF(PENDING_EMAIL, $1),
+			UPDATED_AT = now()
+		WHERE ID = $2
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, email, id)
+	if err != nil {
+		var errPq *pq.Error
+		if errors.As(err, &errPq) && errPq.Code == "23505" {
+			return repoerrs.ErrEmailAlreadyExists
+		}
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *UserRepository) UpdateRole(ctx context.Context, id uuid.UUID, role types.Role) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE USERS SET ROLE = $1, UPDATED_AT = now() WHERE ID = $2
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, role, id)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrUserNotFound
+	}
+	return nil
+}
+
+// Deactivate marks the account as deleted and revokes all of its sessions.
+// The rows stay until the account is purged.
+func (s *UserRepository) Deactivate(ctx context.Context, id uuid.UUID) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE USERS SET DEACTIVATED_AT = now() WHERE ID = $1 AND DEACTIVATED_AT IS NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+	if ra, _ := res.RowsAffected(); ra == 0 {
+		return repoerrs.ErrUserNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE SESSIONS SET REVOKED_AT = now() WHERE USER_ID = $1 AND REVOKED_AT IS NULL
+	`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *UserRepository) Reactivate(ctx context.Context, id uuid.UUID) error {
+	stmt, err := s.db.PrepareContext(ctx, `
+		UPDATE USERS SET DEACTIVATED_AT = NULL WHERE ID = $1 AND DEACTIVATED_AT IS NOT NULL
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, id)
+	return err
+}
+
+// GetDeactivated returns up to limit users deactivated before the given
+// time, longest deactivated first.
+func (s *UserRepository) GetDeactivated(ctx context.Context, before time.Time, limit int) ([]types.User, error) {
+	stmt, err := s.db.PrepareContext(ctx, `
+		SELECT * FROM USERS WHERE DEACTIVATED_AT < $1
+		ORDER BY DEACTIVATED_AT
+		LIMIT $2
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []types.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, nil
+}
+
+// Delete removes the user for good together with everything the user
+// created.
 func (s *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	stmt, err := s.db.PrepareContext(ctx, `
 		DELETE FROM USERS WHERE ID = $1
@@ -117,6 +458,9 @@ func (s *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	defer stmt.Close()
 
 	result, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return err
+	}
 	if v, _ := result.RowsAffected(); v == 0 {
 		return repoerrs.ErrUserNotFound
 	}
@@ -125,7 +469,16 @@ func (s *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 
 func scanUser(rows *sql.Rows) (*types.User, error) {
 	var user types.User
-	if err := rows.Scan(
+	if err := rows.Scan(userFields(&user)...); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// userFields returns the scan destinations of the USERS columns in table
+// order.
+func userFields(user *types.User) []interface{} {
+	return []interface{}{
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
@@ -136,9 +489,13 @@ func scanUser(rows *sql.Rows) (*types.User, error) {
 		&user.AvatarURL,
 		&user.UpdatedAt,
 		&user.CreatedAt,
-	); err != nil {
-		return nil, err
+		&user.EmailVerifiedAt,
+		&user.PendingEmail,
+		&user.Role,
+		&user.IsPrivate,
+		&user.Username,
+		&user.UsernameChangedAt,
+		&user.DeactivatedAt,
+		&user.AvatarRenditions,
 	}
-	return &user, nil
-
 }
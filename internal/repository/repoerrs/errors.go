@@ -8,9 +8,42 @@ var (
 	ErrUserNotFound       = errors.New("user not found")
 	ErrUserAlreadyExists  = errors.New("user already exists")
 	ErrEmailAlreadyExists = errors.New("user with this email address already exists")
+	ErrUsernameTaken      = errors.New("username is already taken")
+
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrResetTokenNotFound   = errors.New("password reset token not found")
+	ErrVerifyTokenNotFound  = errors.New("email verification token not found")
+	ErrMagicLinkNotFound    = errors.New("magic link not found")
+	ErrTwoFactorNotFound    = errors.New("two-factor authentication is not set up")
+	ErrRecoveryCodeNotFound = errors.New("recovery code not found")
+	ErrTOTPStepUsed         = errors.New("totp code was already used")
+	ErrAPIKeyNotFound       = errors.New("api key not found")
+
+	ErrIdentityNotFound      = errors.New("identity not found")
+	ErrIdentityAlreadyExists = errors.New("identity is already linked to a user")
+	ErrOIDCStateNotFound     = errors.New("oidc state not found")
+
+	ErrSelfFollow       = errors.New("users cannot follow themselves")
+	ErrAlreadyFollowing = errors.New("already following this user")
+	ErrNotFollowing     = errors.New("not following this user")
+
+	ErrAlreadyRequested      = errors.New("follow request already sent")
+	ErrFollowRequestNotFound = errors.New("follow request not found")
+
+	ErrSelfBlock      = errors.New("users cannot block themselves")
+	ErrAlreadyBlocked = errors.New("user is already blocked")
+	ErrNotBlocked     = errors.New("user is not blocked")
+	ErrSelfMute       = errors.New("users cannot mute themselves")
+	ErrAlreadyMuted   = errors.New("user is already muted")
+	ErrNotMuted       = errors.New("user is not muted")
 
 	ErrPostNotFound = errors.New("post not found")
 
+	ErrFileNotFound = errors.New("file not found")
+
+	ErrExportNotFound = errors.New("export not found")
+
 	ErrCommentNotFound = errors.New("comment not found")
 
 	ErrLikeFailed       = errors.New("failed to like")
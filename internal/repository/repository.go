@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/escoutdoor/social/internal/repository/postgres"
 	"github.com/escoutdoor/social/internal/types"
@@ -13,18 +14,127 @@ type Auth interface {
 	Create(ctx context.Context, input types.CreateUserReq) (uuid.UUID, error)
 }
 
+type RefreshToken interface {
+	Create(ctx context.Context, input types.RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*types.RefreshToken, error)
+	MarkUsed(ctx context.Context, id uuid.UUID) error
+}
+
+type Session interface {
+	Create(ctx context.Context, userID uuid.UUID, meta types.SessionMeta) (uuid.UUID, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*types.Session, error)
+	GetAll(ctx context.Context, userID uuid.UUID) ([]types.Session, error)
+	Touch(ctx context.Context, id uuid.UUID) error
+	Revoke(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	RevokeAll(ctx context.Context, userID uuid.UUID, exceptID uuid.UUID) error
+}
+
+type APIKey interface {
+	Create(ctx context.Context, input types.APIKey) (*types.APIKey, error)
+	GetByHash(ctx context.Context, hash string) (*types.APIKey, error)
+	GetAll(ctx context.Context, userID uuid.UUID) ([]types.APIKey, error)
+	Touch(ctx context.Context, id uuid.UUID) error
+	Revoke(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+}
+
+type Identity interface {
+	Create(ctx context.Context, input types.Identity) error
+	GetByProviderSubject(ctx context.Context, provider, subject string) (*types.Identity, error)
+	CreateState(ctx context.Context, input types.OIDCState) error
+	ConsumeState(ctx context.Context, hash string) (*types.OIDCState, error)
+}
+
+type PasswordReset interface {
+	Create(ctx context.Context, input types.PasswordResetToken) error
+	GetByHash(ctx context.Context, hash string) (*types.PasswordResetToken, error)
+	MarkUsed(ctx context.Context, id uuid.UUID) error
+}
+
+type MagicLink interface {
+	Create(ctx context.Context, input types.MagicLinkToken) error
+	GetByHash(ctx context.Context, hash string) (*types.MagicLinkToken, error)
+	MarkUsed(ctx context.Context, id uuid.UUID) error
+}
+
+type EmailVerification interface {
+	Create(ctx context.Context, input types.EmailVerificationToken) error
+	GetByHash(ctx context.Context, hash string) (*types.EmailVerificationToken, error)
+	MarkUsed(ctx context.Context, id uuid.UUID) error
+}
+
+type TwoFactor interface {
+	Upsert(ctx context.Context, userID uuid.UUID, secret string) error
+	GetByUserID(ctx context.Context, userID uuid.UUID) (*types.TwoFactor, error)
+	UseStep(ctx context.Context, userID uuid.UUID, step uint64) error
+	Enable(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	Delete(ctx context.Context, userID uuid.UUID) error
+	GetRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]types.RecoveryCode, error)
+	UseRecoveryCode(ctx context.Context, id uuid.UUID) error
+}
+
 type User interface {
 	GetByID(ctx context.Context, id uuid.UUID) (*types.User, error)
 	GetByEmail(ctx context.Context, email string) (*types.User, error)
+	GetByUsername(ctx context.Context, username string) (*types.User, error)
+	GetByOldUsername(ctx context.Context, username string) (*types.User, error)
+	Search(ctx context.Context, query string, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
 	Update(ctx context.Context, input types.User) (*types.User, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, password string) error
+	UpdateUsername(ctx context.Context, id uuid.UUID, username string, redirectUntil time.Time) error
+	ConfirmEmail(ctx context.Context, id uuid.UUID, email string) error
+	UpdateRole(ctx context.Context, id uuid.UUID, role types.Role) error
+	Deactivate(ctx context.Context, id uuid.UUID) error
+	Reactivate(ctx context.Context, id uuid.UUID) error
+	GetDeactivated(ctx context.Context, before time.Time, limit int) ([]types.User, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
+type Follow interface {
+	Create(ctx context.Context, followerID uuid.UUID, followeeID uuid.UUID) error
+	Delete(ctx context.Context, followerID uuid.UUID, followeeID uuid.UUID) error
+	GetFollowers(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
+	GetFollowing(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
+	GetFollowerIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	IsFollowing(ctx context.Context, followerID uuid.UUID, followeeID uuid.UUID) (bool, error)
+	CreateRequest(ctx context.Context, requesterID uuid.UUID, targetID uuid.UUID) error
+	DeleteRequest(ctx context.Context, requesterID uuid.UUID, targetID uuid.UUID) error
+	ApproveRequest(ctx context.Context, requesterID uuid.UUID, targetID uuid.UUID) error
+	GetRequests(ctx context.Context, targetID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
+	Counts(ctx context.Context, userID uuid.UUID) (followers int, following int, err error)
+}
+
+type Block interface {
+	Block(ctx context.Context, blockerID uuid.UUID, blockedID uuid.UUID) error
+	Unblock(ctx context.Context, blockerID uuid.UUID, blockedID uuid.UUID) error
+	IsBlocked(ctx context.Context, userID uuid.UUID, otherID uuid.UUID) (bool, error)
+	GetBlocked(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
+	Mute(ctx context.Context, muterID uuid.UUID, mutedID uuid.UUID) error
+	Unmute(ctx context.Context, muterID uuid.UUID, mutedID uuid.UUID) error
+	GetMuted(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
+}
+
+type Moderation interface {
+	GetAll(ctx context.Context) ([]types.ModerationAction, error)
+}
+
 type Post interface {
-	Create(ctx context.Context, userID uuid.UUID, input types.CreatePostReq) (*types.Post, error)
-	Update(ctx context.Context, postID uuid.UUID, input types.Post) (*types.Post, error)
+	Create(ctx context.Context, userID uuid.UUID, input types.CreatePostReq, mentions []string) (*types.Post, error)
+	Update(ctx context.Context, postID uuid.UUID, input types.Post, mentions []string) (*types.Post, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*types.Post, error)
-	GetAll(ctx context.Context) ([]types.Post, error)
+	IsMentioned(ctx context.Context, postID uuid.UUID, userID uuid.UUID) (bool, error)
+	GetAll(ctx context.Context, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.Post], error)
+	GetFeed(ctx context.Context, userID uuid.UUID, page types.CursorPage) ([]types.Post, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID, viewerID uuid.UUID) ([]types.Post, error)
+	GetRecentByUser(ctx context.Context, userID uuid.UUID, limit int) ([]types.Post, error)
+	Delete(ctx context.Context, id uuid.UUID, moderation *types.ModerationAction) error
+}
+
+type File interface {
+	Create(ctx context.Context, file types.FileRecord) (*types.FileRecord, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*types.FileRecord, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]types.FileRecord, error)
+	GetByURL(ctx context.Context, userID uuid.UUID, url string) (*types.FileRecord, error)
+	GetByUser(ctx context.Context, userID uuid.UUID) ([]types.FileRecord, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
@@ -35,29 +145,68 @@ type Like interface {
 	LikeComment(ctx context.Context, commentID uuid.UUID, userID uuid.UUID) error
 	RemoveLikeFromPost(ctx context.Context, postID uuid.UUID, userID uuid.UUID) error
 	RemoveLikeFromComment(ctx context.Context, commentID uuid.UUID, userID uuid.UUID) error
+	GetPostLikers(ctx context.Context, postID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
+	GetCommentLikers(ctx context.Context, commentID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
 }
 
 type Comment interface {
 	Create(ctx context.Context, userID uuid.UUID, postID uuid.UUID, input types.CreateCommentReq) (uuid.UUID, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*types.Comment, error)
-	GetAll(ctx context.Context, postID uuid.UUID) ([]types.Comment, error)
-	Delete(ctx context.Context, id uuid.UUID) error
+	GetAll(ctx context.Context, postID uuid.UUID, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.Comment], error)
+	Delete(ctx context.Context, id uuid.UUID, moderation *types.ModerationAction) error
+}
+
+type Export interface {
+	Create(ctx context.Context, userID uuid.UUID) (*types.DataExport, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*types.DataExport, error)
+	GetPending(ctx context.Context, userID uuid.UUID, since time.Time) (*types.DataExport, error)
+	Complete(ctx context.Context, id uuid.UUID, objectKey string, expiresAt time.Time) error
+	Fail(ctx context.Context, id uuid.UUID) error
+	GetPosts(ctx context.Context, userID uuid.UUID) ([]types.Post, error)
+	GetComments(ctx context.Context, userID uuid.UUID) ([]types.Comment, error)
+	GetLikes(ctx context.Context, userID uuid.UUID) ([]types.ExportedLike, error)
 }
 
 func New(db *sql.DB) *Repository {
 	return &Repository{
-		Auth:    postgres.NewAuthRepository(db),
-		User:    postgres.NewUserRepository(db),
-		Post:    postgres.NewPostRepository(db),
-		Like:    postgres.NewLikeRepository(db),
-		Comment: postgres.NewCommentRepository(db),
+		Auth:              postgres.NewAuthRepository(db),
+		RefreshToken:      postgres.NewRefreshTokenRepository(db),
+		Session:           postgres.NewSessionRepository(db),
+		PasswordReset:     postgres.NewPasswordResetRepository(db),
+		EmailVerification: postgres.NewEmailVerificationRepository(db),
+		MagicLink:         postgres.NewMagicLinkRepository(db),
+		TwoFactor:         postgres.NewTwoFactorRepository(db),
+		APIKey:            postgres.NewAPIKeyRepository(db),
+		Identity:          postgres.NewIdentityRepository(db),
+		User:              postgres.NewUserRepository(db),
+		Follow:            postgres.NewFollowRepository(db),
+		Block:             postgres.NewBlockRepository(db),
+		Post:              postgres.NewPostRepository(db),
+		File:              postgres.NewFileRepository(db),
+		Like:              postgres.NewLikeRepository(db),
+		Comment:           postgres.NewCommentRepository(db),
+		Moderation:        postgres.NewModerationRepository(db),
+		Export:            postgres.NewExportRepository(db),
 	}
 }
 
 type Repository struct {
 	Auth
+	RefreshToken
+	Session
+	PasswordReset
+	EmailVerification
+	MagicLink
+	TwoFactor
+	APIKey
+	Identity
 	User
+	Follow
+	Block
 	Post
+	File
 	Like
 	Comment
+	Moderation
+	Export
 }
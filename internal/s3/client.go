@@ -3,6 +3,10 @@ package s3
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/escoutdoor/social/internal/types"
@@ -18,14 +22,14 @@ func (m *MinIOClient) generateUrl(id string) string {
 }
 
 func (m *MinIOClient) Create(file types.File) (string, error) {
-	id := uuid.New().String()
+	id := uuid.New().String() + file.Ext
 	if _, err := m.mc.PutObject(
 		context.Background(),
 		m.MinIOBucketName,
 		id,
 		file.Payload,
 		file.Size,
-		minio.PutObjectOptions{ContentType: "image/png"},
+		minio.PutObjectOptions{ContentType: file.ContentType},
 	); err != nil {
 		return "", err
 	}
@@ -56,3 +60,68 @@ func (m *MinIOClient) Delete(id string) error {
 	}
 	return nil
 }
+
+func (m *MinIOClient) ObjectID(rawURL string) (string, bool) {
+	id, ok := strings.CutPrefix(rawURL, m.generateUrl(""))
+	if !ok || id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+	return id, true
+}
+
+// Open returns the content of an object in the media bucket.
+func (m *MinIOClient) Open(ctx context.Context, id string) (io.ReadCloser, error) {
+	obj, err := m.mc.GetObject(ctx, m.MinIOBucketName, id, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy, a missing object only shows up on the first request
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (m *MinIOClient) PutExport(ctx context.Context, key string, src io.Reader, size int64) error {
+	_, err := m.mc.PutObject(ctx, m.MinIOExportBucketName, key, src, size, minio.PutObjectOptions{
+		ContentType: "application/zip",
+	})
+	return err
+}
+
+// ExportURL returns a presigned download URL of an export that stops working
+// after ttl, which cannot be longer than seven days.
+func (m *MinIOClient) ExportURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("response-content-disposition", fmt.Sprintf("attachment; filename=%q", path.Base(key)))
+	u, err := m.mc.PresignedGetObject(ctx, m.MinIOExportBucketName, key, ttl, params)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// RemoveExports deletes every export whose key starts with prefix.
+func (m *MinIOClient) RemoveExports(ctx context.Context, prefix string) error {
+	// stops the listing when returning early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	objects := m.mc.ListObjects(ctx, m.MinIOExportBucketName, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	})
+	for obj := range objects {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		if err := m.mc.RemoveObject(ctx, m.MinIOExportBucketName, obj.Key, minio.RemoveObjectOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
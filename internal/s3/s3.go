@@ -2,7 +2,10 @@ package s3
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/escoutdoor/social/internal/types"
 	"github.com/minio/minio-go/v7"
@@ -16,18 +19,30 @@ type MinIOClient struct {
 
 type Opts struct {
 	MinIOBucketName string
-	MinIOEndpoint   string
-	MinIOHost       string
-	MinIOUser       string
-	MinIOPw         string
-	MinIOUseSSL     bool
-	MinIORegion     string
+	// MinIOExportBucketName holds data exports. Unlike the media bucket it
+	// is private, exports are only handed out through presigned URLs.
+	MinIOExportBucketName string
+	MinIOEndpoint         string
+	MinIOHost             string
+	MinIOUser             string
+	MinIOPw               string
+	MinIOUseSSL           bool
+	MinIORegion           string
 }
 
+var ErrObjectNotFound = errors.New("object not found")
+
 type Repository interface {
 	Create(file types.File) (string, error)
 	Delete(id string) error
 	GetByID(id string) (string, error)
+	// ObjectID returns the ID of the object a URL returned by Create points
+	// at, or false if the URL is not one of ours.
+	ObjectID(url string) (string, bool)
+	Open(ctx context.Context, id string) (io.ReadCloser, error)
+	PutExport(ctx context.Context, key string, src io.Reader, size int64) error
+	ExportURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+	RemoveExports(ctx context.Context, prefix string) error
 }
 
 func New(opts Opts) (*MinIOClient, error) {
@@ -74,5 +89,15 @@ func New(opts Opts) (*MinIOClient, error) {
 			return nil, fmt.Errorf("error client.SetBucketPolicy: %w", err)
 		}
 	}
+
+	ie, err = client.BucketExists(ctx, opts.MinIOExportBucketName)
+	if err != nil {
+		return nil, err
+	}
+	if !ie {
+		if err := client.MakeBucket(ctx, opts.MinIOExportBucketName, minio.MakeBucketOptions{}); err != nil {
+			return nil, err
+		}
+	}
 	return &MinIOClient{mc: client, Opts: opts}, nil
 }
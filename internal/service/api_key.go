@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/hasher"
+	"github.com/google/uuid"
+)
+
+const (
+	// apiKeyPrefixLen is how much of the key is kept in clear to help users
+	// tell their keys apart.
+	apiKeyPrefixLen = len(types.APIKeyPrefix) + 6
+
+	// apiKeyTouchInterval limits how often last used time of a key is written
+	// back to the database.
+	apiKeyTouchInterval = time.Minute
+)
+
+type APIKeyService struct {
+	repo repository.APIKey
+}
+
+func NewAPIKeyService(repo repository.APIKey) *APIKeyService {
+	return &APIKeyService{
+		repo: repo,
+	}
+}
+
+func (s *APIKeyService) Create(ctx context.Context, userID uuid.UUID, input types.CreateAPIKeyReq) (*types.CreatedAPIKey, error) {
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidExpiry
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key := types.APIKeyPrefix + token
+
+	scopes := slices.Clone(input.Scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	created, err := s.repo.Create(ctx, types.APIKey{
+		UserID:    userID,
+		Name:      input.Name,
+		Prefix:    key[:apiKeyPrefixLen],
+		TokenHash: hasher.HashToken(key),
+		Scopes:    scopes,
+		ExpiresAt: input.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &types.CreatedAPIKey{APIKey: *created, Key: key}, nil
+}
+
+func (s *APIKeyService) GetAll(ctx context.Context, userID uuid.UUID) ([]types.APIKey, error) {
+	return s.repo.GetAll(ctx, userID)
+}
+
+func (s *APIKeyService) Revoke(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	return s.repo.Revoke(ctx, id, userID)
+}
+
+// Authenticate resolves an API key sent as a bearer token.
+func (s *APIKeyService) Authenticate(ctx context.Context, key string) (*types.APIKey, error) {
+	apiKey, err := s.repo.GetByHash(ctx, hasher.HashToken(key))
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrAPIKeyNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if apiKey.RevokedAt != nil {
+		return nil, ErrInvalidToken
+	}
+	if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	if apiKey.LastUsedAt == nil || time.Since(*apiKey.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.repo.Touch(ctx, apiKey.ID); err != nil {
+			return nil, err
+		}
+	}
+	return apiKey, nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/testutils"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+type apiKeyServiceSuite struct {
+	suite.Suite
+	container testcontainers.Container
+	svc       APIKey
+	authSvc   Auth
+}
+
+func (st *apiKeyServiceSuite) SetupSuite() {
+	container, db, err := testutils.NewPostgresContainer()
+	st.Require().NoError(err, "failed to run postgres container")
+	st.Require().NotEmpty(container, "expected to get postgres container")
+	st.Require().NotEmpty(db, "expected to get db connection")
+
+	repo := repository.New(db)
+
+	st.container = container
+	st.svc = NewAPIKeyService(repo.APIKey)
+	st.authSvc = newAuthService(repo, NewMemoryMailer())
+}
+
+func (st *apiKeyServiceSuite) TearDownSuite() {
+	err := st.container.Terminate(context.Background())
+	st.Require().NoError(err, "failed to terminate postgres container")
+}
+
+func (st *apiKeyServiceSuite) TestCreateAuthenticateRevoke() {
+	ctx := context.Background()
+	userID := signUp(st.T(), ctx, st.authSvc)
+
+	in := types.CreateAPIKeyReq{
+		Name:   "ci bot",
+		Scopes: []string{types.ScopePostsWrite, types.ScopeLikesWrite, types.ScopePostsWrite},
+	}
+	created, err := st.svc.Create(ctx, userID, in)
+	st.Require().NoError(err, "failed to create api key")
+	st.True(strings.HasPrefix(created.Key, types.APIKeyPrefix), "expected key to have the api key prefix")
+	st.True(strings.HasPrefix(created.Key, created.Prefix), "expected prefix to match the key")
+	st.Equal([]string{types.ScopeLikesWrite, types.ScopePostsWrite}, created.Scopes, "expected deduplicated scopes")
+
+	apiKey, err := st.svc.Authenticate(ctx, created.Key)
+	st.Require().NoError(err, "failed to authenticate api key")
+	st.Equal(userID, apiKey.UserID)
+	st.True(apiKey.HasScope(types.ScopePostsWrite), "expected key to have posts:write")
+	st.False(apiKey.HasScope(types.ScopeUsersWrite), "expected key not to have users:write")
+
+	keys, err := st.svc.GetAll(ctx, userID)
+	st.Require().NoError(err, "failed to get api keys")
+	st.Require().Len(keys, 1)
+	st.NotNil(keys[0].LastUsedAt, "expected last used time to be set")
+
+	err = st.svc.Revoke(ctx, uuid.New(), created.ID)
+	st.ErrorIs(err, repoerrs.ErrAPIKeyNotFound, "expected other users not to revoke the key")
+
+	err = st.svc.Revoke(ctx, userID, created.ID)
+	st.Require().NoError(err, "failed to revoke api key")
+
+	_, err = st.svc.Authenticate(ctx, created.Key)
+	st.ErrorIs(err, ErrInvalidToken, "expected revoked key to be rejected")
+}
+
+func (st *apiKeyServiceSuite) TestExpiry() {
+	ctx := context.Background()
+	userID := signUp(st.T(), ctx, st.authSvc)
+
+	past := time.Now().Add(-time.Minute)
+	_, err := st.svc.Create(ctx, userID, types.CreateAPIKeyReq{
+		Name:      "expired",
+		Scopes:    []string{types.ScopePostsWrite},
+		ExpiresAt: &past,
+	})
+	st.ErrorIs(err, ErrInvalidExpiry)
+
+	_, err = st.svc.Authenticate(ctx, types.APIKeyPrefix+gofakeit.UUID())
+	st.ErrorIs(err, ErrInvalidToken, "expected unknown key to be rejected")
+}
+
+func TestAPIKeyService(t *testing.T) {
+	suite.Run(t, new(apiKeyServiceSuite))
+}
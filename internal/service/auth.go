@@ -2,29 +2,101 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/escoutdoor/social/internal/repository"
 	"github.com/escoutdoor/social/internal/repository/repoerrs"
 	"github.com/escoutdoor/social/internal/types"
 	"github.com/escoutdoor/social/pkg/hasher"
+	"github.com/escoutdoor/social/pkg/jwtkeys"
+	"github.com/escoutdoor/social/pkg/oidc"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+const (
+	accessTokenTTL   = time.Minute * 15
+	refreshTokenTTL  = time.Hour * 24 * 30
+	resetPasswordTTL = time.Hour
+
+	// sessionTouchInterval limits how often last seen time of a session is
+	// written back to the database.
+	sessionTouchInterval = time.Minute
+)
+
 type AuthService struct {
-	repo     repository.Auth
-	userRepo repository.User
-	signKey  string
+	repo          repository.Auth
+	userRepo      repository.User
+	tokenRepo     repository.RefreshToken
+	sessionRepo   repository.Session
+	resetRepo     repository.PasswordReset
+	magicLinkRepo repository.MagicLink
+	twoFactorRepo repository.TwoFactor
+	identityRepo  repository.Identity
+	providers     map[string]*oidc.Provider
+	guard         *LoginGuard
+	verifier      *EmailVerifier
+	mailer        Mailer
+	keys          *jwtkeys.KeySet
+	appURL        string
+	totpIssuer    string
+	deletionGrace time.Duration
 }
 
-func NewAuthService(repo repository.Auth, userRepo repository.User, signKey string) *AuthService {
+type AuthServiceOpts struct {
+	Repo          repository.Auth
+	UserRepo      repository.User
+	TokenRepo     repository.RefreshToken
+	SessionRepo   repository.Session
+	ResetRepo     repository.PasswordReset
+	MagicLinkRepo repository.MagicLink
+	TwoFactorRepo repository.TwoFactor
+	IdentityRepo  repository.Identity
+	// Providers are the OpenID Connect providers users can sign in with,
+	// keyed by the name used in the URL.
+	Providers map[string]*oidc.Provider
+	// Guard limits failed sign in attempts. Leaving it nil disables the
+	// limits.
+	Guard    *LoginGuard
+	Verifier *EmailVerifier
+	Mailer   Mailer
+
+	// Keys signs access tokens with the active key and verifies tokens
+	// signed by any of the retired ones.
+	Keys *jwtkeys.KeySet
+	// AppURL is the base URL of the client application used to build links
+	// sent by email.
+	AppURL string
+	// TOTPIssuer is the name authenticator apps show next to the account.
+	TOTPIssuer string
+	// DeletionGrace is how long a deleted account is restored by signing in.
+	// After that sign in fails until the account is purged.
+	DeletionGrace time.Duration
+}
+
+func NewAuthService(opts AuthServiceOpts) *AuthService {
 	return &AuthService{
-		repo:     repo,
-		userRepo: userRepo,
-		signKey:  signKey,
+		repo:          opts.Repo,
+		userRepo:      opts.UserRepo,
+		tokenRepo:     opts.TokenRepo,
+		sessionRepo:   opts.SessionRepo,
+		resetRepo:     opts.ResetRepo,
+		magicLinkRepo: opts.MagicLinkRepo,
+		twoFactorRepo: opts.TwoFactorRepo,
+		identityRepo:  opts.IdentityRepo,
+		providers:     opts.Providers,
+		guard:         opts.Guard,
+		verifier:      opts.Verifier,
+		mailer:        opts.Mailer,
+		keys:          opts.Keys,
+		appURL:        opts.AppURL,
+		totpIssuer:    opts.TOTPIssuer,
+		deletionGrace: opts.DeletionGrace,
 	}
 }
 
@@ -35,43 +107,315 @@ func (s *AuthService) SignUp(ctx context.Context, input types.CreateUserReq) (uu
 		return uuid.Nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	return s.repo.Create(ctx, input)
+	id, err := s.repo.Create(ctx, input)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// the account is usable without confirmation, so a failed mail must not
+	// fail the sign up; the user can ask for the link again
+	if err := s.verifier.Send(ctx, id, input.Email); err != nil {
+		slog.Error("AuthService.SignUp - EmailVerifier.Send", "error", err)
+	}
+	return id, nil
+}
+
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	vt, err := s.verifier.Consume(ctx, token)
+	if err != nil {
+		return err
+	}
+	user, err := s.userRepo.GetByID(ctx, vt.UserID)
+	if err != nil {
+		return err
+	}
+
+	// the token is only good for the address it was sent to, and only while
+	// that address is still the current or the pending one
+	isCurrent := user.Email == vt.Email
+	isPending := user.PendingEmail != nil && *user.PendingEmail == vt.Email
+	if !isCurrent && !isPending {
+		return ErrInvalidToken
+	}
+	return s.userRepo.ConfirmEmail(ctx, user.ID, vt.Email)
+}
+
+func (s *AuthService) ResendVerification(ctx context.Context, user types.User) error {
+	switch {
+	case user.PendingEmail != nil:
+		return s.verifier.Send(ctx, user.ID, *user.PendingEmail)
+	case !user.IsEmailVerified():
+		return s.verifier.Send(ctx, user.ID, user.Email)
+	default:
+		return ErrEmailAlreadyVerified
+	}
 }
 
-func (s *AuthService) SignIn(ctx context.Context, input types.LoginReq) (string, error) {
+// SignIn checks the credentials and opens a session. When the account has
+// two-factor authentication enabled, only a challenge token is returned and
+// the session is opened by VerifyTwoFactor.
+func (s *AuthService) SignIn(ctx context.Context, input types.LoginReq, meta types.SessionMeta) (*types.SignInResult, error) {
+	if err := s.guard.Check(ctx, input.Email, meta.IP); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetByEmail(ctx, input.Email)
 	if err != nil {
 		if errors.Is(err, repoerrs.ErrUserNotFound) {
-			return "", ErrInvalidEmailOrPw
+			return nil, s.failSignIn(ctx, input.Email, meta.IP)
 		}
-		return "", err
+		return nil, err
 	}
 
 	if ok := hasher.ComparePw(input.Password, user.Password); !ok {
-		return "", ErrInvalidEmailOrPw
+		return nil, s.failSignIn(ctx, input.Email, meta.IP)
+	}
+	if err := s.guard.Succeed(ctx, input.Email); err != nil {
+		return nil, err
 	}
-	token, err := s.generateToken(ctx, user.ID)
+	return s.completeSignIn(ctx, user.ID, meta)
+}
+
+// completeSignIn opens a session for a user whose first factor was checked,
+// or hands out a two-factor challenge when the account requires it.
+func (s *AuthService) completeSignIn(ctx context.Context, userID uuid.UUID, meta types.SessionMeta) (*types.SignInResult, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if user.IsDeactivated() && time.Since(*user.DeactivatedAt) >= s.deletionGrace {
+		return nil, ErrAccountDeleted
+	}
+
+	enabled, err := s.isTwoFactorEnabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		challenge, err := s.generateChallengeToken(userID)
+		if err != nil {
+			return nil, err
+		}
+		return &types.SignInResult{TwoFactorRequired: true, ChallengeToken: challenge}, nil
+	}
+
+	tokens, err := s.startSession(ctx, userID, meta)
+	if err != nil {
+		return nil, err
+	}
+	return &types.SignInResult{Tokens: tokens}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. Every refresh token
+// can be used only once: presenting an already rotated token is treated as
+// theft and revokes the whole session it belongs to.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*types.Tokens, error) {
+	rt, err := s.tokenRepo.GetByHash(ctx, hasher.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrRefreshTokenNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	session, err := s.sessionRepo.GetByID(ctx, rt.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, ErrSessionRevoked
+	}
+	if rt.UsedAt != nil {
+		return nil, s.revokeReusedSession(ctx, session)
+	}
+	if time.Now().After(rt.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.tokenRepo.MarkUsed(ctx, rt.ID); err != nil {
+		if errors.Is(err, repoerrs.ErrRefreshTokenNotFound) {
+			return nil, s.revokeReusedSession(ctx, session)
+		}
+		return nil, err
+	}
+	if err := s.sessionRepo.Touch(ctx, session.ID); err != nil {
+		return nil, err
+	}
+	return s.issueTokens(ctx, session.UserID, session.ID)
+}
+
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	rt, err := s.tokenRepo.GetByHash(ctx, hasher.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrRefreshTokenNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+	session, err := s.sessionRepo.GetByID(ctx, rt.SessionID)
+	if err != nil {
+		return err
+	}
+
+	err = s.sessionRepo.Revoke(ctx, session.ID, session.UserID)
+	if err != nil && !errors.Is(err, repoerrs.ErrSessionNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (s *AuthService) GetSessions(ctx context.Context, userID uuid.UUID, currentSessionID uuid.UUID) ([]types.Session, error) {
+	sessions, err := s.sessionRepo.GetAll(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+func (s *AuthService) RevokeSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) error {
+	return s.sessionRepo.Revoke(ctx, sessionID, userID)
+}
+
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, userID uuid.UUID, currentSessionID uuid.UUID) error {
+	return s.sessionRepo.RevokeAll(ctx, userID, currentSessionID)
+}
+
+// ForgotPassword emails a password reset link to the user. It does not report
+// whether the email belongs to an account.
+func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	err = s.resetRepo.Create(ctx, types.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hasher.HashToken(token),
+		ExpiresAt: time.Now().Add(resetPasswordTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, Mail{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Use the link below to set a new password. It expires in %s.\n\n%s/reset-password?token=%s\n",
+			resetPasswordTTL, s.appURL, token,
+		),
+	})
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword and
+// revokes all sessions of the user.
+func (s *AuthService) ResetPassword(ctx context.Context, input types.ResetPasswordReq) error {
+	rt, err := s.resetRepo.GetByHash(ctx, hasher.HashToken(input.Token))
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrResetTokenNotFound) {
+			return ErrInvalidToken
+		}
+		return err
 	}
-	return token, nil
+	if rt.UsedAt != nil || time.Now().After(rt.ExpiresAt) {
+		return ErrInvalidToken
+	}
+	if err := s.resetRepo.MarkUsed(ctx, rt.ID); err != nil {
+		if errors.Is(err, repoerrs.ErrResetTokenNotFound) {
+			return ErrInvalidToken
+		}
+		return err
+	}
+
+	password, err := hasher.HashPw(input.Password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := s.userRepo.UpdatePassword(ctx, rt.UserID, password); err != nil {
+		return err
+	}
+	return s.sessionRepo.RevokeAll(ctx, rt.UserID, uuid.Nil)
+}
+
+func (s *AuthService) revokeReusedSession(ctx context.Context, session *types.Session) error {
+	if err := s.sessionRepo.Revoke(ctx, session.ID, session.UserID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return ErrRefreshTokenReused
+}
+
+// failSignIn records the failed attempt and returns the error to report.
+// Unknown emails are counted too, so they can't be told apart from wrong
+// passwords by the lockout behaviour.
+func (s *AuthService) failSignIn(ctx context.Context, email, ip string) error {
+	if err := s.guard.Fail(ctx, email, ip); err != nil {
+		return err
+	}
+	return ErrInvalidEmailOrPw
+}
+
+// startSession opens a session for the user, which also restores a deleted
+// account that is still in its grace period.
+func (s *AuthService) startSession(ctx context.Context, userID uuid.UUID, meta types.SessionMeta) (*types.Tokens, error) {
+	if err := s.userRepo.Reactivate(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to reactivate user: %w", err)
+	}
+	sessionID, err := s.sessionRepo.Create(ctx, userID, meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return s.issueTokens(ctx, userID, sessionID)
+}
+
+func (s *AuthService) issueTokens(ctx context.Context, userID, sessionID uuid.UUID) (*types.Tokens, error) {
+	accessToken, err := s.generateToken(ctx, userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	err = s.tokenRepo.Create(ctx, types.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: hasher.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
 }
 
 type AuthTokenClaims struct {
-	UserID uuid.UUID `json:"user_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	SessionID uuid.UUID `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func (s *AuthService) generateToken(_ context.Context, userID uuid.UUID) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &AuthTokenClaims{
+func (s *AuthService) generateToken(_ context.Context, userID, sessionID uuid.UUID) (string, error) {
+	tokenStr, err := s.keys.Sign(&AuthTokenClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 	})
-
-	tokenStr, err := token.SignedString([]byte(s.signKey))
 	if err != nil {
 		return "", err
 	}
@@ -79,29 +423,53 @@ func (s *AuthService) generateToken(_ context.Context, userID uuid.UUID) (string
 	return tokenStr, nil
 }
 
-func (s *AuthService) ParseToken(jwtToken string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(jwtToken, &AuthTokenClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-		}
+// JWKS returns the public keys other services can use to verify access
+// tokens.
+func (s *AuthService) JWKS() jwtkeys.JWKS {
+	return s.keys.JWKS()
+}
 
-		return []byte(s.signKey), nil
-	})
+func (s *AuthService) ParseToken(ctx context.Context, jwtToken string) (*AuthTokenClaims, error) {
+	token, err := jwt.ParseWithClaims(jwtToken, &AuthTokenClaims{}, s.keys.Keyfunc)
 	if err != nil {
 		switch {
 		case errors.Is(err, jwt.ErrTokenMalformed):
-			return uuid.Nil, fmt.Errorf("it doesn't look like a token")
+			return nil, fmt.Errorf("it doesn't look like a token")
 		case errors.Is(err, jwt.ErrTokenSignatureInvalid):
-			return uuid.Nil, fmt.Errorf("invalid token signature")
+			return nil, fmt.Errorf("invalid token signature")
 		case errors.Is(err, jwt.ErrTokenExpired) || errors.Is(err, jwt.ErrTokenNotValidYet):
-			return uuid.Nil, fmt.Errorf("token is either expired or not active yet")
+			return nil, fmt.Errorf("token is either expired or not active yet")
 		default:
-			return uuid.Nil, ErrInvalidToken
+			return nil, ErrInvalidToken
 		}
 	}
 	claims, ok := token.Claims.(*AuthTokenClaims)
-	if !ok {
-		return uuid.Nil, err
+	if !ok || len(claims.Audience) != 0 {
+		return nil, ErrInvalidToken
+	}
+
+	session, err := s.sessionRepo.GetByID(ctx, claims.SessionID)
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrSessionNotFound) {
+			return nil, ErrSessionRevoked
+		}
+		return nil, err
+	}
+	if session.RevokedAt != nil || session.UserID != claims.UserID {
+		return nil, ErrSessionRevoked
+	}
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		if err := s.sessionRepo.Touch(ctx, session.ID); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
-	return claims.UserID, nil
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
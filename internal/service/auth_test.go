@@ -2,13 +2,21 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/escoutdoor/social/internal/repository"
 	"github.com/escoutdoor/social/internal/repository/repoerrs"
 	"github.com/escoutdoor/social/internal/testutils"
 	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/jwtkeys"
+	"github.com/escoutdoor/social/pkg/totp"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"github.com/testcontainers/testcontainers-go"
 )
@@ -17,10 +25,25 @@ var (
 	signKey = "test"
 )
 
+// newTestKeys signs with a fresh EdDSA key and still accepts tokens signed
+// with the legacy HMAC secret.
+func newTestKeys() *jwtkeys.KeySet {
+	key, err := jwtkeys.GenerateEd25519("test")
+	if err != nil {
+		panic(err)
+	}
+	keys, err := jwtkeys.New(key.ID, key, jwtkeys.NewHMACKey("", []byte(signKey)))
+	if err != nil {
+		panic(err)
+	}
+	return keys
+}
+
 type authServiceSuite struct {
 	suite.Suite
 	container testcontainers.Container
 	svc       Auth
+	mailer    *MemoryMailer
 }
 
 func (st *authServiceSuite) SetupSuite() {
@@ -32,7 +55,8 @@ func (st *authServiceSuite) SetupSuite() {
 	repo := repository.New(db)
 
 	st.container = container
-	st.svc = NewAuthService(repo.Auth, repo.User, signKey)
+	st.mailer = NewMemoryMailer()
+	st.svc = newAuthService(repo, st.mailer)
 }
 
 func (st *authServiceSuite) TearDownSuite() {
@@ -43,6 +67,7 @@ func (st *authServiceSuite) TearDownSuite() {
 func (st *authServiceSuite) TestSignUp() {
 	ctx := context.Background()
 	in := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -58,6 +83,7 @@ func (st *authServiceSuite) TestSignUpSignInParseToken() {
 	ctx := context.Background()
 
 	registerIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -71,19 +97,224 @@ func (st *authServiceSuite) TestSignUpSignInParseToken() {
 		Email:    registerIn.Email,
 		Password: registerIn.Password,
 	}
-	token, err := st.svc.SignIn(ctx, loginIn)
+	tokens, err := st.svc.SignIn(ctx, loginIn, types.SessionMeta{})
 	st.NoError(err, "failed to signin")
-	st.NotEmpty(token, "expected to get token")
+	st.NotEmpty(tokens, "expected to get tokens")
 
-	tokenID, err := st.svc.ParseToken(token)
+	claims, err := st.svc.ParseToken(ctx, tokens.AccessToken)
 	st.NoError(err, "failed to parse token")
-	st.Equal(id, tokenID, "user id from claims doesn't match user id")
+	st.Equal(id, claims.UserID, "user id from claims doesn't match user id")
+}
+
+func (st *authServiceSuite) TestRefreshRotatesToken() {
+	ctx := context.Background()
+	tokens := st.signUpAndSignIn(ctx)
+
+	refreshed, err := st.svc.Refresh(ctx, tokens.RefreshToken)
+	st.NoError(err, "failed to refresh tokens")
+	st.NotEmpty(refreshed, "expected to get tokens")
+	st.NotEqual(tokens.RefreshToken, refreshed.RefreshToken, "expected refresh token to be rotated")
+
+	_, err = st.svc.ParseToken(ctx, refreshed.AccessToken)
+	st.NoError(err, "failed to parse refreshed token")
+}
+
+func (st *authServiceSuite) TestRefreshReuseRevokesFamily() {
+	ctx := context.Background()
+	tokens := st.signUpAndSignIn(ctx)
+
+	refreshed, err := st.svc.Refresh(ctx, tokens.RefreshToken)
+	st.NoError(err, "failed to refresh tokens")
+
+	_, err = st.svc.Refresh(ctx, tokens.RefreshToken)
+	st.ErrorIs(err, ErrRefreshTokenReused, "expected to get refresh token reused error")
+
+	_, err = st.svc.Refresh(ctx, refreshed.RefreshToken)
+	st.ErrorIs(err, ErrSessionRevoked, "expected session to be revoked after reuse")
+
+	_, err = st.svc.ParseToken(ctx, refreshed.AccessToken)
+	st.ErrorIs(err, ErrSessionRevoked, "expected access token to be rejected after reuse")
+}
+
+func (st *authServiceSuite) TestLogout() {
+	ctx := context.Background()
+	tokens := st.signUpAndSignIn(ctx)
+
+	err := st.svc.Logout(ctx, tokens.RefreshToken)
+	st.NoError(err, "failed to logout")
+
+	_, err = st.svc.ParseToken(ctx, tokens.AccessToken)
+	st.ErrorIs(err, ErrSessionRevoked, "expected access token to be rejected after logout")
+
+	_, err = st.svc.Refresh(ctx, tokens.RefreshToken)
+	st.ErrorIs(err, ErrSessionRevoked, "expected refresh token to be rejected after logout")
+}
+
+func (st *authServiceSuite) TestSessions() {
+	ctx := context.Background()
+	in := types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     gofakeit.Email(),
+		Password:  randomPw(),
+	}
+	userID, err := st.svc.SignUp(ctx, in)
+	st.Require().NoError(err, "failed to signup")
+
+	loginIn := types.LoginReq{Email: in.Email, Password: in.Password}
+	meta := types.SessionMeta{Device: "laptop", UserAgent: gofakeit.UserAgent(), IP: gofakeit.IPv4Address()}
+	current, err := st.svc.SignIn(ctx, loginIn, meta)
+	st.Require().NoError(err, "failed to signin")
+	other, err := st.svc.SignIn(ctx, loginIn, types.SessionMeta{Device: "phone"})
+	st.Require().NoError(err, "failed to signin")
+
+	claims, err := st.svc.ParseToken(ctx, current.AccessToken)
+	st.Require().NoError(err, "failed to parse token")
+
+	sessions, err := st.svc.GetSessions(ctx, userID, claims.SessionID)
+	st.NoError(err, "failed to get sessions")
+	st.Len(sessions, 2, "expected to get two sessions")
+
+	err = st.svc.RevokeOtherSessions(ctx, userID, claims.SessionID)
+	st.NoError(err, "failed to revoke other sessions")
+
+	_, err = st.svc.ParseToken(ctx, other.AccessToken)
+	st.ErrorIs(err, ErrSessionRevoked, "expected other session to be revoked")
+	_, err = st.svc.ParseToken(ctx, current.AccessToken)
+	st.NoError(err, "expected current session to stay active")
+
+	err = st.svc.RevokeSession(ctx, userID, claims.SessionID)
+	st.NoError(err, "failed to revoke session")
+	_, err = st.svc.ParseToken(ctx, current.AccessToken)
+	st.ErrorIs(err, ErrSessionRevoked, "expected current session to be revoked")
+}
+
+func (st *authServiceSuite) TestResetPassword() {
+	ctx := context.Background()
+	in := types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     gofakeit.Email(),
+		Password:  randomPw(),
+	}
+	_, err := st.svc.SignUp(ctx, in)
+	st.Require().NoError(err, "failed to signup")
+
+	tokens, err := st.svc.SignIn(ctx, types.LoginReq{Email: in.Email, Password: in.Password}, types.SessionMeta{})
+	st.Require().NoError(err, "failed to signin")
+
+	err = st.svc.ForgotPassword(ctx, in.Email)
+	st.Require().NoError(err, "failed to request password reset")
+
+	mail, ok := st.mailer.Last(in.Email)
+	st.Require().True(ok, "expected to get reset mail")
+	token := tokenFromMail(mail)
+	st.Require().NotEmpty(token, "expected to find token in mail")
+
+	resetIn := types.ResetPasswordReq{Token: token, Password: randomPw()}
+	err = st.svc.ResetPassword(ctx, resetIn)
+	st.NoError(err, "failed to reset password")
+
+	err = st.svc.ResetPassword(ctx, resetIn)
+	st.ErrorIs(err, ErrInvalidToken, "expected reset token to be single-use")
+
+	_, err = st.svc.ParseToken(ctx, tokens.AccessToken)
+	st.ErrorIs(err, ErrSessionRevoked, "expected sessions to be revoked after reset")
+
+	_, err = st.svc.SignIn(ctx, types.LoginReq{Email: in.Email, Password: in.Password}, types.SessionMeta{})
+	st.ErrorIs(err, ErrInvalidEmailOrPw, "expected old password to be rejected")
+
+	_, err = st.svc.SignIn(ctx, types.LoginReq{Email: in.Email, Password: resetIn.Password}, types.SessionMeta{})
+	st.NoError(err, "failed to signin with new password")
+}
+
+func (st *authServiceSuite) TestForgotPasswordUnknownEmail() {
+	ctx := context.Background()
+
+	err := st.svc.ForgotPassword(ctx, gofakeit.Email())
+	st.NoError(err, "expected no error for unknown email")
+}
+
+func (st *authServiceSuite) TestMagicLink() {
+	ctx := context.Background()
+	in := types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     gofakeit.Email(),
+		Password:  randomPw(),
+	}
+	_, err := st.svc.SignUp(ctx, in)
+	st.Require().NoError(err, "failed to signup")
+
+	err = st.svc.RequestMagicLink(ctx, in.Email, "")
+	st.Require().NoError(err, "failed to request magic link")
+
+	mail, ok := st.mailer.Last(in.Email)
+	st.Require().True(ok, "expected to get magic link mail")
+	token := tokenFromMail(mail)
+	st.Require().NotEmpty(token, "expected to find token in mail")
+
+	result, err := st.svc.SignInWithMagicLink(ctx, token, types.SessionMeta{})
+	st.Require().NoError(err, "failed to sign in with magic link")
+	st.Require().NotNil(result.Tokens, "expected to get tokens")
+
+	_, err = st.svc.ParseToken(ctx, result.AccessToken)
+	st.NoError(err, "failed to parse access token")
+
+	_, err = st.svc.SignInWithMagicLink(ctx, token, types.SessionMeta{})
+	st.ErrorIs(err, ErrInvalidToken, "expected magic link to be single-use")
+}
+
+func (st *authServiceSuite) TestMagicLinkUnknownEmail() {
+	ctx := context.Background()
+
+	err := st.svc.RequestMagicLink(ctx, gofakeit.Email(), "")
+	st.NoError(err, "expected no error for unknown email")
+
+	_, err = st.svc.SignInWithMagicLink(ctx, "fake-token", types.SessionMeta{})
+	st.ErrorIs(err, ErrInvalidToken, "expected unknown token to be rejected")
+}
+
+func (st *authServiceSuite) TestVerifyEmail() {
+	ctx := context.Background()
+	in := types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     gofakeit.Email(),
+		Password:  randomPw(),
+	}
+	_, err := st.svc.SignUp(ctx, in)
+	st.Require().NoError(err, "failed to signup")
+
+	mail, ok := st.mailer.Last(in.Email)
+	st.Require().True(ok, "expected to get verification mail")
+	token := tokenFromMail(mail)
+	st.Require().NotEmpty(token, "expected to find token in mail")
+
+	err = st.svc.VerifyEmail(ctx, token)
+	st.NoError(err, "failed to verify email")
+
+	err = st.svc.VerifyEmail(ctx, token)
+	st.ErrorIs(err, ErrInvalidToken, "expected verification token to be single-use")
+}
+
+func (st *authServiceSuite) TestRefreshWithFakeToken() {
+	ctx := context.Background()
+
+	tokens, err := st.svc.Refresh(ctx, gofakeit.UUID())
+	st.ErrorIs(err, ErrInvalidToken, "expected to get invalid token error")
+	st.Empty(tokens, "expected to get no data")
 }
 
 func (st *authServiceSuite) TestSignUpWithExistingEmail() {
 	ctx := context.Background()
 
 	in := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -93,12 +324,33 @@ func (st *authServiceSuite) TestSignUpWithExistingEmail() {
 	st.NoError(err, "failed to signup")
 	st.NotEmpty(id, "id should be non-empty")
 
+	in.Username = randomUsername()
 	id, err = st.svc.SignUp(ctx, in)
 	st.Error(err, "expected to get error: user already exists")
 	st.ErrorIs(err, repoerrs.ErrUserAlreadyExists, "expected to get user already exists error")
 	st.Empty(id, "expected to get no data")
 }
 
+func (st *authServiceSuite) TestSignUpWithTakenUsername() {
+	ctx := context.Background()
+
+	in := types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     gofakeit.Email(),
+		Password:  randomPw(),
+	}
+	_, err := st.svc.SignUp(ctx, in)
+	st.Require().NoError(err, "failed to signup")
+
+	in.Username = strings.ToUpper(in.Username)
+	in.Email = gofakeit.Email()
+	id, err := st.svc.SignUp(ctx, in)
+	st.ErrorIs(err, repoerrs.ErrUsernameTaken, "expected usernames to be case-insensitive")
+	st.Empty(id, "expected to get no data")
+}
+
 func (st *authServiceSuite) TestSignInWithFakeEmail() {
 	ctx := context.Background()
 	in := types.LoginReq{
@@ -106,16 +358,154 @@ func (st *authServiceSuite) TestSignInWithFakeEmail() {
 		Password: randomPw(),
 	}
 
-	token, err := st.svc.SignIn(ctx, in)
+	tokens, err := st.svc.SignIn(ctx, in, types.SessionMeta{})
 	st.Error(err, "expected to get error: invalid email or password")
 	st.ErrorIs(err, ErrInvalidEmailOrPw, "expected to get invalid email or password error")
-	st.Empty(token, "expected to get no data")
+	st.Empty(tokens, "expected to get no data")
+}
+
+func (st *authServiceSuite) TestTwoFactor() {
+	ctx := context.Background()
+	in := types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     gofakeit.Email(),
+		Password:  randomPw(),
+	}
+	id, err := st.svc.SignUp(ctx, in)
+	st.Require().NoError(err, "failed to signup")
+
+	setup, err := st.svc.SetupTwoFactor(ctx, types.User{ID: id, Email: in.Email})
+	st.Require().NoError(err, "failed to setup two-factor authentication")
+	st.NotEmpty(setup.URI, "expected to get otpauth uri")
+
+	key, err := totp.DecodeSecret(setup.Secret)
+	st.Require().NoError(err, "failed to decode totp secret")
+
+	_, err = st.svc.ConfirmTwoFactor(ctx, id, "000000")
+	st.ErrorIs(err, ErrInvalidTwoFactorCode, "expected to get invalid two-factor code error")
+
+	codes, err := st.svc.ConfirmTwoFactor(ctx, id, totp.Generate(key, time.Now(), totp.DefaultOpts))
+	st.Require().NoError(err, "failed to confirm two-factor authentication")
+	st.Len(codes, recoveryCodesCount, "expected to get recovery codes")
+
+	loginIn := types.LoginReq{Email: in.Email, Password: in.Password}
+	result, err := st.svc.SignIn(ctx, loginIn, types.SessionMeta{})
+	st.Require().NoError(err, "failed to signin")
+	st.True(result.TwoFactorRequired, "expected two-factor challenge")
+	st.Nil(result.Tokens, "expected to get no tokens before the second factor")
+
+	_, err = st.svc.ParseToken(ctx, result.ChallengeToken)
+	st.ErrorIs(err, ErrInvalidToken, "expected challenge token to be rejected as access token")
+
+	// the current code was used up confirming the setup
+	totpIn := types.TwoFactorVerifyReq{
+		ChallengeToken: result.ChallengeToken,
+		Code:           totp.Generate(key, time.Now(), totp.DefaultOpts),
+	}
+	_, err = st.svc.VerifyTwoFactor(ctx, totpIn, types.SessionMeta{})
+	st.ErrorIs(err, ErrInvalidTwoFactorCode, "expected used totp code to be rejected")
+
+	totpIn.Code = totp.Generate(key, time.Now().Add(totp.DefaultOpts.Period), totp.DefaultOpts)
+	tokens, err := st.svc.VerifyTwoFactor(ctx, totpIn, types.SessionMeta{})
+	st.Require().NoError(err, "failed to verify totp code")
+	st.NotEmpty(tokens.AccessToken, "expected to get access token")
+
+	_, err = st.svc.VerifyTwoFactor(ctx, totpIn, types.SessionMeta{})
+	st.ErrorIs(err, ErrInvalidTwoFactorCode, "expected used totp code to be rejected")
+
+	recoveryIn := types.TwoFactorVerifyReq{ChallengeToken: result.ChallengeToken, Code: codes[0]}
+	_, err = st.svc.VerifyTwoFactor(ctx, recoveryIn, types.SessionMeta{})
+	st.NoError(err, "failed to verify recovery code")
+
+	_, err = st.svc.VerifyTwoFactor(ctx, recoveryIn, types.SessionMeta{})
+	st.ErrorIs(err, ErrInvalidTwoFactorCode, "expected used recovery code to be rejected")
+
+	err = st.svc.DisableTwoFactor(ctx, id, codes[1])
+	st.Require().NoError(err, "failed to disable two-factor authentication")
+
+	result, err = st.svc.SignIn(ctx, loginIn, types.SessionMeta{})
+	st.Require().NoError(err, "failed to signin")
+	st.False(result.TwoFactorRequired, "expected no two-factor challenge")
+}
+
+func (st *authServiceSuite) signUpAndSignIn(ctx context.Context) *types.Tokens {
+	in := types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     gofakeit.Email(),
+		Password:  randomPw(),
+	}
+	_, err := st.svc.SignUp(ctx, in)
+	st.Require().NoError(err, "failed to signup")
+
+	result, err := st.svc.SignIn(ctx, types.LoginReq{Email: in.Email, Password: in.Password}, types.SessionMeta{})
+	st.Require().NoError(err, "failed to signin")
+	st.Require().NotNil(result.Tokens, "expected to get tokens")
+	return result.Tokens
 }
 
 func TestAuthService(t *testing.T) {
 	suite.Run(t, new(authServiceSuite))
 }
 
+func newAuthService(repo *repository.Repository, mailer Mailer) *AuthService {
+	return NewAuthService(newAuthServiceOpts(repo, mailer))
+}
+
+func newAuthServiceOpts(repo *repository.Repository, mailer Mailer) AuthServiceOpts {
+	return AuthServiceOpts{
+		Repo:          repo.Auth,
+		UserRepo:      repo.User,
+		TokenRepo:     repo.RefreshToken,
+		SessionRepo:   repo.Session,
+		ResetRepo:     repo.PasswordReset,
+		MagicLinkRepo: repo.MagicLink,
+		TwoFactorRepo: repo.TwoFactor,
+		IdentityRepo:  repo.Identity,
+		Verifier:      NewEmailVerifier(repo.EmailVerification, mailer, "http://localhost"),
+		Mailer:        mailer,
+		Keys:          newTestKeys(),
+		AppURL:        "http://localhost",
+		TOTPIssuer:    "Social",
+		DeletionGrace: DefaultPurgeOpts.Grace,
+	}
+}
+
+// tokenFromMail extracts the token query parameter from the link in the mail.
+func tokenFromMail(mail Mail) string {
+	for _, field := range strings.Fields(mail.Body) {
+		u, err := url.Parse(field)
+		if err != nil {
+			continue
+		}
+		if token := u.Query().Get("token"); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// signUp creates a user with random details and returns its ID.
+func signUp(t *testing.T, ctx context.Context, auth Auth) uuid.UUID {
+	t.Helper()
+	id, err := auth.SignUp(ctx, types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     gofakeit.Email(),
+		Password:  randomPw(),
+	})
+	require.NoError(t, err, "failed to signup")
+	return id
+}
+
 func randomPw() string {
 	return gofakeit.Password(true, true, true, true, false, 6)
 }
+
+func randomUsername() string {
+	return fmt.Sprintf("%s_%d", gofakeit.Username(), gofakeit.Number(1000, 9999))
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+)
+
+// BlockService manages blocks and mutes. A block works both ways: neither
+// user can follow, like or comment on the content of the other, and their
+// profiles are hidden from each other. A mute only hides the muted user from
+// the feed and comment trees of the muter.
+type BlockService struct {
+	repo       repository.Block
+	followRepo repository.Follow
+	feed       *FeedService
+}
+
+func NewBlockService(repo repository.Block, followRepo repository.Follow, feed *FeedService) *BlockService {
+	return &BlockService{
+		repo:       repo,
+		followRepo: followRepo,
+		feed:       feed,
+	}
+}
+
+// Block blocks the user and removes the follows and follow requests between
+// the two users.
+func (s *BlockService) Block(ctx context.Context, blockerID uuid.UUID, blockedID uuid.UUID) error {
+	if err := s.repo.Block(ctx, blockerID, blockedID); err != nil {
+		return err
+	}
+	for _, f := range [][2]uuid.UUID{{blockerID, blockedID}, {blockedID, blockerID}} {
+		err := s.followRepo.Delete(ctx, f[0], f[1])
+		if errors.Is(err, repoerrs.ErrNotFollowing) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.feed.Unfollowed(ctx, f[0], f[1]); err != nil {
+			slog.Error("BlockService.Block - FeedService.Unfollowed", "error", err)
+		}
+	}
+	for _, f := range [][2]uuid.UUID{{blockerID, blockedID}, {blockedID, blockerID}} {
+		err := s.followRepo.DeleteRequest(ctx, f[0], f[1])
+		if err != nil && !errors.Is(err, repoerrs.ErrFollowRequestNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *BlockService) Unblock(ctx context.Context, blockerID uuid.UUID, blockedID uuid.UUID) error {
+	return s.repo.Unblock(ctx, blockerID, blockedID)
+}
+
+func (s *BlockService) GetBlocked(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	return s.repo.GetBlocked(ctx, userID, page)
+}
+
+func (s *BlockService) Mute(ctx context.Context, muterID uuid.UUID, mutedID uuid.UUID) error {
+	if err := s.repo.Mute(ctx, muterID, mutedID); err != nil {
+		return err
+	}
+	if err := s.feed.Unfollowed(ctx, muterID, mutedID); err != nil {
+		slog.Error("BlockService.Mute - FeedService.Unfollowed", "error", err)
+	}
+	return nil
+}
+
+func (s *BlockService) Unmute(ctx context.Context, muterID uuid.UUID, mutedID uuid.UUID) error {
+	if err := s.repo.Unmute(ctx, muterID, mutedID); err != nil {
+		return err
+	}
+	if err := s.feed.Unmuted(ctx, muterID); err != nil {
+		slog.Error("BlockService.Unmute - FeedService.Unmuted", "error", err)
+	}
+	return nil
+}
+
+func (s *BlockService) GetMuted(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	return s.repo.GetMuted(ctx, userID, page)
+}
+
+// check returns ErrBlocked when either user has blocked the other. A nil
+// *BlockService allows everything.
+func (s *BlockService) check(ctx context.Context, userID uuid.UUID, otherID uuid.UUID) error {
+	if s == nil || userID == otherID {
+		return nil
+	}
+	blocked, err := s.repo.IsBlocked(ctx, userID, otherID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+	return nil
+}
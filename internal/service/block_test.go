@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/testutils"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/validator"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+type blockServiceSuite struct {
+	suite.Suite
+	container  testcontainers.Container
+	repo       *repository.Repository
+	svc        Block
+	followSvc  Follow
+	userSvc    User
+	commentSvc Comment
+	likeSvc    Like
+	authSvc    Auth
+}
+
+func (st *blockServiceSuite) SetupSuite() {
+	container, db, err := testutils.NewPostgresContainer()
+	st.Require().NoError(err, "failed to run container")
+	st.Require().NotEmpty(container, "expected to get non-empty container")
+	st.Require().NotEmpty(db, "expected to get non-empty db connection")
+
+	repo := repository.New(db)
+	mailer := NewMemoryMailer()
+	blocks := NewBlockService(repo.Block, repo.Follow, nil)
+	follows := NewFollowService(repo.Follow, repo.User, blocks, nil)
+
+	st.container = container
+	st.repo = repo
+	st.svc = blocks
+	st.followSvc = follows
+	st.userSvc = NewUserService(repo.User, repo.Follow, repo.File, blocks, NewEmailVerifier(repo.EmailVerification, mailer, "http://localhost"), validator.New(), DefaultUsernameOpts)
+	st.commentSvc = NewCommentService(repo.Comment, repo.Post, NewModerationService(repo.Moderation), blocks, follows)
+	st.likeSvc = NewLikeService(repo.Like, repo.Post, repo.Comment, blocks, follows, nil)
+	st.authSvc = newAuthService(repo, mailer)
+}
+
+func (st *blockServiceSuite) TearDownSuite() {
+	err := st.container.Terminate(context.Background())
+	st.Require().NoError(err, "failed to terminate postgres container")
+}
+
+func (st *blockServiceSuite) createPost(ctx context.Context, userID uuid.UUID) uuid.UUID {
+	post, err := st.repo.Post.Create(ctx, userID, types.CreatePostReq{Content: gofakeit.Dessert()}, nil)
+	st.Require().NoError(err, "failed to create post")
+	return post.ID
+}
+
+func (st *blockServiceSuite) TestBlock() {
+	ctx := context.Background()
+	alice, bob := signUp(st.T(), ctx, st.authSvc), signUp(st.T(), ctx, st.authSvc)
+	postID := st.createPost(ctx, alice)
+
+	_, err := st.followSvc.Follow(ctx, alice, bob)
+	st.Require().NoError(err, "failed to follow")
+	_, err = st.followSvc.Follow(ctx, bob, alice)
+	st.Require().NoError(err, "failed to follow")
+
+	err = st.svc.Block(ctx, alice, bob)
+	st.Require().NoError(err, "failed to block")
+
+	err = st.svc.Block(ctx, alice, bob)
+	st.ErrorIs(err, repoerrs.ErrAlreadyBlocked, "expected duplicate block to be rejected")
+
+	following, err := st.followSvc.GetFollowing(ctx, alice, types.CursorPage{Limit: 20})
+	st.NoError(err, "failed to get following")
+	st.Empty(following.Items, "expected block to remove follows")
+	followers, err := st.followSvc.GetFollowers(ctx, alice, types.CursorPage{Limit: 20})
+	st.NoError(err, "failed to get followers")
+	st.Empty(followers.Items, "expected block to remove follows")
+
+	// the blocked user is held back just like the blocker
+	_, err = st.followSvc.Follow(ctx, bob, alice)
+	st.ErrorIs(err, ErrBlocked, "expected follow to be rejected")
+	_, err = st.commentSvc.Create(ctx, bob, postID, types.CreateCommentReq{Content: gofakeit.Sentence(3)})
+	st.ErrorIs(err, ErrBlocked, "expected comment to be rejected")
+	err = st.likeSvc.LikePost(ctx, postID, bob)
+	st.ErrorIs(err, ErrBlocked, "expected like to be rejected")
+	_, err = st.userSvc.GetProfile(ctx, alice, bob)
+	st.ErrorIs(err, repoerrs.ErrUserNotFound, "expected profile to be hidden")
+	_, err = st.userSvc.GetProfile(ctx, bob, alice)
+	st.ErrorIs(err, repoerrs.ErrUserNotFound, "expected profile to be hidden")
+
+	blocked, err := st.svc.GetBlocked(ctx, alice, types.CursorPage{Limit: 20})
+	st.NoError(err, "failed to get blocked users")
+	st.Require().Len(blocked.Items, 1, "expected one blocked user")
+	st.Equal(bob, blocked.Items[0].ID)
+
+	err = st.svc.Unblock(ctx, alice, bob)
+	st.Require().NoError(err, "failed to unblock")
+	err = st.svc.Unblock(ctx, alice, bob)
+	st.ErrorIs(err, repoerrs.ErrNotBlocked, "expected unblock without block to fail")
+
+	_, err = st.followSvc.Follow(ctx, bob, alice)
+	st.NoError(err, "expected follow to be allowed after unblock")
+	_, err = st.userSvc.GetProfile(ctx, alice, bob)
+	st.NoError(err, "expected profile to be visible after unblock")
+}
+
+func (st *blockServiceSuite) TestMuteHidesComments() {
+	ctx := context.Background()
+	alice, bob, carol := signUp(st.T(), ctx, st.authSvc), signUp(st.T(), ctx, st.authSvc), signUp(st.T(), ctx, st.authSvc)
+	postID := st.createPost(ctx, carol)
+
+	_, err := st.commentSvc.Create(ctx, bob, postID, types.CreateCommentReq{Content: gofakeit.Sentence(3)})
+	st.Require().NoError(err, "failed to create comment")
+	carolComment, err := st.commentSvc.Create(ctx, carol, postID, types.CreateCommentReq{Content: gofakeit.Sentence(3)})
+	st.Require().NoError(err, "failed to create comment")
+	_, err = st.commentSvc.Create(ctx, bob, postID, types.CreateCommentReq{
+		Content:         gofakeit.Sentence(3),
+		ParentCommentID: &carolComment,
+	})
+	st.Require().NoError(err, "failed to create reply")
+
+	err = st.svc.Mute(ctx, alice, bob)
+	st.Require().NoError(err, "failed to mute")
+	err = st.svc.Mute(ctx, alice, alice)
+	st.ErrorIs(err, repoerrs.ErrSelfMute, "expected self mute to be rejected")
+
+	comments, err := st.commentSvc.GetAll(ctx, postID, alice, types.CursorPage{Limit: 20})
+	st.Require().NoError(err, "failed to get comments")
+	st.Require().Len(comments.Items, 1, "expected comments of the muted user to be hidden")
+	st.Equal(carolComment, comments.Items[0].ID)
+	st.Empty(comments.Items[0].Replies, "expected replies of the muted user to be hidden")
+
+	// muting does not affect anyone else, nor what the muted user may do
+	comments, err = st.commentSvc.GetAll(ctx, postID, carol, types.CursorPage{Limit: 20})
+	st.Require().NoError(err, "failed to get comments")
+	st.Len(comments.Items, 2, "expected every comment")
+	_, err = st.followSvc.Follow(ctx, bob, alice)
+	st.NoError(err, "expected muted user to be able to follow")
+
+	muted, err := st.svc.GetMuted(ctx, alice, types.CursorPage{Limit: 20})
+	st.NoError(err, "failed to get muted users")
+	st.Require().Len(muted.Items, 1, "expected one muted user")
+	st.Equal(bob, muted.Items[0].ID)
+
+	st.Require().NoError(st.svc.Unmute(ctx, alice, bob), "failed to unmute")
+	comments, err = st.commentSvc.GetAll(ctx, postID, alice, types.CursorPage{Limit: 20})
+	st.Require().NoError(err, "failed to get comments")
+	st.Len(comments.Items, 2, "expected every comment after unmute")
+}
+
+func TestBlockService(t *testing.T) {
+	suite.Run(t, new(blockServiceSuite))
+}
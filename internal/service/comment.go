@@ -2,46 +2,94 @@ package service
 
 import (
 	"context"
+	"errors"
 
 	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
 	"github.com/escoutdoor/social/internal/types"
 	"github.com/google/uuid"
 )
 
 type CommentService struct {
-	repo     repository.Comment
-	postRepo repository.Post
+	repo       repository.Comment
+	postRepo   repository.Post
+	moderation *ModerationService
+	blocks     *BlockService
+	follows    *FollowService
 }
 
-func NewCommentService(repo repository.Comment, postRepo repository.Post) *CommentService {
+func NewCommentService(repo repository.Comment, postRepo repository.Post, moderation *ModerationService, blocks *BlockService, follows *FollowService) *CommentService {
 	return &CommentService{
-		repo:     repo,
-		postRepo: postRepo,
+		repo:       repo,
+		postRepo:   postRepo,
+		moderation: moderation,
+		blocks:     blocks,
+		follows:    follows,
 	}
 }
 
+// Create adds a comment unless the post is hidden from the user, or the
+// author of the post or of the comment replied to shares a block with them.
 func (s *CommentService) Create(ctx context.Context, userID uuid.UUID, postID uuid.UUID, input types.CreateCommentReq) (uuid.UUID, error) {
+	post, err := visiblePost(ctx, s.postRepo, s.follows, postID, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := s.blocks.check(ctx, userID, post.UserID); err != nil {
+		return uuid.Nil, err
+	}
+	if input.ParentCommentID != nil {
+		parent, err := s.repo.GetByID(ctx, *input.ParentCommentID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if err := s.blocks.check(ctx, userID, parent.UserID); err != nil {
+			return uuid.Nil, err
+		}
+	}
 	return s.repo.Create(ctx, userID, postID, input)
 }
 
-func (s *CommentService) GetByID(ctx context.Context, id uuid.UUID) (*types.Comment, error) {
-	return s.repo.GetByID(ctx, id)
+// GetByID returns the comment if the viewer may see the post it belongs to.
+func (s *CommentService) GetByID(ctx context.Context, id uuid.UUID, viewerID uuid.UUID) (*types.Comment, error) {
+	comment, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := visiblePost(ctx, s.postRepo, s.follows, comment.PostID, viewerID); err != nil {
+		if errors.Is(err, repoerrs.ErrPostNotFound) {
+			return nil, repoerrs.ErrCommentNotFound
+		}
+		return nil, err
+	}
+	return comment, nil
 }
 
-func (s *CommentService) GetAll(ctx context.Context, postID uuid.UUID) ([]types.Comment, error) {
-	if _, err := s.postRepo.GetByID(ctx, postID); err != nil {
-		return nil, err
+// GetAll returns the comment trees of the post as seen by the viewer, without
+// the comments of users the viewer muted or shares a block with.
+func (s *CommentService) GetAll(ctx context.Context, postID uuid.UUID, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.Comment], error) {
+	if _, err := visiblePost(ctx, s.postRepo, s.follows, postID, viewerID); err != nil {
+		return types.Page[types.Comment]{}, err
 	}
-	return s.repo.GetAll(ctx, postID)
+	return s.repo.GetAll(ctx, postID, viewerID, page)
 }
 
-func (s *CommentService) Delete(ctx context.Context, commentID uuid.UUID, userID uuid.UUID) error {
+func (s *CommentService) Delete(ctx context.Context, commentID uuid.UUID, actor types.Actor) error {
 	comment, err := s.repo.GetByID(ctx, commentID)
 	if err != nil {
 		return err
 	}
-	if comment.UserID != userID {
-		return ErrAccessDenied
+	moderated, err := authorize(actor, comment.UserID, PermDeleteAnyComment)
+	if err != nil {
+		return err
+	}
+	var action *types.ModerationAction
+	if moderated {
+		action = s.moderation.action(actor, types.ModerationDeleteComment, commentID, comment.UserID)
+	}
+	if err := s.repo.Delete(ctx, commentID, action); err != nil {
+		return err
 	}
-	return s.repo.Delete(ctx, commentID)
+	logModeration(action)
+	return nil
 }
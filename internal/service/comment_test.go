@@ -38,9 +38,9 @@ func (st *commentServiceSuite) SetupSuite() {
 
 	st.container = container
 	st.redisContainer = redisContainer
-	st.svc = NewCommentService(repo.Comment, repo.Post)
-	st.postSvc = NewPostService(repo.Post, c)
-	st.authSvc = NewAuthService(repo.Auth, repo.User, signKey)
+	st.svc = NewCommentService(repo.Comment, repo.Post, NewModerationService(repo.Moderation), nil, nil)
+	st.postSvc = NewPostService(repo.Post, repo.File, c, NewModerationService(repo.Moderation), nil, nil)
+	st.authSvc = newAuthService(repo, NewMemoryMailer())
 }
 
 func (st *commentServiceSuite) TearDownSuite() {
@@ -55,6 +55,7 @@ func (st *commentServiceSuite) TestCreateCommentExistingPost() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -65,8 +66,7 @@ func (st *commentServiceSuite) TestCreateCommentExistingPost() {
 	st.NotEmpty(userID, "expected to get user id")
 
 	postIn := types.CreatePostReq{
-		Content:  gofakeit.Dessert(),
-		PhotoURL: gofakeit.URL(),
+		Content: gofakeit.Dessert(),
 	}
 	post, err := st.postSvc.Create(ctx, userID, postIn)
 	st.NoError(err, "failed to create post")
@@ -84,6 +84,7 @@ func (st *commentServiceSuite) TestCreateCommentNonExistingPost() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -106,6 +107,7 @@ func (st *commentServiceSuite) TestCreateCommentNonExistingUser() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -116,8 +118,7 @@ func (st *commentServiceSuite) TestCreateCommentNonExistingUser() {
 	st.NotEmpty(userID, "expected to get user id")
 
 	postIn := types.CreatePostReq{
-		Content:  gofakeit.Dessert(),
-		PhotoURL: gofakeit.URL(),
+		Content: gofakeit.Dessert(),
 	}
 	post, err := st.postSvc.Create(ctx, userID, postIn)
 	st.NoError(err, "failed to create post")
@@ -136,6 +137,7 @@ func (st *commentServiceSuite) TestCreateCommentOnComment() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -146,8 +148,7 @@ func (st *commentServiceSuite) TestCreateCommentOnComment() {
 	st.NotEmpty(userID, "expected to get user id")
 
 	postIn := types.CreatePostReq{
-		Content:  gofakeit.Dessert(),
-		PhotoURL: gofakeit.URL(),
+		Content: gofakeit.Dessert(),
 	}
 	post, err := st.postSvc.Create(ctx, userID, postIn)
 	st.NoError(err, "failed to create post")
@@ -173,7 +174,7 @@ func (st *commentServiceSuite) TestCreateCommentOnComment() {
 func (st *commentServiceSuite) TestGetByIDNotFound() {
 	ctx := context.Background()
 
-	comment, err := st.svc.GetByID(ctx, uuid.New())
+	comment, err := st.svc.GetByID(ctx, uuid.New(), uuid.New())
 	st.Error(err, "expected to get error: comment not found")
 	st.ErrorIs(err, repoerrs.ErrCommentNotFound, "expected to get comment not found error")
 	st.Empty(comment, "expected to get no data")
@@ -183,6 +184,7 @@ func (st *commentServiceSuite) TestGetByID() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -193,8 +195,7 @@ func (st *commentServiceSuite) TestGetByID() {
 	st.NotEmpty(userID, "expected to get user id")
 
 	postIn := types.CreatePostReq{
-		Content:  gofakeit.Dessert(),
-		PhotoURL: gofakeit.URL(),
+		Content: gofakeit.Dessert(),
 	}
 	post, err := st.postSvc.Create(ctx, userID, postIn)
 	st.NoError(err, "failed to create post")
@@ -207,7 +208,7 @@ func (st *commentServiceSuite) TestGetByID() {
 	st.NoError(err, "failed to create comment")
 	st.NotEmpty(commentID, "expected to get comment id")
 
-	comment, err := st.svc.GetByID(ctx, commentID)
+	comment, err := st.svc.GetByID(ctx, commentID, userID)
 	st.NoError(err, "failed to get comment")
 	st.NotEmpty(comment, "expected to get comment")
 }
@@ -216,6 +217,7 @@ func (st *commentServiceSuite) TestDeleteComment() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -226,8 +228,7 @@ func (st *commentServiceSuite) TestDeleteComment() {
 	st.NotEmpty(userID, "expected to get user id")
 
 	postIn := types.CreatePostReq{
-		Content:  gofakeit.Dessert(),
-		PhotoURL: gofakeit.URL(),
+		Content: gofakeit.Dessert(),
 	}
 	post, err := st.postSvc.Create(ctx, userID, postIn)
 	st.NoError(err, "failed to create post")
@@ -240,14 +241,14 @@ func (st *commentServiceSuite) TestDeleteComment() {
 	st.NoError(err, "failed to create comment")
 	st.NotEmpty(commentID, "expected to get comment id")
 
-	err = st.svc.Delete(ctx, commentID, userID)
+	err = st.svc.Delete(ctx, commentID, types.Actor{ID: userID, Role: types.RoleUser})
 	st.NoError(err, "failed to delete comment")
 }
 
 func (st *commentServiceSuite) TestDeleteNotExistingComment() {
 	ctx := context.Background()
 
-	err := st.svc.Delete(ctx, uuid.New(), uuid.New())
+	err := st.svc.Delete(ctx, uuid.New(), types.Actor{ID: uuid.New(), Role: types.RoleUser})
 	st.Error(err, "expected to get error: comment not found")
 	st.ErrorIs(err, repoerrs.ErrCommentNotFound, "expected to get comment not found error")
 }
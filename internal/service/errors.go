@@ -1,13 +1,68 @@
 package service
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/escoutdoor/social/internal/types"
+)
 
 var (
 	ErrInvalidEmailOrPw = errors.New("invalid email or password")
 
-	ErrInvalidToken = errors.New("invalid token")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrSessionRevoked     = errors.New("session has been revoked")
+	ErrRefreshTokenReused = errors.New("refresh token has already been used")
+
+	ErrAPIKeyExpired = errors.New("api key has expired")
+	ErrInvalidExpiry = errors.New("expiry must be in the future")
+
+	ErrUnknownProvider  = errors.New("unknown identity provider")
+	ErrOIDCLoginFailed  = errors.New("failed to sign in with identity provider")
+	ErrOIDCEmailMissing = errors.New("identity provider did not share an email address")
+
+	ErrAccountDeleted = errors.New("account has been deleted")
+
+	ErrTooManyAttempts = errors.New("too many failed sign in attempts")
+	ErrAccountLocked   = errors.New("account is temporarily locked")
+	ErrLockoutNotFound = errors.New("lockout not found")
+
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor authentication code")
+
+	ErrAccessDenied     = errors.New("access denied")
+	ErrCannotDemoteSelf = errors.New("admins cannot change their own role")
 
-	ErrAccessDenied = errors.New("access denied")
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+	ErrEmailNotVerified     = errors.New("email is not verified")
 
 	ErrAlreadyLiked = errors.New("already liked by user")
+
+	ErrTooManyMedia   = fmt.Errorf("a post can have at most %d media", types.MaxPostMedia)
+	ErrDuplicateMedia = errors.New("a file can only be attached to a post once")
+	ErrMediaNotFound  = errors.New("media must be files you uploaded")
+
+	ErrUnsupportedFileType   = errors.New("file type is not supported")
+	ErrFileTooLarge          = errors.New("file is too large")
+	ErrFileExtensionMismatch = errors.New("file extension does not match its content")
+
+	ErrBlocked = errors.New("action is not allowed between these users")
+
+	ErrUsernameCooldown = errors.New("username was changed too recently")
 )
+
+// RetryError rejects a request for a limited time. The underlying error is
+// ErrTooManyAttempts or ErrAccountLocked.
+type RetryError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/s3"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+)
+
+type ExportOpts struct {
+	// LinkTTL is how long the download link of a finished export works. S3
+	// does not sign URLs for longer than seven days.
+	LinkTTL time.Duration
+	// Timeout bounds the time to build an export. A pending export older
+	// than that is considered lost, e.g. to a restart, and the user may
+	// start a new one.
+	Timeout time.Duration
+}
+
+var DefaultExportOpts = ExportOpts{
+	LinkTTL: time.Hour * 48,
+	Timeout: time.Minute * 30,
+}
+
+// ExportService builds archives of the data of a user in the background and
+// mails a download link once they are ready.
+type ExportService struct {
+	repo     repository.Export
+	fileRepo repository.File
+	s3       s3.Repository
+	mailer   Mailer
+	opts     ExportOpts
+	wg       sync.WaitGroup
+}
+
+func NewExportService(repo repository.Export, fileRepo repository.File, s3 s3.Repository, mailer Mailer, opts ExportOpts) *ExportService {
+	return &ExportService{
+		repo:     repo,
+		fileRepo: fileRepo,
+		s3:       s3,
+		mailer:   mailer,
+		opts:     opts,
+	}
+}
+
+// Request starts an export of the data of the user. If one is already being
+// built, that one is returned instead of starting another.
+func (s *ExportService) Request(ctx context.Context, user types.User) (*types.DataExport, error) {
+	export, err := s.repo.GetPending(ctx, user.ID, time.Now().Add(-s.opts.Timeout))
+	if err == nil {
+		return export, nil
+	}
+	if !errors.Is(err, repoerrs.ErrExportNotFound) {
+		return nil, err
+	}
+
+	export, err = s.repo.Create(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(*export, user)
+	}()
+	return export, nil
+}
+
+// Get returns an export of the user. A ready export comes with a download
+// link that stops working when the export expires.
+func (s *ExportService) Get(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*types.DataExport, error) {
+	export, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if export.UserID != userID {
+		return nil, repoerrs.ErrExportNotFound
+	}
+	if export.Status != types.ExportStatusReady {
+		return export, nil
+	}
+
+	ttl := time.Until(*export.ExpiresAt)
+	if ttl <= 0 {
+		export.Status = types.ExportStatusExpired
+		return export, nil
+	}
+	url, err := s.s3.ExportURL(ctx, *export.ObjectKey, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign export url: %w", err)
+	}
+	export.DownloadURL = &url
+	return export, nil
+}
+
+// Wait blocks until every export started so far has finished.
+func (s *ExportService) Wait() {
+	s.wg.Wait()
+}
+
+func (s *ExportService) run(export types.DataExport, user types.User) {
+	// the request that started the export is long gone by now
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+
+	key := fmt.Sprintf("%s/%s.zip", user.ID, export.ID)
+	if err := s.build(ctx, key, user); err != nil {
+		slog.Error("ExportService.run - ExportService.build", "export_id", export.ID, "error", err)
+		if err := s.repo.Fail(ctx, export.ID); err != nil {
+			slog.Error("ExportService.run - ExportRepository.Fail", "export_id", export.ID, "error", err)
+		}
+		return
+	}
+
+	expiresAt := time.Now().Add(s.opts.LinkTTL)
+	if err := s.repo.Complete(ctx, export.ID, key, expiresAt); err != nil {
+		slog.Error("ExportService.run - ExportRepository.Complete", "export_id", export.ID, "error", err)
+		return
+	}
+	url, err := s.s3.ExportURL(ctx, key, s.opts.LinkTTL)
+	if err != nil {
+		slog.Error("ExportService.run - S3.ExportURL", "export_id", export.ID, "error", err)
+		return
+	}
+	err = s.mailer.Send(ctx, Mail{
+		To:      user.Email,
+		Subject: "Your data export is ready",
+		Body: fmt.Sprintf(
+			"Your data export is ready. Download it from the link below before %s.\n\n%s\n",
+			expiresAt.UTC().Format(time.RFC1123), url,
+		),
+	})
+	if err != nil {
+		slog.Error("ExportService.run - Mailer.Send", "export_id", export.ID, "error", err)
+	}
+}
+
+// build writes the profile, posts, comments and likes of the user as JSON
+// files into a ZIP archive together with the media they reference, and
+// uploads the archive under key.
+func (s *ExportService) build(ctx context.Context, key string, user types.User) error {
+	posts, err := s.repo.GetPosts(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	comments, err := s.repo.GetComments(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	likes, err := s.repo.GetLikes(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	uploads, err := s.fileRepo.GetByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	// the archive can be large, so it is spooled to disk rather than memory
+	f, err := os.CreateTemp("", "export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", types.NewAccount(user)},
+		{"posts.json", posts},
+		{"comments.json", comments},
+		{"likes.json", likes},
+	}
+	for _, file := range files {
+		if err := writeJSON(zw, file.name, file.data); err != nil {
+			return err
+		}
+	}
+
+	// every upload goes into the archive once, whether it is on posts, the
+	// avatar or nowhere. The avatar and media URLs themselves could name an
+	// object of someone else.
+	for _, f := range uploads {
+		if err := s.writeMedia(ctx, zw, f.URL); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return s.s3.PutExport(ctx, key, f, size)
+}
+
+// writeMedia copies an object of ours into the media folder of the archive.
+// Links to elsewhere and objects that are gone are skipped.
+func (s *ExportService) writeMedia(ctx context.Context, zw *zip.Writer, url string) error {
+	id, ok := s.s3.ObjectID(url)
+	if !ok {
+		return nil
+	}
+	src, err := s.s3.Open(ctx, id)
+	if err != nil {
+		if errors.Is(err, s3.ErrObjectNotFound) {
+			return nil
+		}
+		return err
+	}
+	defer src.Close()
+
+	w, err := zw.Create("media/" + id)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+func writeJSON(zw *zip.Writer, name string, v any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/s3"
+	"github.com/escoutdoor/social/internal/testutils"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+type exportServiceSuite struct {
+	suite.Suite
+	container      testcontainers.Container
+	minioContainer testcontainers.Container
+	repo           *repository.Repository
+	s3             s3.Repository
+	mailer         *MemoryMailer
+	svc            *ExportService
+	authSvc        Auth
+}
+
+func (st *exportServiceSuite) SetupSuite() {
+	container, db, err := testutils.NewPostgresContainer()
+	st.Require().NoError(err, "failed to run postgres container")
+	st.Require().NotEmpty(db, "expected to get db connection")
+
+	minioContainer, s3, err := testutils.NewMinIOContainer()
+	st.Require().NoError(err, "failed to run minio container")
+	st.Require().NotEmpty(s3, "expected to get minio connection")
+
+	st.container = container
+	st.minioContainer = minioContainer
+	st.repo = repository.New(db)
+	st.s3 = s3
+	st.mailer = NewMemoryMailer()
+	st.svc = NewExportService(st.repo.Export, st.repo.File, s3, st.mailer, DefaultExportOpts)
+	st.authSvc = newAuthService(st.repo, st.mailer)
+}
+
+func (st *exportServiceSuite) TearDownSuite() {
+	err := st.container.Terminate(context.Background())
+	st.Require().NoError(err, "failed to terminate postgres container")
+
+	err = st.minioContainer.Terminate(context.Background())
+	st.Require().NoError(err, "failed to terminate minio container")
+}
+
+func (st *exportServiceSuite) TestExport() {
+	ctx := context.Background()
+
+	id, err := st.authSvc.SignUp(ctx, types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     gofakeit.Email(),
+		Password:  randomPw(),
+	})
+	st.Require().NoError(err, "failed to signup")
+	user, err := st.repo.User.GetByID(ctx, id)
+	st.Require().NoError(err, "failed to get user")
+
+	photo := []byte("photo")
+	photoURL, err := st.s3.Create(types.File{Name: "photo.png", Payload: bytes.NewReader(photo), Size: int64(len(photo))})
+	st.Require().NoError(err, "failed to upload photo")
+	objectID, _ := st.s3.ObjectID(photoURL)
+	file, err := st.repo.File.Create(ctx, types.FileRecord{
+		UserID:      id,
+		ObjectKey:   objectID,
+		URL:         photoURL,
+		ContentType: "image/png",
+		Size:        int64(len(photo)),
+	})
+	st.Require().NoError(err, "failed to record photo")
+	post, err := st.repo.Post.Create(ctx, id, types.CreatePostReq{Content: gofakeit.Sentence(5), Media: []types.PostMediaReq{{FileID: file.ID}}}, nil)
+	st.Require().NoError(err, "failed to create post")
+	st.Require().NoError(st.repo.Like.LikePost(ctx, post.ID, id), "failed to like post")
+
+	// an upload that is on no post still belongs in the archive
+	draftURL, err := st.s3.Create(types.File{Name: "draft.png", Payload: bytes.NewReader(photo), Size: int64(len(photo))})
+	st.Require().NoError(err, "failed to upload draft")
+	draftID, _ := st.s3.ObjectID(draftURL)
+	_, err = st.repo.File.Create(ctx, types.FileRecord{
+		UserID:      id,
+		ObjectKey:   draftID,
+		URL:         draftURL,
+		ContentType: "image/png",
+		Size:        int64(len(photo)),
+	})
+	st.Require().NoError(err, "failed to record draft")
+
+	export, err := st.svc.Request(ctx, *user)
+	st.Require().NoError(err, "failed to request export")
+	st.Equal(types.ExportStatusPending, export.Status)
+	again, err := st.svc.Request(ctx, *user)
+	st.Require().NoError(err, "failed to request export")
+	st.Equal(export.ID, again.ID, "expected a pending export to be reused")
+	st.svc.Wait()
+
+	_, err = st.svc.Get(ctx, export.ID, uuid.New())
+	st.ErrorIs(err, repoerrs.ErrExportNotFound, "expected exports of others to be hidden")
+
+	export, err = st.svc.Get(ctx, export.ID, id)
+	st.Require().NoError(err, "failed to get export")
+	st.Require().Equal(types.ExportStatusReady, export.Status)
+	st.Require().NotNil(export.DownloadURL, "expected to get a download link")
+	_, ok := st.mailer.Last(user.Email)
+	st.True(ok, "expected the user to be mailed")
+
+	res, err := http.Get(*export.DownloadURL)
+	st.Require().NoError(err, "failed to download export")
+	defer res.Body.Close()
+	st.Require().Equal(http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	st.Require().NoError(err, "failed to read export")
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	st.Require().NoError(err, "expected the export to be a zip archive")
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	st.ElementsMatch([]string{
+		"profile.json",
+		"posts.json",
+		"comments.json",
+		"likes.json",
+		"media/" + objectID,
+		"media/" + draftID,
+	}, names)
+}
+
+func TestExportService(t *testing.T) {
+	suite.Run(t, new(exportServiceSuite))
+}
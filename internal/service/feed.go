@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/escoutdoor/social/internal/cache"
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type FeedMode string
+
+const (
+	// FeedModeRead builds the feed with a join over the follow graph on
+	// every request.
+	FeedModeRead FeedMode = "read"
+	// FeedModeWrite pushes new posts into a Redis timeline of every follower
+	// and reads the feed from there.
+	FeedModeWrite FeedMode = "write"
+)
+
+func (m FeedMode) Valid() bool {
+	return m == FeedModeRead || m == FeedModeWrite
+}
+
+type FeedOpts struct {
+	Mode FeedMode
+	// TimelineSize caps the number of posts kept in a Redis timeline. Older
+	// pages are read from the database.
+	TimelineSize int
+	// TimelineTTL is how long the timeline of an inactive user is kept.
+	TimelineTTL time.Duration
+}
+
+var DefaultFeedOpts = FeedOpts{
+	Mode:         FeedModeRead,
+	TimelineSize: 800,
+	TimelineTTL:  time.Hour * 24 * 7,
+}
+
+// FeedService serves the home timeline. A nil *FeedService ignores post
+// and follow events, which is what fan-out-on-read needs anyway.
+type FeedService struct {
+	repo       repository.Post
+	followRepo repository.Follow
+	cache      cache.Repository
+	opts       FeedOpts
+}
+
+func NewFeedService(repo repository.Post, followRepo repository.Follow, cache cache.Repository, opts FeedOpts) *FeedService {
+	return &FeedService{
+		repo:       repo,
+		followRepo: followRepo,
+		cache:      cache,
+		opts:       opts,
+	}
+}
+
+// Get returns a page of posts of the user and the accounts the user follows,
+// newest first.
+func (s *FeedService) Get(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.Post], error) {
+	query := page.Query()
+
+	var (
+		posts []types.Post
+		err   error
+	)
+	if s.opts.Mode == FeedModeWrite {
+		posts, err = s.getFromTimeline(ctx, userID, query)
+	} else {
+		posts, err = s.repo.GetFeed(ctx, userID, query)
+	}
+	if err != nil {
+		return types.Page[types.Post]{}, err
+	}
+	return types.NewPage(posts, page.Limit, func(i int) types.Cursor {
+		return posts[i].Cursor()
+	}), nil
+}
+
+// getFromTimeline reads post IDs from the Redis timeline and loads the posts.
+// Timelines that expired are rebuilt first.
+func (s *FeedService) getFromTimeline(ctx context.Context, userID uuid.UUID, page types.CursorPage) ([]types.Post, error) {
+	entries, err := s.timelineEntries(ctx, userID, page)
+	if errors.Is(err, redis.Nil) {
+		if err := s.rebuild(ctx, userID); err != nil {
+			return nil, err
+		}
+		entries, err = s.timelineEntries(ctx, userID, page)
+	}
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	// the timeline is capped, so pages past its end come from the database,
+	// as do pages pointing at posts deleted in the meantime
+	if len(entries) < page.Limit {
+		return s.repo.GetFeed(ctx, userID, page)
+	}
+
+	ids := make([]uuid.UUID, len(entries))
+	for i, e := range entries {
+		ids[i] = e.PostID
+	}
+	posts, err := s.repo.GetByIDs(ctx, ids, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) < len(ids) {
+		return s.repo.GetFeed(ctx, userID, page)
+	}
+	return posts, nil
+}
+
+// timelineEntries returns up to page.Limit timeline entries after the
+// cursor.
+func (s *FeedService) timelineEntries(ctx context.Context, userID uuid.UUID, page types.CursorPage) ([]types.TimelineEntry, error) {
+	var before *time.Time
+	if page.After != nil {
+		before = &page.After.CreatedAt
+	}
+
+	var entries []types.TimelineEntry
+	for offset := 0; len(entries) < page.Limit; {
+		batch, err := s.cache.GetTimeline(ctx, userID, before, offset, page.Limit, s.opts.TimelineTTL)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range batch {
+			// entries sharing the timestamp of the cursor come first and are
+			// only wanted when they sort after it
+			if page.After != nil && e.CreatedAt.Equal(page.After.CreatedAt) && e.PostID.String() >= page.After.ID.String() {
+				continue
+			}
+			entries = append(entries, e)
+		}
+		if len(batch) < page.Limit {
+			break
+		}
+		offset += len(batch)
+	}
+	if len(entries) > page.Limit {
+		entries = entries[:page.Limit]
+	}
+	return entries, nil
+}
+
+// rebuild fills the timeline of the user from the database.
+func (s *FeedService) rebuild(ctx context.Context, userID uuid.UUID) error {
+	posts, err := s.repo.GetFeed(ctx, userID, types.CursorPage{Limit: s.opts.TimelineSize})
+	if err != nil {
+		return err
+	}
+	return s.cache.SetTimeline(ctx, userID, timelineEntries(posts), s.opts.TimelineTTL)
+}
+
+// PostCreated fans the post out to the timelines of the author and of every
+// follower, or only of the followers it mentions if it is mentioned-only.
+func (s *FeedService) PostCreated(ctx context.Context, post types.Post) error {
+	if s == nil || s.opts.Mode != FeedModeWrite {
+		return nil
+	}
+	followers, err := s.followRepo.GetFollowerIDs(ctx, post.UserID)
+	if err != nil {
+		return err
+	}
+	if post.Visibility == types.PostVisibilityMentioned {
+		followers = mentioned(followers, post.MentionIDs)
+	}
+	userIDs := append(followers, post.UserID)
+	return s.cache.PushToTimelines(ctx, userIDs, []types.TimelineEntry{post.TimelineEntry()}, s.opts.TimelineSize)
+}
+
+func (s *FeedService) PostDeleted(ctx context.Context, post types.Post) error {
+	if s == nil || s.opts.Mode != FeedModeWrite {
+		return nil
+	}
+	followers, err := s.followRepo.GetFollowerIDs(ctx, post.UserID)
+	if err != nil {
+		return err
+	}
+	userIDs := append(followers, post.UserID)
+	return s.cache.RemoveFromTimelines(ctx, userIDs, []uuid.UUID{post.ID})
+}
+
+// Followed backfills the timeline of the follower with recent posts of the
+// account they started following. Mentioned-only posts are left to the next
+// rebuild of the timeline, which knows whom they mention.
+func (s *FeedService) Followed(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	if s == nil || s.opts.Mode != FeedModeWrite {
+		return nil
+	}
+	posts, err := s.repo.GetRecentByUser(ctx, followeeID, s.opts.TimelineSize)
+	if err != nil {
+		return err
+	}
+	shown := make([]types.Post, 0, len(posts))
+	for _, p := range posts {
+		if p.Visibility != types.PostVisibilityMentioned {
+			shown = append(shown, p)
+		}
+	}
+	return s.cache.PushToTimelines(ctx, []uuid.UUID{followerID}, timelineEntries(shown), s.opts.TimelineSize)
+}
+
+func (s *FeedService) Unfollowed(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	if s == nil || s.opts.Mode != FeedModeWrite {
+		return nil
+	}
+	posts, err := s.repo.GetRecentByUser(ctx, followeeID, s.opts.TimelineSize)
+	if err != nil {
+		return err
+	}
+	ids := make([]uuid.UUID, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	return s.cache.RemoveFromTimelines(ctx, []uuid.UUID{followerID}, ids)
+}
+
+// Unmuted rebuilds the timeline of the muter, which brings back the posts
+// of the unmuted user if the muter follows them.
+func (s *FeedService) Unmuted(ctx context.Context, muterID uuid.UUID) error {
+	if s == nil || s.opts.Mode != FeedModeWrite {
+		return nil
+	}
+	return s.rebuild(ctx, muterID)
+}
+
+func timelineEntries(posts []types.Post) []types.TimelineEntry {
+	entries := make([]types.TimelineEntry, len(posts))
+	for i, p := range posts {
+		entries[i] = p.TimelineEntry()
+	}
+	return entries
+}
+
+// mentioned returns the users in userIDs that are also in mentionIDs.
+func mentioned(userIDs []uuid.UUID, mentionIDs []uuid.UUID) []uuid.UUID {
+	isMentioned := make(map[uuid.UUID]bool, len(mentionIDs))
+	for _, id := range mentionIDs {
+		isMentioned[id] = true
+	}
+	var ids []uuid.UUID
+	for _, id := range userIDs {
+		if isMentioned[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
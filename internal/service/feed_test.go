@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/testutils"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+type feedServiceSuite struct {
+	suite.Suite
+	container      testcontainers.Container
+	redisContainer testcontainers.Container
+	authSvc        Auth
+	mode           FeedMode
+	svc            *FeedService
+	postSvc        Post
+	followSvc      Follow
+}
+
+func (st *feedServiceSuite) SetupSuite() {
+	container, db, err := testutils.NewPostgresContainer()
+	st.Require().NoError(err, "failed to run postgres container")
+	st.Require().NotEmpty(db, "expected to get db connection")
+
+	redisContainer, c, err := testutils.NewRedisContainer()
+	st.Require().NoError(err, "failed to run redis container")
+	st.Require().NotEmpty(c, "expected to get redis connection")
+
+	repo := repository.New(db)
+
+	st.container = container
+	st.redisContainer = redisContainer
+	st.svc = NewFeedService(repo.Post, repo.Follow, c, FeedOpts{
+		Mode:         st.mode,
+		TimelineSize: 5,
+		TimelineTTL:  time.Minute,
+	})
+	st.postSvc = NewPostService(repo.Post, repo.File, c, NewModerationService(repo.Moderation), st.svc, nil)
+	st.followSvc = NewFollowService(repo.Follow, repo.User, nil, st.svc)
+	st.authSvc = newAuthService(repo, NewMemoryMailer())
+}
+
+func (st *feedServiceSuite) TearDownSuite() {
+	err := st.container.Terminate(context.Background())
+	st.Require().NoError(err, "failed to terminate postgres container")
+
+	err = st.redisContainer.Terminate(context.Background())
+	st.Require().NoError(err, "failed to terminate redis container")
+}
+
+func (st *feedServiceSuite) createPosts(ctx context.Context, userID uuid.UUID, n int) []uuid.UUID {
+	ids := make([]uuid.UUID, n)
+	for i := range ids {
+		post, err := st.postSvc.Create(ctx, userID, types.CreatePostReq{Content: gofakeit.Sentence(5)})
+		st.Require().NoError(err, "failed to create post")
+		ids[i] = post.ID
+	}
+	return ids
+}
+
+// readAll pages through the whole feed and returns the post IDs.
+func (st *feedServiceSuite) readAll(ctx context.Context, userID uuid.UUID, limit int) []uuid.UUID {
+	var (
+		ids  []uuid.UUID
+		page = types.CursorPage{Limit: limit}
+	)
+	for {
+		posts, err := st.svc.Get(ctx, userID, page)
+		st.Require().NoError(err, "failed to get feed")
+		for _, p := range posts.Items {
+			ids = append(ids, p.ID)
+		}
+		if posts.NextCursor == nil {
+			return ids
+		}
+		page.After = posts.NextCursor
+	}
+}
+
+func (st *feedServiceSuite) TestFeed() {
+	ctx := context.Background()
+	alice, bob, carol := signUp(st.T(), ctx, st.authSvc), signUp(st.T(), ctx, st.authSvc), signUp(st.T(), ctx, st.authSvc)
+
+	st.createPosts(ctx, carol, 2)
+	own := st.createPosts(ctx, alice, 2)
+	_, err := st.followSvc.Follow(ctx, alice, bob)
+	st.Require().NoError(err, "failed to follow")
+	st.Equal(reversed(own), st.readAll(ctx, alice, 3))
+
+	// more posts than fit into a timeline
+	followed := st.createPosts(ctx, bob, 6)
+
+	feed := st.readAll(ctx, alice, 3)
+	st.Len(feed, 8, "expected own and followed posts only")
+	st.Equal(reversed(followed), feed[:6], "expected newest posts first")
+	st.Equal(reversed(own), feed[6:])
+
+	st.Require().NoError(st.followSvc.Unfollow(ctx, alice, bob), "failed to unfollow")
+	st.Equal(reversed(own), st.readAll(ctx, alice, 3), "expected posts of unfollowed users to be gone")
+
+	_, err = st.followSvc.Follow(ctx, alice, carol)
+	st.Require().NoError(err, "failed to follow")
+	st.Len(st.readAll(ctx, alice, 10), 4, "expected posts of followed user to be backfilled")
+}
+
+func (st *feedServiceSuite) TestDeletedPost() {
+	ctx := context.Background()
+	alice, bob := signUp(st.T(), ctx, st.authSvc), signUp(st.T(), ctx, st.authSvc)
+	_, err := st.followSvc.Follow(ctx, alice, bob)
+	st.Require().NoError(err, "failed to follow")
+
+	ids := st.createPosts(ctx, bob, 3)
+	st.Len(st.readAll(ctx, alice, 10), 3)
+
+	err = st.postSvc.Delete(ctx, ids[0], types.Actor{ID: bob, Role: types.RoleUser})
+	st.Require().NoError(err, "failed to delete post")
+	st.Equal(reversed(ids[1:]), st.readAll(ctx, alice, 10), "expected deleted post to be gone")
+}
+
+func reversed(ids []uuid.UUID) []uuid.UUID {
+	out := make([]uuid.UUID, len(ids))
+	for i, id := range ids {
+		out[len(ids)-1-i] = id
+	}
+	return out
+}
+
+func TestFeedService(t *testing.T) {
+	t.Run("read", func(t *testing.T) {
+		suite.Run(t, &feedServiceSuite{mode: FeedModeRead})
+	})
+	t.Run("write", func(t *testing.T) {
+		suite.Run(t, &feedServiceSuite{mode: FeedModeWrite})
+	})
+}
@@ -1,34 +1,335 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
+	"log/slog"
 	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
 
+	_ "golang.org/x/image/webp"
+
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
 	"github.com/escoutdoor/social/internal/s3"
 	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/imaging"
+	"github.com/google/uuid"
 )
 
+// UploadTypes maps the content types uploads can have to the file extensions
+// they go by. The first extension is the one objects are stored with.
+var UploadTypes = map[string][]string{
+	"image/jpeg": {".jpg", ".jpeg"},
+	"image/png":  {".png"},
+	"image/webp": {".webp"},
+	"image/gif":  {".gif"},
+	"video/mp4":  {".mp4"},
+}
+
+type UploadOpts struct {
+	// MaxSizes is the allow-list of upload content types, each with the
+	// largest size in bytes a file of that type may have. Every type must be
+	// one of UploadTypes.
+	MaxSizes map[string]int64
+	// Renditions maps the names of the scaled down copies made of uploaded
+	// images to the size in pixels their longer side is scaled down to.
+	Renditions map[string]int
+	// JPEGQuality is the quality renditions are encoded with, from 1 to 100.
+	JPEGQuality int
+}
+
+var DefaultUploadOpts = UploadOpts{
+	MaxSizes: map[string]int64{
+		"image/jpeg": 10 << 20,
+		"image/png":  10 << 20,
+		"image/webp": 10 << 20,
+		"image/gif":  10 << 20,
+		"video/mp4":  100 << 20,
+	},
+	Renditions: map[string]int{
+		"avatar": 128,
+		"small":  640,
+		"large":  1280,
+	},
+	JPEGQuality: 85,
+}
+
 type FileService struct {
-	s3 s3.Repository
+	repo repository.File
+	s3   s3.Repository
+	opts UploadOpts
 }
 
-func NewFileService(s3 s3.Repository) *FileService {
+func NewFileService(repo repository.File, s3 s3.Repository, opts UploadOpts) *FileService {
 	return &FileService{
-		s3: s3,
+		repo: repo,
+		s3:   s3,
+		opts: opts,
 	}
 }
 
-func (s *FileService) Create(ctx context.Context, src io.Reader, hdr *multipart.FileHeader) (string, error) {
+// MaxSize returns the size of the largest file that may be uploaded.
+func (s *FileService) MaxSize() int64 {
+	var size int64
+	for _, limit := range s.opts.MaxSizes {
+		size = max(size, limit)
+	}
+	return size
+}
+
+// Create uploads the file and records it with the user as its owner, which
+// lets them attach it to their posts. The content type is sniffed from the
+// content rather than trusted from the client, and must be allowed and agree
+// with the extension of the file name. Images are stored without their
+// metadata, together with their renditions.
+func (s *FileService) Create(ctx context.Context, userID uuid.UUID, src io.ReadSeeker, hdr *multipart.FileHeader) (*types.FileRecord, error) {
+	contentType, err := sniffContentType(src)
+	if err != nil {
+		return nil, err
+	}
+	maxSize, ok := s.opts.MaxSizes[contentType]
+	if !ok {
+		return nil, ErrUnsupportedFileType
+	}
+	if hdr.Size > maxSize {
+		return nil, ErrFileTooLarge
+	}
+	exts := UploadTypes[contentType]
+	if ext := strings.ToLower(filepath.Ext(hdr.Filename)); ext != "" && !slices.Contains(exts, ext) {
+		return nil, ErrFileExtensionMismatch
+	}
+
+	record := types.FileRecord{
+		UserID:      userID,
+		ContentType: contentType,
+		Size:        hdr.Size,
+	}
 	f := types.File{
-		Name:    hdr.Filename,
-		Payload: src,
-		Size:    hdr.Size,
+		Name:        hdr.Filename,
+		Payload:     src,
+		Size:        hdr.Size,
+		ContentType: contentType,
+		Ext:         exts[0],
+	}
+	var img *uploadedImage
+	if strings.HasPrefix(contentType, "image/") {
+		img, err = readImage(src, maxSize)
+		if err != nil {
+			return nil, err
+		}
+		record.Size = int64(len(img.data))
+		record.Width, record.Height = &img.width, &img.height
+		f.Payload = bytes.NewReader(img.data)
+		f.Size = record.Size
 	}
 
+	// the checksum is taken while the file streams to S3
+	hash := sha256.New()
+	f.Payload = io.TeeReader(f.Payload, hash)
 	url, err := s.s3.Create(f)
 	if err != nil {
+		return nil, err
+	}
+	key, ok := s.s3.ObjectID(url)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object url %q", url)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	if img != nil {
+		record.Renditions, err = s.createRenditions(img)
+		if err != nil {
+			s.deleteObjects(url, record.Renditions)
+			return nil, err
+		}
+	}
+	record.ObjectKey = key
+	record.URL = url
+	record.SHA256 = &sum
+	file, err := s.repo.Create(ctx, record)
+	if err != nil {
+		// nothing refers to the objects
+		s.deleteObjects(url, record.Renditions)
+		return nil, err
+	}
+	return file, nil
+}
+
+// maxImagePixels bounds the size of images, which are decoded in full to make
+// their renditions. A small file can hold a huge image.
+const maxImagePixels = 50_000_000
+
+// uploadedImage is an image as it is stored, with the metadata stripped.
+type uploadedImage struct {
+	data        []byte
+	orientation int
+	// width and height are the size the image is shown at, i.e. after it
+	// is turned upright
+	width, height int
+}
+
+// readImage reads an uploaded image and strips its metadata.
+func readImage(src io.Reader, maxSize int64) (*uploadedImage, error) {
+	// the size in the header is what the client claims
+	data, err := io.ReadAll(io.LimitReader(src, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, ErrFileTooLarge
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFileType
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return nil, ErrFileTooLarge
+	}
+	if data, err = imaging.StripMetadata(format, data); err != nil {
+		return nil, ErrUnsupportedFileType
+	}
+
+	img := &uploadedImage{
+		data:        data,
+		orientation: imaging.OrientationNormal,
+		width:       cfg.Width,
+		height:      cfg.Height,
+	}
+	if format == "jpeg" {
+		img.orientation = imaging.Orientation(data)
+	}
+	if img.orientation >= 5 {
+		img.width, img.height = img.height, img.width
+	}
+	return img, nil
+}
+
+// createRenditions uploads a JPEG of the image for every configured
+// rendition. On failure it still returns the renditions it uploaded.
+func (s *FileService) createRenditions(img *uploadedImage) (types.Renditions, error) {
+	if len(s.opts.Renditions) == 0 {
+		return nil, nil
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(img.data))
+	if err != nil {
+		return nil, ErrUnsupportedFileType
+	}
+
+	// each rendition is scaled from the next larger one, which is a lot
+	// cheaper than scaling the original every time
+	names := make([]string, 0, len(s.opts.Renditions))
+	for name := range s.opts.Renditions {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return s.opts.Renditions[b] - s.opts.Renditions[a]
+	})
+	renditions := make(types.Renditions, len(names))
+	for _, name := range names {
+		scaled := imaging.Fit(decoded, s.opts.Renditions[name])
+		decoded = scaled
+
+		// the size bounds both sides, so it does not matter that the image
+		// is turned after it is scaled
+		var buf bytes.Buffer
+		if err := imaging.EncodeJPEG(&buf, imaging.Orient(scaled, img.orientation), s.opts.JPEGQuality); err != nil {
+			return renditions, err
+		}
+		url, err := s.s3.Create(types.File{
+			Name:        name + ".jpg",
+			Payload:     &buf,
+			Size:        int64(buf.Len()),
+			ContentType: "image/jpeg",
+			Ext:         ".jpg",
+		})
+		if err != nil {
+			return renditions, err
+		}
+		renditions[name] = url
+	}
+	return renditions, nil
+}
+
+// deleteObjects removes an upload and its renditions, logging failures.
+func (s *FileService) deleteObjects(url string, renditions types.Renditions) {
+	urls := []string{url}
+	for _, url := range renditions {
+		urls = append(urls, url)
+	}
+	for _, url := range urls {
+		id, ok := s.s3.ObjectID(url)
+		if !ok {
+			continue
+		}
+		if err := s.s3.Delete(id); err != nil {
+			slog.Error("FileService.deleteObjects - S3.Delete", "object", id, "error", err)
+		}
+	}
+}
+
+// sniffContentType detects the content type from the first bytes of src and
+// rewinds it.
+func sniffContentType(src io.ReadSeeker) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
 		return "", err
 	}
-	return url, nil
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+	return contentType, nil
+}
+
+// GetByID returns a file of the user. Files of others are reported as
+// missing.
+func (s *FileService) GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*types.FileRecord, error) {
+	file, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if file.UserID != userID {
+		return nil, repoerrs.ErrFileNotFound
+	}
+	return file, nil
+}
+
+// Delete removes a file of the user and its renditions from S3 and then its
+// record, which detaches it from the posts it was on.
+func (s *FileService) Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	file, err := s.GetByID(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	// the object is found from the URL rather than the stored key, so a
+	// record that points elsewhere never deletes an object of someone else
+	if key, ok := s.s3.ObjectID(file.URL); ok {
+		if err := s.s3.Delete(key); err != nil {
+			return fmt.Errorf("failed to delete object: %w", err)
+		}
+	}
+	for _, url := range file.Renditions {
+		key, ok := s.s3.ObjectID(url)
+		if !ok {
+			continue
+		}
+		if err := s.s3.Delete(key); err != nil {
+			return fmt.Errorf("failed to delete rendition: %w", err)
+		}
+	}
+	return s.repo.Delete(ctx, id)
 }
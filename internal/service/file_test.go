@@ -3,10 +3,20 @@ package service
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
 	"mime/multipart"
+	"strings"
 	"testing"
 
-	"github.com/brianvoe/gofakeit/v7"
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/s3"
 	"github.com/escoutdoor/social/internal/testutils"
 	"github.com/stretchr/testify/suite"
 	"github.com/testcontainers/testcontainers-go"
@@ -14,51 +24,186 @@ import (
 
 type fileServiceSuite struct {
 	suite.Suite
-	container testcontainers.Container
-	svc       File
+	container      testcontainers.Container
+	minioContainer testcontainers.Container
+	s3             s3.Repository
+	svc            File
+	authSvc        Auth
 }
 
 func (st *fileServiceSuite) SetupSuite() {
-	container, s3, err := testutils.NewMinIOContainer()
+	container, db, err := testutils.NewPostgresContainer()
+	st.Require().NoError(err, "failed to run postgres container")
+	st.Require().NotEmpty(db, "expected to get db connection")
+
+	minioContainer, s3, err := testutils.NewMinIOContainer()
 	st.Require().NoError(err, "failed to run minio container")
-	st.Require().NotEmpty(container, "expected to get minio container")
+	st.Require().NotEmpty(minioContainer, "expected to get minio container")
 	st.Require().NotEmpty(s3, "expected to get minio connection")
 
+	repo := repository.New(db)
 	st.container = container
-	st.svc = NewFileService(s3)
+	st.minioContainer = minioContainer
+	st.s3 = s3
+	st.svc = NewFileService(repo.File, s3, DefaultUploadOpts)
+	st.authSvc = newAuthService(repo, NewMemoryMailer())
 }
 
 func (st *fileServiceSuite) TearDownSuite() {
 	err := st.container.Terminate(context.Background())
 	st.Require().NoError(err, "failed to terminate postgres container")
+
+	err = st.minioContainer.Terminate(context.Background())
+	st.Require().NoError(err, "failed to terminate minio container")
 }
 
 func (st *fileServiceSuite) TestCreate() {
-	var (
-		body    bytes.Buffer
-		content = []byte("wassup")
-		ctx     = context.Background()
-	)
-
-	mw := multipart.NewWriter(&body)
-	fw, err := mw.CreateFormFile("file", "file.jpg")
-	st.NoError(err, "failed to create form file")
-
-	n, err := fw.Write(content)
-	st.NoError(err, "failed to write content into file")
-	st.Equal(len(content), n)
-
-	err = mw.Close()
-	st.NoError(err, "failed to close writer")
-
-	hdr := &multipart.FileHeader{
-		Filename: gofakeit.BeerName(),
-		Size:     int64(len(body.Bytes())),
+	ctx := context.Background()
+	userID := signUp(st.T(), ctx, st.authSvc)
+
+	content := st.pngImage(3, 2)
+
+	file, err := st.svc.Create(ctx, userID, bytes.NewReader(content), newFileHeader("photo.PNG", content))
+	st.Require().NoError(err, "failed to store photo into s3")
+	st.NotEmpty(file.URL, "expected to get photo url")
+	st.Equal(userID, file.UserID, "expected the uploader to own the file")
+	st.Equal("image/png", file.ContentType)
+	st.True(strings.HasSuffix(file.ObjectKey, ".png"), "expected the object key to have the extension of the type")
+	st.Equal(int64(len(content)), file.Size)
+	sum := sha256.Sum256(content)
+	st.Equal(hex.EncodeToString(sum[:]), *file.SHA256)
+	st.Require().NotNil(file.Width, "expected image dimensions")
+	st.Equal(3, *file.Width)
+	st.Equal(2, *file.Height)
+
+	st.Require().Len(file.Renditions, len(DefaultUploadOpts.Renditions), "expected a rendition of every size")
+	for name := range DefaultUploadOpts.Renditions {
+		cfg := st.openJPEG(file.Renditions[name])
+		st.Equal(3, cfg.Width, "expected %s not to be scaled up", name)
+		st.Equal(2, cfg.Height, "expected %s not to be scaled up", name)
+	}
+}
+
+func (st *fileServiceSuite) TestCreateStripsMetadata() {
+	ctx := context.Background()
+	userID := signUp(st.T(), ctx, st.authSvc)
+
+	// a phone photo held upright: stored sideways and turned by EXIF
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 400, 300)), nil)
+	st.Require().NoError(err, "failed to encode image")
+	content := append([]byte{0xFF, 0xD8}, exifSegment(6, "GPS 50.4501N 30.5234E")...)
+	content = append(content, buf.Bytes()[2:]...)
+
+	file, err := st.svc.Create(ctx, userID, bytes.NewReader(content), newFileHeader("photo.jpg", content))
+	st.Require().NoError(err, "failed to create file")
+	st.Equal(300, *file.Width, "expected the size the photo is shown at")
+	st.Equal(400, *file.Height, "expected the size the photo is shown at")
+
+	src, err := st.s3.Open(ctx, file.ObjectKey)
+	st.Require().NoError(err, "failed to open the original")
+	defer src.Close()
+	stored, err := io.ReadAll(src)
+	st.Require().NoError(err, "failed to read the original")
+	st.NotContains(string(stored), "GPS", "expected the location to be stripped")
+	st.Equal(int64(len(stored)), file.Size)
+
+	cfg := st.openJPEG(file.Renditions["avatar"])
+	st.Equal(96, cfg.Width, "expected the rendition to be upright")
+	st.Equal(128, cfg.Height, "expected the rendition to be upright")
+}
+
+func (st *fileServiceSuite) TestGetAndDelete() {
+	ctx := context.Background()
+	owner, stranger := signUp(st.T(), ctx, st.authSvc), signUp(st.T(), ctx, st.authSvc)
+
+	content := st.pngImage(1, 1)
+	file, err := st.svc.Create(ctx, owner, bytes.NewReader(content), newFileHeader("photo.png", content))
+	st.Require().NoError(err, "failed to create file")
+
+	_, err = st.svc.GetByID(ctx, file.ID, stranger)
+	st.ErrorIs(err, repoerrs.ErrFileNotFound, "expected files of others to be hidden")
+	err = st.svc.Delete(ctx, file.ID, stranger)
+	st.ErrorIs(err, repoerrs.ErrFileNotFound, "expected files of others to be kept")
+
+	got, err := st.svc.GetByID(ctx, file.ID, owner)
+	st.Require().NoError(err, "failed to get file")
+	st.Equal(file.ID, got.ID)
+
+	st.Require().NoError(st.svc.Delete(ctx, file.ID, owner), "failed to delete file")
+	_, err = st.svc.GetByID(ctx, file.ID, owner)
+	st.ErrorIs(err, repoerrs.ErrFileNotFound, "expected file to be deleted")
+	_, err = st.s3.Open(ctx, file.ObjectKey)
+	st.ErrorIs(err, s3.ErrObjectNotFound, "expected the object to be removed")
+	for _, url := range file.Renditions {
+		key, _ := st.s3.ObjectID(url)
+		_, err = st.s3.Open(ctx, key)
+		st.ErrorIs(err, s3.ErrObjectNotFound, "expected the renditions to be removed")
+	}
+}
+
+func (st *fileServiceSuite) TestCreateRejected() {
+	ctx := context.Background()
+	userID := signUp(st.T(), ctx, st.authSvc)
+
+	text := []byte("wassup")
+	_, err := st.svc.Create(ctx, userID, bytes.NewReader(text), newFileHeader("photo.png", text))
+	st.ErrorIs(err, ErrUnsupportedFileType, "expected the sniffed type to count")
+
+	content := st.pngImage(1, 1)
+	_, err = st.svc.Create(ctx, userID, bytes.NewReader(content), newFileHeader("photo.jpg", content))
+	st.ErrorIs(err, ErrFileExtensionMismatch)
+
+	hdr := newFileHeader("photo.png", content)
+	hdr.Size = DefaultUploadOpts.MaxSizes["image/png"] + 1
+	_, err = st.svc.Create(ctx, userID, bytes.NewReader(content), hdr)
+	st.ErrorIs(err, ErrFileTooLarge)
+}
+
+func (st *fileServiceSuite) pngImage(width, height int) []byte {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)))
+	st.Require().NoError(err, "failed to encode image")
+	return buf.Bytes()
+}
+
+// openJPEG reads the size of a JPEG in the media bucket.
+func (st *fileServiceSuite) openJPEG(url string) image.Config {
+	key, ok := st.s3.ObjectID(url)
+	st.Require().True(ok, "expected an object of ours")
+	src, err := st.s3.Open(context.Background(), key)
+	st.Require().NoError(err, "failed to open object")
+	defer src.Close()
+	cfg, err := jpeg.DecodeConfig(src)
+	st.Require().NoError(err, "expected a jpeg")
+	return cfg
+}
+
+func newFileHeader(name string, content []byte) *multipart.FileHeader {
+	return &multipart.FileHeader{
+		Filename: name,
+		Size:     int64(len(content)),
 	}
+}
+
+// exifSegment returns a JPEG APP1 segment with the EXIF orientation followed
+// by note, which stands in for the rest of the metadata.
+func exifSegment(orientation int, note string) []byte {
+	tiff := []byte("MM\x00\x2A")
+	tiff = binary.BigEndian.AppendUint32(tiff, 8)
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(orientation))
+	tiff = binary.BigEndian.AppendUint16(tiff, 0)
+	tiff = binary.BigEndian.AppendUint32(tiff, 0)
+	tiff = append(tiff, note...)
 
-	url, err := st.svc.Create(ctx, &body, hdr)
-	st.NoError(err, "failed to store photo into s3")
-	st.NotEmpty(url, "expected to get photo url")
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(2+6+len(tiff)))
+	segment = append(segment, "Exif\x00\x00"...)
+	return append(segment, tiff...)
 }
 
 func TestFileService(t *testing.T) {
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+)
+
+type FollowService struct {
+	repo     repository.Follow
+	userRepo repository.User
+	blocks   *BlockService
+	feed     *FeedService
+}
+
+func NewFollowService(repo repository.Follow, userRepo repository.User, blocks *BlockService, feed *FeedService) *FollowService {
+	return &FollowService{
+		repo:     repo,
+		userRepo: userRepo,
+		blocks:   blocks,
+		feed:     feed,
+	}
+}
+
+// Follow follows the user, or sends a follow request when the account is
+// private.
+func (s *FollowService) Follow(ctx context.Context, followerID uuid.UUID, followeeID uuid.UUID) (types.FollowStatus, error) {
+	if err := s.blocks.check(ctx, followerID, followeeID); err != nil {
+		return "", err
+	}
+	followee, err := s.userRepo.GetByID(ctx, followeeID)
+	if err != nil {
+		return "", err
+	}
+	if followee.IsDeactivated() {
+		return "", repoerrs.ErrUserNotFound
+	}
+
+	if followee.IsPrivate && followerID != followeeID {
+		following, err := s.repo.IsFollowing(ctx, followerID, followeeID)
+		if err != nil {
+			return "", err
+		}
+		if following {
+			return "", repoerrs.ErrAlreadyFollowing
+		}
+		if err := s.repo.CreateRequest(ctx, followerID, followeeID); err != nil {
+			return "", err
+		}
+		return types.FollowStatusRequested, nil
+	}
+
+	if err := s.repo.Create(ctx, followerID, followeeID); err != nil {
+		return "", err
+	}
+	if err := s.feed.Followed(ctx, followerID, followeeID); err != nil {
+		slog.Error("FollowService.Follow - FeedService.Followed", "error", err)
+	}
+	return types.FollowStatusFollowing, nil
+}
+
+// Unfollow stops following the user or withdraws a pending follow request.
+func (s *FollowService) Unfollow(ctx context.Context, followerID uuid.UUID, followeeID uuid.UUID) error {
+	err := s.repo.Delete(ctx, followerID, followeeID)
+	if errors.Is(err, repoerrs.ErrNotFollowing) {
+		if err := s.repo.DeleteRequest(ctx, followerID, followeeID); err != nil {
+			if errors.Is(err, repoerrs.ErrFollowRequestNotFound) {
+				return repoerrs.ErrNotFollowing
+			}
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.feed.Unfollowed(ctx, followerID, followeeID); err != nil {
+		slog.Error("FollowService.Unfollow - FeedService.Unfollowed", "error", err)
+	}
+	return nil
+}
+
+func (s *FollowService) GetFollowers(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	if err := s.checkActive(ctx, userID); err != nil {
+		return types.Page[types.User]{}, err
+	}
+	return s.repo.GetFollowers(ctx, userID, page)
+}
+
+func (s *FollowService) GetFollowing(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	if err := s.checkActive(ctx, userID); err != nil {
+		return types.Page[types.User]{}, err
+	}
+	return s.repo.GetFollowing(ctx, userID, page)
+}
+
+func (s *FollowService) GetRequests(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	return s.repo.GetRequests(ctx, userID, page)
+}
+
+func (s *FollowService) ApproveRequest(ctx context.Context, userID uuid.UUID, requesterID uuid.UUID) error {
+	if err := s.repo.ApproveRequest(ctx, requesterID, userID); err != nil {
+		return err
+	}
+	if err := s.feed.Followed(ctx, requesterID, userID); err != nil {
+		slog.Error("FollowService.ApproveRequest - FeedService.Followed", "error", err)
+	}
+	return nil
+}
+
+func (s *FollowService) RejectRequest(ctx context.Context, userID uuid.UUID, requesterID uuid.UUID) error {
+	return s.repo.DeleteRequest(ctx, requesterID, userID)
+}
+
+// checkActive returns repoerrs.ErrUserNotFound unless the user exists and is
+// not deactivated.
+func (s *FollowService) checkActive(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.IsDeactivated() {
+		return repoerrs.ErrUserNotFound
+	}
+	return nil
+}
+
+// canView reports whether the viewer may see the content of the owner, which
+// for a private account takes being the owner or an approved follower. No one
+// else sees the content of a deactivated account. A nil *FollowService lets
+// everyone see everything.
+func (s *FollowService) canView(ctx context.Context, viewerID uuid.UUID, ownerID uuid.UUID) (bool, error) {
+	if s == nil || viewerID == ownerID {
+		return true, nil
+	}
+	owner, err := s.userRepo.GetByID(ctx, ownerID)
+	if err != nil {
+		return false, err
+	}
+	if owner.IsDeactivated() {
+		return false, nil
+	}
+	if !owner.IsPrivate {
+		return true, nil
+	}
+	return s.repo.IsFollowing(ctx, viewerID, ownerID)
+}
+
+// canViewPost reports whether the viewer may see the post. The visibility of
+// the post narrows what canView allows: a followers-only post takes following
+// the author and a mentioned-only post being mentioned in it.
+func (s *FollowService) canViewPost(ctx context.Context, postRepo repository.Post, viewerID uuid.UUID, post *types.Post) (bool, error) {
+	if s == nil || viewerID == post.UserID {
+		return true, nil
+	}
+	visible, err := s.canView(ctx, viewerID, post.UserID)
+	if err != nil || !visible {
+		return false, err
+	}
+	switch post.Visibility {
+	case types.PostVisibilityFollowers:
+		return s.repo.IsFollowing(ctx, viewerID, post.UserID)
+	case types.PostVisibilityMentioned:
+		return postRepo.IsMentioned(ctx, post.ID, viewerID)
+	default:
+		return true, nil
+	}
+}
+
+// visiblePost returns the post if the viewer may see it and
+// repoerrs.ErrPostNotFound otherwise.
+func visiblePost(ctx context.Context, postRepo repository.Post, follows *FollowService, postID uuid.UUID, viewerID uuid.UUID) (*types.Post, error) {
+	post, err := postRepo.GetByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	visible, err := follows.canViewPost(ctx, postRepo, viewerID, post)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, repoerrs.ErrPostNotFound
+	}
+	return post, nil
+}
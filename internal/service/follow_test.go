@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/testutils"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/validator"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+type followServiceSuite struct {
+	suite.Suite
+	container  testcontainers.Container
+	repo       *repository.Repository
+	svc        Follow
+	userSvc    User
+	commentSvc Comment
+	authSvc    Auth
+}
+
+func (st *followServiceSuite) SetupSuite() {
+	container, db, err := testutils.NewPostgresContainer()
+	st.Require().NoError(err, "failed to run container")
+	st.Require().NotEmpty(container, "expected to get non-empty container")
+	st.Require().NotEmpty(db, "expected to get non-empty db connection")
+
+	repo := repository.New(db)
+	mailer := NewMemoryMailer()
+
+	follows := NewFollowService(repo.Follow, repo.User, nil, nil)
+
+	st.container = container
+	st.repo = repo
+	st.svc = follows
+	st.userSvc = NewUserService(repo.User, repo.Follow, repo.File, nil, NewEmailVerifier(repo.EmailVerification, mailer, "http://localhost"), validator.New(), DefaultUsernameOpts)
+	st.commentSvc = NewCommentService(repo.Comment, repo.Post, NewModerationService(repo.Moderation), nil, follows)
+	st.authSvc = newAuthService(repo, mailer)
+}
+
+func (st *followServiceSuite) TearDownSuite() {
+	err := st.container.Terminate(context.Background())
+	st.Require().NoError(err, "failed to terminate postgres container")
+}
+
+func (st *followServiceSuite) TestFollowUnfollow() {
+	ctx := context.Background()
+	alice, bob := signUp(st.T(), ctx, st.authSvc), signUp(st.T(), ctx, st.authSvc)
+
+	status, err := st.svc.Follow(ctx, alice, bob)
+	st.Require().NoError(err, "failed to follow")
+	st.Equal(types.FollowStatusFollowing, status)
+
+	_, err = st.svc.Follow(ctx, alice, bob)
+	st.ErrorIs(err, repoerrs.ErrAlreadyFollowing, "expected duplicate follow to be rejected")
+
+	followers, err := st.svc.GetFollowers(ctx, bob, types.CursorPage{Limit: 20})
+	st.NoError(err, "failed to get followers")
+	st.Len(followers.Items, 1, "expected one follower")
+	st.Equal(alice, followers.Items[0].ID)
+
+	following, err := st.svc.GetFollowing(ctx, alice, types.CursorPage{Limit: 20})
+	st.NoError(err, "failed to get following")
+	st.Len(following.Items, 1, "expected to follow one user")
+	st.Equal(bob, following.Items[0].ID)
+
+	profile, err := st.userSvc.GetProfile(ctx, bob, alice)
+	st.Require().NoError(err, "failed to get profile")
+	st.Equal(1, *profile.FollowersCount)
+	st.Equal(0, *profile.FollowingCount)
+
+	err = st.svc.Unfollow(ctx, alice, bob)
+	st.NoError(err, "failed to unfollow")
+
+	err = st.svc.Unfollow(ctx, alice, bob)
+	st.ErrorIs(err, repoerrs.ErrNotFollowing, "expected unfollow without follow to fail")
+}
+
+func (st *followServiceSuite) TestSelfFollow() {
+	ctx := context.Background()
+	id := signUp(st.T(), ctx, st.authSvc)
+
+	_, err := st.svc.Follow(ctx, id, id)
+	st.ErrorIs(err, repoerrs.ErrSelfFollow, "expected self follow to be rejected")
+}
+
+func (st *followServiceSuite) TestFollowNotFound() {
+	ctx := context.Background()
+	id := signUp(st.T(), ctx, st.authSvc)
+
+	_, err := st.svc.Follow(ctx, id, uuid.New())
+	st.ErrorIs(err, repoerrs.ErrUserNotFound, "expected to get user not found error")
+
+	_, err = st.svc.GetFollowers(ctx, uuid.New(), types.CursorPage{Limit: 20})
+	st.ErrorIs(err, repoerrs.ErrUserNotFound, "expected to get user not found error")
+}
+
+func (st *followServiceSuite) TestPrivateAccount() {
+	ctx := context.Background()
+	alice, bob, carol := signUp(st.T(), ctx, st.authSvc), signUp(st.T(), ctx, st.authSvc), signUp(st.T(), ctx, st.authSvc)
+
+	user, err := st.userSvc.GetByID(ctx, bob)
+	st.Require().NoError(err, "failed to get user")
+	private := true
+	_, err = st.userSvc.Update(ctx, *user, types.UpdateUserReq{IsPrivate: &private})
+	st.Require().NoError(err, "failed to make account private")
+
+	post, err := st.repo.Post.Create(ctx, bob, types.CreatePostReq{Content: gofakeit.Dessert()}, nil)
+	st.Require().NoError(err, "failed to create post")
+
+	_, err = st.commentSvc.GetAll(ctx, post.ID, alice, types.CursorPage{Limit: 20})
+	st.ErrorIs(err, repoerrs.ErrPostNotFound, "expected post to be hidden from non-followers")
+	_, err = st.commentSvc.Create(ctx, alice, post.ID, types.CreateCommentReq{Content: gofakeit.Sentence(3)})
+	st.ErrorIs(err, repoerrs.ErrPostNotFound, "expected post to be hidden from non-followers")
+	_, err = st.commentSvc.GetAll(ctx, post.ID, bob, types.CursorPage{Limit: 20})
+	st.NoError(err, "expected the owner to see the post")
+
+	status, err := st.svc.Follow(ctx, alice, bob)
+	st.Require().NoError(err, "failed to request follow")
+	st.Equal(types.FollowStatusRequested, status)
+	_, err = st.svc.Follow(ctx, alice, bob)
+	st.ErrorIs(err, repoerrs.ErrAlreadyRequested, "expected duplicate request to be rejected")
+	_, err = st.svc.Follow(ctx, carol, bob)
+	st.Require().NoError(err, "failed to request follow")
+
+	requests, err := st.svc.GetRequests(ctx, bob, types.CursorPage{Limit: 20})
+	st.Require().NoError(err, "failed to get follow requests")
+	st.Len(requests.Items, 2, "expected two pending requests")
+
+	_, err = st.commentSvc.GetAll(ctx, post.ID, alice, types.CursorPage{Limit: 20})
+	st.ErrorIs(err, repoerrs.ErrPostNotFound, "expected pending requests to grant no access")
+
+	st.Require().NoError(st.svc.ApproveRequest(ctx, bob, alice), "failed to approve request")
+	st.Require().NoError(st.svc.RejectRequest(ctx, bob, carol), "failed to reject request")
+	err = st.svc.ApproveRequest(ctx, bob, carol)
+	st.ErrorIs(err, repoerrs.ErrFollowRequestNotFound, "expected rejected request to be gone")
+
+	_, err = st.commentSvc.GetAll(ctx, post.ID, alice, types.CursorPage{Limit: 20})
+	st.NoError(err, "expected approved followers to see the post")
+	_, err = st.commentSvc.GetAll(ctx, post.ID, carol, types.CursorPage{Limit: 20})
+	st.ErrorIs(err, repoerrs.ErrPostNotFound, "expected rejected users to be kept out")
+
+	followers, err := st.svc.GetFollowers(ctx, bob, types.CursorPage{Limit: 20})
+	st.Require().NoError(err, "failed to get followers")
+	st.Require().Len(followers.Items, 1, "expected one follower")
+	st.Equal(alice, followers.Items[0].ID)
+}
+
+func TestFollowService(t *testing.T) {
+	suite.Run(t, new(followServiceSuite))
+}
@@ -4,27 +4,43 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"time"
 
 	"github.com/escoutdoor/social/internal/cache"
 	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
 	"github.com/google/uuid"
-	"github.com/redis/go-redis/v9"
 )
 
 type LikeService struct {
-	repo  repository.Like
-	cache cache.Repository
+	repo        repository.Like
+	postRepo    repository.Post
+	commentRepo repository.Comment
+	blocks      *BlockService
+	follows     *FollowService
+	cache       cache.Repository
 }
 
-func NewLikeService(repo repository.Like, cache cache.Repository) *LikeService {
+func NewLikeService(repo repository.Like, postRepo repository.Post, commentRepo repository.Comment, blocks *BlockService, follows *FollowService, cache cache.Repository) *LikeService {
 	return &LikeService{
-		repo:  repo,
-		cache: cache,
+		repo:        repo,
+		postRepo:    postRepo,
+		commentRepo: commentRepo,
+		blocks:      blocks,
+		follows:     follows,
+		cache:       cache,
 	}
 }
 
 func (s *LikeService) LikePost(ctx context.Context, postID uuid.UUID, userID uuid.UUID) error {
+	post, err := visiblePost(ctx, s.postRepo, s.follows, postID, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.blocks.check(ctx, userID, post.UserID); err != nil {
+		return err
+	}
+
 	il, err := s.isPostLiked(ctx, postID)
 	if err != nil {
 		return err
@@ -38,23 +54,23 @@ func (s *LikeService) LikePost(ctx context.Context, postID uuid.UUID, userID uui
 		return err
 	}
 
-	key := generatePostKey(postID)
-	p, err := s.cache.GetPost(ctx, key)
-	if errors.Is(err, redis.Nil) {
-		return nil
+	// a post is cached once per audience, dropping the copies is simpler
+	// than counting the like into each of them
+	if err := s.cache.Del(ctx, generatePostKey(postID)).Err(); err != nil {
+		return fmt.Errorf("failed to delete item from cache: %w", err)
 	}
+	return nil
+}
+
+func (s *LikeService) LikeComment(ctx context.Context, commentID uuid.UUID, userID uuid.UUID) error {
+	comment, err := s.visibleComment(ctx, commentID, userID)
 	if err != nil {
 		return err
 	}
-
-	p.Likes++
-	if err := s.cache.Set(ctx, generatePostKey(p.ID), p, time.Minute*1).Err(); err != nil {
-		return fmt.Errorf("failed to cache data: %w", err)
+	if err := s.blocks.check(ctx, userID, comment.UserID); err != nil {
+		return err
 	}
-	return nil
-}
 
-func (s *LikeService) LikeComment(ctx context.Context, commentID uuid.UUID, userID uuid.UUID) error {
 	il, err := s.isCommentLiked(ctx, commentID)
 	if err != nil {
 		return err
@@ -72,18 +88,8 @@ func (s *LikeService) RemoveLikeFromPost(ctx context.Context, postID uuid.UUID,
 		return err
 	}
 
-	key := generatePostKey(postID)
-	p, err := s.cache.GetPost(ctx, key)
-	if errors.Is(err, redis.Nil) {
-		return nil
-	}
-	if err != nil {
-		return err
-	}
-
-	p.Likes--
-	if err := s.cache.Set(ctx, generatePostKey(p.ID), p, time.Minute*1).Err(); err != nil {
-		return fmt.Errorf("failed to cache data: %w", err)
+	if err := s.cache.Del(ctx, generatePostKey(postID)).Err(); err != nil {
+		return fmt.Errorf("failed to delete item from cache: %w", err)
 	}
 	return nil
 }
@@ -92,6 +98,36 @@ func (s *LikeService) RemoveLikeFromComment(ctx context.Context, commentID uuid.
 	return s.repo.RemoveLikeFromComment(ctx, commentID, userID)
 }
 
+func (s *LikeService) GetPostLikers(ctx context.Context, postID uuid.UUID, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	if _, err := visiblePost(ctx, s.postRepo, s.follows, postID, viewerID); err != nil {
+		return types.Page[types.User]{}, err
+	}
+	return s.repo.GetPostLikers(ctx, postID, page)
+}
+
+func (s *LikeService) GetCommentLikers(ctx context.Context, commentID uuid.UUID, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	if _, err := s.visibleComment(ctx, commentID, viewerID); err != nil {
+		return types.Page[types.User]{}, err
+	}
+	return s.repo.GetCommentLikers(ctx, commentID, page)
+}
+
+// visibleComment returns the comment if the viewer may see the post it
+// belongs to and repoerrs.ErrCommentNotFound otherwise.
+func (s *LikeService) visibleComment(ctx context.Context, commentID uuid.UUID, viewerID uuid.UUID) (*types.Comment, error) {
+	comment, err := s.commentRepo.GetByID(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := visiblePost(ctx, s.postRepo, s.follows, comment.PostID, viewerID); err != nil {
+		if errors.Is(err, repoerrs.ErrPostNotFound) {
+			return nil, repoerrs.ErrCommentNotFound
+		}
+		return nil, err
+	}
+	return comment, nil
+}
+
 func (s *LikeService) isPostLiked(ctx context.Context, postID uuid.UUID) (bool, error) {
 	return s.repo.IsPostLiked(ctx, postID)
 }
@@ -39,10 +39,10 @@ func (st *likeServiceSuite) SetupSuite() {
 
 	st.container = container
 	st.redisContainer = redisContainer
-	st.svc = NewLikeService(repo.Like, c)
-	st.authSvc = NewAuthService(repo.Auth, repo.User, signKey)
-	st.postSvc = NewPostService(repo.Post, c)
-	st.commentSvc = NewCommentService(repo.Comment, repo.Post)
+	st.svc = NewLikeService(repo.Like, repo.Post, repo.Comment, nil, nil, c)
+	st.authSvc = newAuthService(repo, NewMemoryMailer())
+	st.postSvc = NewPostService(repo.Post, repo.File, c, NewModerationService(repo.Moderation), nil, nil)
+	st.commentSvc = NewCommentService(repo.Comment, repo.Post, NewModerationService(repo.Moderation), nil, nil)
 }
 
 func (st *likeServiceSuite) TearDownSuite() {
@@ -57,6 +57,7 @@ func (st *likeServiceSuite) TestLikeAndRemoveLikeFromPost() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -67,8 +68,7 @@ func (st *likeServiceSuite) TestLikeAndRemoveLikeFromPost() {
 	st.NotEmpty(userID, "expected to get user id")
 
 	postIn := types.CreatePostReq{
-		Content:  gofakeit.Comment(),
-		PhotoURL: gofakeit.URL(),
+		Content: gofakeit.Comment(),
 	}
 	post, err := st.postSvc.Create(ctx, userID, postIn)
 	st.NoError(err, "failed to create post")
@@ -85,6 +85,7 @@ func (st *likeServiceSuite) TestLikeNotExistingPost() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -103,6 +104,7 @@ func (st *likeServiceSuite) TestRemoveLikeFromNotExistingPost() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -121,6 +123,7 @@ func (st *likeServiceSuite) TestLikeAlreadyLikedPost() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -131,8 +134,7 @@ func (st *likeServiceSuite) TestLikeAlreadyLikedPost() {
 	st.NotEmpty(userID, "expected to get user id")
 
 	postIn := types.CreatePostReq{
-		Content:  gofakeit.Comment(),
-		PhotoURL: gofakeit.URL(),
+		Content: gofakeit.Comment(),
 	}
 	post, err := st.postSvc.Create(ctx, userID, postIn)
 	st.NoError(err, "failed to create post")
@@ -151,6 +153,7 @@ func (st *likeServiceSuite) TestRemoveLikeFromNotLikedPost() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -161,8 +164,7 @@ func (st *likeServiceSuite) TestRemoveLikeFromNotLikedPost() {
 	st.NotEmpty(userID, "expected to get user id")
 
 	postIn := types.CreatePostReq{
-		Content:  gofakeit.Comment(),
-		PhotoURL: gofakeit.URL(),
+		Content: gofakeit.Comment(),
 	}
 	post, err := st.postSvc.Create(ctx, userID, postIn)
 	st.NoError(err, "failed to create post")
@@ -177,6 +179,7 @@ func (st *likeServiceSuite) TestLikeAndRemoveLikeFromComment() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -187,8 +190,7 @@ func (st *likeServiceSuite) TestLikeAndRemoveLikeFromComment() {
 	st.NotEmpty(userID, "expected to get user id")
 
 	postIn := types.CreatePostReq{
-		Content:  gofakeit.Comment(),
-		PhotoURL: gofakeit.URL(),
+		Content: gofakeit.Comment(),
 	}
 	post, err := st.postSvc.Create(ctx, userID, postIn)
 	st.NoError(err, "failed to create post")
@@ -212,6 +214,7 @@ func (st *likeServiceSuite) TestLikeNotExistingComment() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -230,6 +233,7 @@ func (st *likeServiceSuite) TestRemoveLikeFromNotExistingComment() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -248,6 +252,7 @@ func (st *likeServiceSuite) TestLikeAlreadyLikedComment() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -258,8 +263,7 @@ func (st *likeServiceSuite) TestLikeAlreadyLikedComment() {
 	st.NotEmpty(userID, "expected to get user id")
 
 	postIn := types.CreatePostReq{
-		Content:  gofakeit.Comment(),
-		PhotoURL: gofakeit.URL(),
+		Content: gofakeit.Comment(),
 	}
 	post, err := st.postSvc.Create(ctx, userID, postIn)
 	st.NoError(err, "failed to create post")
@@ -284,6 +288,7 @@ func (st *likeServiceSuite) TestRemoveLikeFromNotLikedComment() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -294,8 +299,7 @@ func (st *likeServiceSuite) TestRemoveLikeFromNotLikedComment() {
 	st.NotEmpty(userID, "expected to get user id")
 
 	postIn := types.CreatePostReq{
-		Content:  gofakeit.Comment(),
-		PhotoURL: gofakeit.URL(),
+		Content: gofakeit.Comment(),
 	}
 	post, err := st.postSvc.Create(ctx, userID, postIn)
 	st.NoError(err, "failed to create post")
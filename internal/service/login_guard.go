@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/escoutdoor/social/internal/cache"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type LoginGuardOpts struct {
+	// MaxAccountFailures and MaxIPFailures are the number of failed attempts
+	// after which the account or the client IP is locked out.
+	MaxAccountFailures int64
+	MaxIPFailures      int64
+	LockoutDuration    time.Duration
+
+	// BackoffAfter is the number of failed attempts on an account after which
+	// every further attempt has to wait, starting at BaseBackoff and doubling
+	// up to MaxBackoff.
+	BackoffAfter int64
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+
+	// Window is how long failed attempts are remembered.
+	Window time.Duration
+
+	// MaxAccountMagicLinks and MaxIPMagicLinks are the number of sign in
+	// links that can be requested for an email or from a client IP within
+	// MagicLinkWindow. They are counted apart from failed attempts, so
+	// requesting links for someone else can't lock them out.
+	MaxAccountMagicLinks int64
+	MaxIPMagicLinks      int64
+	MagicLinkWindow      time.Duration
+}
+
+var DefaultLoginGuardOpts = LoginGuardOpts{
+	MaxAccountFailures: 10,
+	MaxIPFailures:      100,
+	LockoutDuration:    time.Minute * 15,
+	BackoffAfter:       3,
+	BaseBackoff:        time.Second,
+	MaxBackoff:         time.Minute,
+	Window:             time.Minute * 15,
+
+	MaxAccountMagicLinks: 5,
+	MaxIPMagicLinks:      20,
+	MagicLinkWindow:      time.Minute * 15,
+}
+
+// LoginGuard counts failed sign in attempts per account and per client IP.
+// A nil *LoginGuard lets every attempt through.
+type LoginGuard struct {
+	cache cache.Repository
+	opts  LoginGuardOpts
+}
+
+func NewLoginGuard(cache cache.Repository, opts LoginGuardOpts) *LoginGuard {
+	return &LoginGuard{
+		cache: cache,
+		opts:  opts,
+	}
+}
+
+// Check returns a *RetryError when the attempt has to be rejected without
+// looking at the password.
+func (g *LoginGuard) Check(ctx context.Context, email, ip string) error {
+	if g == nil {
+		return nil
+	}
+	email = normalizeEmail(email)
+
+	lockout, err := g.cache.GetLockout(ctx, types.LockoutKindAccount, email)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	if lockout != nil {
+		return &RetryError{Err: ErrAccountLocked, RetryAfter: time.Until(lockout.ExpiresAt)}
+	}
+
+	if ip != "" {
+		lockout, err := g.cache.GetLockout(ctx, types.LockoutKindIP, ip)
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if lockout != nil {
+			return &RetryError{Err: ErrTooManyAttempts, RetryAfter: time.Until(lockout.ExpiresAt)}
+		}
+	}
+
+	wait, err := g.cache.GetBackoff(ctx, types.LockoutKindAccount, email)
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		return &RetryError{Err: ErrTooManyAttempts, RetryAfter: wait}
+	}
+	return nil
+}
+
+// Fail records a failed attempt and locks the account or the IP once they
+// run out of attempts.
+func (g *LoginGuard) Fail(ctx context.Context, email, ip string) error {
+	if g == nil {
+		return nil
+	}
+	email = normalizeEmail(email)
+
+	failures, err := g.cache.IncrFailures(ctx, types.LockoutKindAccount, email, g.opts.Window)
+	if err != nil {
+		return err
+	}
+	switch {
+	case failures >= g.opts.MaxAccountFailures:
+		if err := g.lock(ctx, types.LockoutKindAccount, email, failures); err != nil {
+			return err
+		}
+	case failures >= g.opts.BackoffAfter:
+		if err := g.cache.SetBackoff(ctx, types.LockoutKindAccount, email, g.backoff(failures)); err != nil {
+			return err
+		}
+	}
+
+	if ip == "" {
+		return nil
+	}
+	failures, err = g.cache.IncrFailures(ctx, types.LockoutKindIP, ip, g.opts.Window)
+	if err != nil {
+		return err
+	}
+	if failures >= g.opts.MaxIPFailures {
+		return g.lock(ctx, types.LockoutKindIP, ip, failures)
+	}
+	return nil
+}
+
+// LimitMagicLink counts a request for a sign in link and returns a
+// *RetryError once the email or the IP asked for too many.
+func (g *LoginGuard) LimitMagicLink(ctx context.Context, email, ip string) error {
+	if g == nil {
+		return nil
+	}
+	count, ttl, err := g.cache.IncrRequests(ctx, types.LockoutKindAccount, normalizeEmail(email), g.opts.MagicLinkWindow)
+	if err != nil {
+		return err
+	}
+	if count > g.opts.MaxAccountMagicLinks {
+		return &RetryError{Err: ErrTooManyAttempts, RetryAfter: ttl}
+	}
+
+	if ip == "" {
+		return nil
+	}
+	count, ttl, err = g.cache.IncrRequests(ctx, types.LockoutKindIP, ip, g.opts.MagicLinkWindow)
+	if err != nil {
+		return err
+	}
+	if count > g.opts.MaxIPMagicLinks {
+		return &RetryError{Err: ErrTooManyAttempts, RetryAfter: ttl}
+	}
+	return nil
+}
+
+// Succeed forgets the failed attempts on the account. Failures from the IP
+// are kept, so one valid account does not reset a credential stuffing run.
+func (g *LoginGuard) Succeed(ctx context.Context, email string) error {
+	if g == nil {
+		return nil
+	}
+	return g.cache.ResetFailures(ctx, types.LockoutKindAccount, normalizeEmail(email))
+}
+
+func (g *LoginGuard) GetLockouts(ctx context.Context) ([]types.Lockout, error) {
+	return g.cache.GetLockouts(ctx)
+}
+
+func (g *LoginGuard) Unlock(ctx context.Context, kind types.LockoutKind, subject string, adminID uuid.UUID) error {
+	if kind == types.LockoutKindAccount {
+		subject = normalizeEmail(subject)
+	}
+	if err := g.cache.DeleteLockout(ctx, kind, subject); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrLockoutNotFound
+		}
+		return err
+	}
+	slog.Info("sign in lockout lifted", "kind", kind, "subject", subject, "admin_id", adminID)
+	return nil
+}
+
+func (g *LoginGuard) lock(ctx context.Context, kind types.LockoutKind, subject string, failures int64) error {
+	now := time.Now()
+	lockout := types.Lockout{
+		Kind:      kind,
+		Subject:   subject,
+		Failures:  failures,
+		LockedAt:  now,
+		ExpiresAt: now.Add(g.opts.LockoutDuration),
+	}
+	if err := g.cache.SetLockout(ctx, lockout); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", kind, err)
+	}
+	slog.Warn("sign in locked out", "kind", kind, "subject", subject, "failures", failures, "until", lockout.ExpiresAt)
+	return nil
+}
+
+func (g *LoginGuard) backoff(failures int64) time.Duration {
+	d := g.opts.BaseBackoff
+	for i := g.opts.BackoffAfter; i < failures && d < g.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, g.opts.MaxBackoff)
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
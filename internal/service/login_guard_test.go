@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/escoutdoor/social/internal/testutils"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+type loginGuardSuite struct {
+	suite.Suite
+	redisContainer testcontainers.Container
+	guard          *LoginGuard
+}
+
+func (st *loginGuardSuite) SetupSuite() {
+	redisContainer, c, err := testutils.NewRedisContainer()
+	st.Require().NoError(err, "failed to run redis container")
+	st.Require().NotEmpty(redisContainer, "expected to get redis container")
+	st.Require().NotEmpty(c, "expected to get redis connection")
+
+	st.redisContainer = redisContainer
+	st.guard = NewLoginGuard(c, LoginGuardOpts{
+		MaxAccountFailures: 4,
+		MaxIPFailures:      6,
+		LockoutDuration:    time.Minute,
+		BackoffAfter:       2,
+		BaseBackoff:        time.Second,
+		MaxBackoff:         time.Second * 4,
+		Window:             time.Minute,
+
+		MaxAccountMagicLinks: 2,
+		MaxIPMagicLinks:      3,
+		MagicLinkWindow:      time.Minute,
+	})
+}
+
+func (st *loginGuardSuite) TearDownSuite() {
+	err := st.redisContainer.Terminate(context.Background())
+	st.Require().NoError(err, "failed to terminate redis container")
+}
+
+func (st *loginGuardSuite) TestLimitMagicLink() {
+	ctx := context.Background()
+	email := gofakeit.Email()
+	ip := gofakeit.IPv4Address()
+
+	st.NoError(st.guard.LimitMagicLink(ctx, email, ip))
+	st.NoError(st.guard.LimitMagicLink(ctx, email, ip))
+	err := st.guard.LimitMagicLink(ctx, email, ip)
+	st.ErrorIs(err, ErrTooManyAttempts, "expected the email to run out of links")
+	var retryErr *RetryError
+	st.Require().ErrorAs(err, &retryErr)
+	st.Positive(retryErr.RetryAfter, "expected retry after")
+
+	st.NoError(st.guard.Check(ctx, email, ip), "expected link requests not to count as failed sign ins")
+
+	// the rejected request did not count against the ip
+	st.NoError(st.guard.LimitMagicLink(ctx, gofakeit.Email(), ip))
+	st.ErrorIs(st.guard.LimitMagicLink(ctx, gofakeit.Email(), ip), ErrTooManyAttempts, "expected the ip to run out of links")
+}
+
+func (st *loginGuardSuite) TestBackoffAndLockout() {
+	ctx := context.Background()
+	email := gofakeit.Email()
+	ip := gofakeit.IPv4Address()
+
+	st.NoError(st.guard.Fail(ctx, email, ip))
+	st.NoError(st.guard.Check(ctx, email, ip), "expected no backoff after first failure")
+
+	st.NoError(st.guard.Fail(ctx, email, ip))
+	err := st.guard.Check(ctx, email, ip)
+	st.ErrorIs(err, ErrTooManyAttempts, "expected backoff")
+
+	var retryErr *RetryError
+	st.Require().ErrorAs(err, &retryErr)
+	st.Positive(retryErr.RetryAfter, "expected retry after")
+
+	st.NoError(st.guard.Fail(ctx, email, ip))
+	st.NoError(st.guard.Fail(ctx, email, ip))
+	st.ErrorIs(st.guard.Check(ctx, email, ip), ErrAccountLocked, "expected account lockout")
+
+	lockouts, err := st.guard.GetLockouts(ctx)
+	st.Require().NoError(err, "failed to get lockouts")
+	st.Contains(lockouts, findLockout(lockouts, types.LockoutKindAccount, normalizeEmail(email)), "expected lockout to be listed")
+
+	err = st.guard.Unlock(ctx, types.LockoutKindAccount, email, uuid.New())
+	st.Require().NoError(err, "failed to unlock")
+	st.NoError(st.guard.Check(ctx, email, ip), "expected lockout and backoff to be lifted")
+
+	err = st.guard.Unlock(ctx, types.LockoutKindAccount, email, uuid.New())
+	st.ErrorIs(err, ErrLockoutNotFound)
+}
+
+func (st *loginGuardSuite) TestIPLockout() {
+	ctx := context.Background()
+	ip := gofakeit.IPv4Address()
+
+	for i := 0; i < 6; i++ {
+		st.Require().NoError(st.guard.Fail(ctx, gofakeit.Email(), ip))
+	}
+	st.ErrorIs(st.guard.Check(ctx, gofakeit.Email(), ip), ErrTooManyAttempts, "expected ip lockout")
+	st.NoError(st.guard.Check(ctx, gofakeit.Email(), gofakeit.IPv4Address()), "expected other ips to be allowed")
+}
+
+func (st *loginGuardSuite) TestSucceedResetsFailures() {
+	ctx := context.Background()
+	email := gofakeit.Email()
+
+	st.NoError(st.guard.Fail(ctx, email, ""))
+	st.NoError(st.guard.Fail(ctx, email, ""))
+	st.Error(st.guard.Check(ctx, email, ""), "expected backoff")
+
+	st.NoError(st.guard.Succeed(ctx, email))
+	st.NoError(st.guard.Check(ctx, email, ""), "expected failures to be reset")
+}
+
+func findLockout(lockouts []types.Lockout, kind types.LockoutKind, subject string) types.Lockout {
+	for _, l := range lockouts {
+		if l.Kind == kind && l.Subject == subject {
+			return l
+		}
+	}
+	return types.Lockout{}
+}
+
+func TestLoginGuard(t *testing.T) {
+	suite.Run(t, new(loginGuardSuite))
+}
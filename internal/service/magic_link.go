@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/hasher"
+)
+
+const magicLinkTTL = time.Minute * 15
+
+// RequestMagicLink emails a single-use sign in link to the user. It does not
+// report whether the email belongs to an account. Requests are rate limited
+// per email and IP, apart from failed sign in attempts, so that anyone can't
+// lock an account out just by knowing its email.
+func (s *AuthService) RequestMagicLink(ctx context.Context, email, ip string) error {
+	if err := s.guard.LimitMagicLink(ctx, email, ip); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate magic link token: %w", err)
+	}
+	err = s.magicLinkRepo.Create(ctx, types.MagicLinkToken{
+		UserID:    user.ID,
+		TokenHash: hasher.HashToken(token),
+		ExpiresAt: time.Now().Add(magicLinkTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, Mail{
+		To:      user.Email,
+		Subject: "Your sign in link",
+		Body: fmt.Sprintf(
+			"Use the link below to sign in. It expires in %s and works only once.\n\n%s/magic-link?token=%s\n",
+			magicLinkTTL, s.appURL, token,
+		),
+	})
+}
+
+// SignInWithMagicLink exchanges a token from RequestMagicLink for the same
+// result SignIn returns. The password is never looked at, so it also works
+// for accounts created through an identity provider.
+func (s *AuthService) SignInWithMagicLink(ctx context.Context, token string, meta types.SessionMeta) (*types.SignInResult, error) {
+	ml, err := s.magicLinkRepo.GetByHash(ctx, hasher.HashToken(token))
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrMagicLinkNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if ml.UsedAt != nil || time.Now().After(ml.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	if err := s.magicLinkRepo.MarkUsed(ctx, ml.ID); err != nil {
+		if errors.Is(err, repoerrs.ErrMagicLinkNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, ml.UserID)
+	if err != nil {
+		return nil, err
+	}
+	// the link proves access to the mailbox
+	if !user.IsEmailVerified() {
+		if err := s.userRepo.ConfirmEmail(ctx, user.ID, user.Email); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.guard.Succeed(ctx, user.Email); err != nil {
+		return nil, err
+	}
+	return s.completeSignIn(ctx, user.ID, meta)
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+type SMTPOpts struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	From     string
+}
+
+type SMTPMailer struct {
+	opts SMTPOpts
+}
+
+func NewSMTPMailer(opts SMTPOpts) *SMTPMailer {
+	return &SMTPMailer{
+		opts: opts,
+	}
+}
+
+func (m *SMTPMailer) Send(_ context.Context, mail Mail) error {
+	var auth smtp.Auth
+	if m.opts.User != "" {
+		auth = smtp.PlainAuth("", m.opts.User, m.opts.Password, m.opts.Host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.opts.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mail.Subject)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	msg.WriteString(mail.Body)
+
+	addr := net.JoinHostPort(m.opts.Host, strconv.Itoa(m.opts.Port))
+	if err := smtp.SendMail(addr, auth, m.opts.From, []string{mail.To}, []byte(msg.String())); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
+
+// LogMailer writes mails to the log instead of sending them. It is meant for
+// local development where no SMTP server is configured.
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(_ context.Context, mail Mail) error {
+	slog.Info("mail", "to", mail.To, "subject", mail.Subject, "body", mail.Body)
+	return nil
+}
+
+// MemoryMailer keeps sent mails in memory so tests can inspect them.
+type MemoryMailer struct {
+	mu    sync.Mutex
+	mails []Mail
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(_ context.Context, mail Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+// Last returns the most recent mail sent to the given address.
+func (m *MemoryMailer) Last(to string) (Mail, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.mails) - 1; i >= 0; i-- {
+		if m.mails[i].To == to {
+			return m.mails[i], true
+		}
+	}
+	return Mail{}, false
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/hasher"
+	"github.com/escoutdoor/social/pkg/oidc"
+	"github.com/google/uuid"
+)
+
+const (
+	oidcStateTTL         = time.Minute * 10
+	oidcUsernameAttempts = 3
+)
+
+// StartOIDC begins a login at the provider and returns the URL to send the
+// user to. The provider redirects back to the client application, which
+// finishes the login with FinishOIDC.
+func (s *AuthService) StartOIDC(ctx context.Context, providerName string) (string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	state, err := generateOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	nonce, err := generateOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	verifier, err := oidc.GenerateVerifier()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code verifier: %w", err)
+	}
+
+	err = s.identityRepo.CreateState(ctx, types.OIDCState{
+		StateHash: hasher.HashToken(state),
+		Provider:  providerName,
+		Verifier:  verifier,
+		Nonce:     nonce,
+		ExpiresAt: time.Now().Add(oidcStateTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return provider.AuthCodeURL(ctx, state, nonce, verifier)
+}
+
+// FinishOIDC exchanges the code the provider redirected back with and signs
+// in the user linked to the external identity, creating one on first login.
+func (s *AuthService) FinishOIDC(ctx context.Context, providerName string, input types.OIDCCallbackReq, meta types.SessionMeta) (*types.SignInResult, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	state, err := s.identityRepo.ConsumeState(ctx, hasher.HashToken(input.State))
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrOIDCStateNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if state.Provider != providerName || time.Now().After(state.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	claims, err := provider.Exchange(ctx, input.Code, state.Verifier, state.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrExchangeFailed) || errors.Is(err, oidc.ErrInvalidIDToken) {
+			return nil, fmt.Errorf("%w: %w", ErrOIDCLoginFailed, err)
+		}
+		return nil, err
+	}
+
+	userID, err := s.resolveIdentity(ctx, providerName, claims)
+	if err != nil {
+		return nil, err
+	}
+	return s.completeSignIn(ctx, userID, meta)
+}
+
+func (s *AuthService) resolveIdentity(ctx context.Context, provider string, claims *oidc.Claims) (uuid.UUID, error) {
+	identity, err := s.identityRepo.GetByProviderSubject(ctx, provider, claims.Subject)
+	if err == nil {
+		return identity.UserID, nil
+	}
+	if !errors.Is(err, repoerrs.ErrIdentityNotFound) {
+		return uuid.Nil, err
+	}
+	if claims.Email == "" {
+		return uuid.Nil, ErrOIDCEmailMissing
+	}
+
+	var userID uuid.UUID
+	user, err := s.userRepo.GetByEmail(ctx, claims.Email)
+	switch {
+	case err == nil:
+		// linking by email is only safe when both the provider and the
+		// account vouch for the address. Otherwise anyone could claim an
+		// existing account, or sign up with the email of someone else and
+		// keep a password to the account once they link their identity.
+		if !claims.EmailVerified || !user.IsEmailVerified() {
+			return uuid.Nil, repoerrs.ErrEmailAlreadyExists
+		}
+		userID = user.ID
+	case errors.Is(err, repoerrs.ErrUserNotFound):
+		userID, err = s.createOIDCUser(ctx, claims)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	default:
+		return uuid.Nil, err
+	}
+
+	err = s.identityRepo.Create(ctx, types.Identity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    &claims.Email,
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+// createOIDCUser creates a user without a usable password. The user can set
+// one later through the password reset flow.
+func (s *AuthService) createOIDCUser(ctx context.Context, claims *oidc.Claims) (uuid.UUID, error) {
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	password, err := hasher.HashPw(secret)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	firstName, lastName := oidcNames(claims)
+	var id uuid.UUID
+	// the random suffix makes a clash unlikely, but not impossible
+	for attempt := 0; ; attempt++ {
+		username, err := oidcUsername(claims)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		id, err = s.repo.Create(ctx, types.CreateUserReq{
+			Username:  username,
+			FirstName: firstName,
+			LastName:  lastName,
+			Email:     claims.Email,
+			Password:  password,
+		})
+		if errors.Is(err, repoerrs.ErrUsernameTaken) && attempt < oidcUsernameAttempts {
+			continue
+		}
+		if err != nil {
+			return uuid.Nil, err
+		}
+		break
+	}
+
+	if claims.EmailVerified {
+		if err := s.userRepo.ConfirmEmail(ctx, id, claims.Email); err != nil {
+			return uuid.Nil, err
+		}
+	} else if err := s.verifier.Send(ctx, id, claims.Email); err != nil {
+		slog.Error("AuthService.createOIDCUser - EmailVerifier.Send", "error", err)
+	}
+	return id, nil
+}
+
+func oidcNames(claims *oidc.Claims) (string, string) {
+	firstName, lastName := claims.GivenName, claims.FamilyName
+	if firstName == "" && claims.Name != "" {
+		firstName, lastName, _ = strings.Cut(claims.Name, " ")
+	}
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(claims.Email, "@")
+	}
+	return firstName, lastName
+}
+
+// oidcUsername derives a username from the local part of the email address
+// with a random suffix, e.g. "jane_doe_1f9a2c" for jane.doe@example.com. The
+// user can pick a nicer one later.
+func oidcUsername(claims *oidc.Claims) (string, error) {
+	local, _, _ := strings.Cut(claims.Email, "@")
+	base := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		case r == '.' || r == '-' || r == '+':
+			return '_'
+		}
+		return -1
+	}, local)
+	if len(base) > 20 {
+		base = base[:20]
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return base + "_" + hex.EncodeToString(suffix), nil
+}
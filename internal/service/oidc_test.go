@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/testutils"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/oidc"
+	"github.com/escoutdoor/social/pkg/oidc/oidctest"
+	"github.com/stretchr/testify/suite"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+type oidcSuite struct {
+	suite.Suite
+	container testcontainers.Container
+	issuer    *oidctest.Issuer
+	repo      *repository.Repository
+	svc       *AuthService
+}
+
+func (st *oidcSuite) SetupSuite() {
+	container, db, err := testutils.NewPostgresContainer()
+	st.Require().NoError(err, "failed to run postgres container")
+	st.Require().NotEmpty(container, "expected to get postgres container")
+	st.Require().NotEmpty(db, "expected to get db connection")
+
+	issuer, err := oidctest.NewIssuer("social")
+	st.Require().NoError(err, "failed to start oidc issuer")
+
+	st.container = container
+	st.issuer = issuer
+	st.repo = repository.New(db)
+
+	opts := newAuthServiceOpts(st.repo, NewMemoryMailer())
+	opts.Providers = map[string]*oidc.Provider{
+		"fake": oidc.NewProvider(issuer.Config("http://localhost/oauth/fake/callback"), nil),
+	}
+	st.svc = NewAuthService(opts)
+}
+
+func (st *oidcSuite) TearDownSuite() {
+	st.issuer.Close()
+	err := st.container.Terminate(context.Background())
+	st.Require().NoError(err, "failed to terminate postgres container")
+}
+
+func (st *oidcSuite) TestFirstLoginCreatesUser() {
+	ctx := context.Background()
+	user := oidctest.User{
+		Subject:       gofakeit.UUID(),
+		Email:         gofakeit.Email(),
+		EmailVerified: true,
+		GivenName:     gofakeit.FirstName(),
+		FamilyName:    gofakeit.LastName(),
+	}
+
+	result := st.login(ctx, user)
+	st.Require().NotNil(result.Tokens, "expected to get tokens")
+
+	claims, err := st.svc.ParseToken(ctx, result.AccessToken)
+	st.Require().NoError(err, "failed to parse access token")
+
+	created, err := st.repo.User.GetByID(ctx, claims.UserID)
+	st.Require().NoError(err, "failed to get created user")
+	st.Equal(user.Email, created.Email)
+	st.Equal(user.GivenName, created.FirstName)
+	st.True(created.IsEmailVerified(), "expected email verified by provider to be confirmed")
+
+	again := st.login(ctx, user)
+	st.Require().NotNil(again.Tokens, "expected to get tokens")
+	againClaims, err := st.svc.ParseToken(ctx, again.AccessToken)
+	st.Require().NoError(err, "failed to parse access token")
+	st.Equal(claims.UserID, againClaims.UserID, "expected the same user on next login")
+}
+
+func (st *oidcSuite) TestLinksExistingUserByVerifiedEmail() {
+	ctx := context.Background()
+	email := gofakeit.Email()
+	id, err := st.svc.SignUp(ctx, types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     email,
+		Password:  randomPw(),
+	})
+	st.Require().NoError(err, "failed to signup")
+
+	unverified := oidctest.User{Subject: gofakeit.UUID(), Email: email}
+	_, err = st.finish(ctx, unverified)
+	st.ErrorIs(err, repoerrs.ErrEmailAlreadyExists, "expected unverified email not to be linked")
+
+	// whoever signed up may not own the address
+	_, err = st.finish(ctx, oidctest.User{Subject: gofakeit.UUID(), Email: email, EmailVerified: true})
+	st.ErrorIs(err, repoerrs.ErrEmailAlreadyExists, "expected an account with an unconfirmed email not to be linked")
+
+	err = st.repo.User.ConfirmEmail(ctx, id, email)
+	st.Require().NoError(err, "failed to confirm email")
+	result := st.login(ctx, oidctest.User{Subject: gofakeit.UUID(), Email: email, EmailVerified: true})
+	claims, err := st.svc.ParseToken(ctx, result.AccessToken)
+	st.Require().NoError(err, "failed to parse access token")
+	st.Equal(id, claims.UserID, "expected identity to be linked to the existing user")
+}
+
+func (st *oidcSuite) TestStateIsSingleUse() {
+	ctx := context.Background()
+	st.issuer.SetUser(oidctest.User{Subject: gofakeit.UUID(), Email: gofakeit.Email(), EmailVerified: true})
+
+	authURL, err := st.svc.StartOIDC(ctx, "fake")
+	st.Require().NoError(err, "failed to start login")
+	code, state, err := st.issuer.Authorize(authURL)
+	st.Require().NoError(err, "failed to authorize")
+
+	in := types.OIDCCallbackReq{Code: code, State: state}
+	_, err = st.svc.FinishOIDC(ctx, "fake", in, types.SessionMeta{})
+	st.Require().NoError(err, "failed to finish login")
+
+	_, err = st.svc.FinishOIDC(ctx, "fake", in, types.SessionMeta{})
+	st.ErrorIs(err, ErrInvalidToken, "expected state to be single use")
+
+	_, err = st.svc.StartOIDC(ctx, "unknown")
+	st.ErrorIs(err, ErrUnknownProvider)
+}
+
+func (st *oidcSuite) login(ctx context.Context, user oidctest.User) *types.SignInResult {
+	result, err := st.finish(ctx, user)
+	st.Require().NoError(err, "failed to sign in with provider")
+	return result
+}
+
+func (st *oidcSuite) finish(ctx context.Context, user oidctest.User) (*types.SignInResult, error) {
+	st.issuer.SetUser(user)
+	authURL, err := st.svc.StartOIDC(ctx, "fake")
+	st.Require().NoError(err, "failed to start login")
+
+	code, state, err := st.issuer.Authorize(authURL)
+	st.Require().NoError(err, "failed to authorize")
+	return st.svc.FinishOIDC(ctx, "fake", types.OIDCCallbackReq{Code: code, State: state}, types.SessionMeta{})
+}
+
+func TestOIDC(t *testing.T) {
+	suite.Run(t, new(oidcSuite))
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+)
+
+type Permission string
+
+const (
+	PermDeleteAnyPost    Permission = "posts:delete_any"
+	PermDeleteAnyComment Permission = "comments:delete_any"
+	PermViewModeration   Permission = "moderation:view"
+	PermManageLockouts   Permission = "lockouts:manage"
+	PermManageRoles      Permission = "roles:manage"
+)
+
+var rolePermissions = map[types.Role][]Permission{
+	types.RoleModerator: {
+		PermDeleteAnyPost,
+		PermDeleteAnyComment,
+		PermViewModeration,
+	},
+	types.RoleAdmin: {
+		PermDeleteAnyPost,
+		PermDeleteAnyComment,
+		PermViewModeration,
+		PermManageLockouts,
+		PermManageRoles,
+	},
+}
+
+// HasPermission reports whether the role grants the permission. Regular
+// users have no permissions beyond acting on their own resources.
+func HasPermission(role types.Role, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize decides whether the actor may act on a resource owned by
+// ownerID. Owners always may; anyone else needs perm, in which case the
+// action is done on the owner's behalf and moderated is true. An empty perm
+// means only the owner is allowed.
+func authorize(actor types.Actor, ownerID uuid.UUID, perm Permission) (moderated bool, err error) {
+	if actor.ID == ownerID {
+		return false, nil
+	}
+	if perm != "" && HasPermission(actor.Role, perm) {
+		return true, nil
+	}
+	return false, ErrAccessDenied
+}
+
+type ModerationService struct {
+	repo repository.Moderation
+}
+
+func NewModerationService(repo repository.Moderation) *ModerationService {
+	return &ModerationService{
+		repo: repo,
+	}
+}
+
+func (s *ModerationService) GetActions(ctx context.Context) ([]types.ModerationAction, error) {
+	return s.repo.GetAll(ctx)
+}
+
+// action returns the record that attributes an action on another user's
+// content to the moderator, or nil when actions are not recorded. It is
+// written by the repository in the same transaction as the action.
+func (s *ModerationService) action(actor types.Actor, action types.ModerationActionType, targetID, targetUserID uuid.UUID) *types.ModerationAction {
+	if s == nil {
+		return nil
+	}
+	return &types.ModerationAction{
+		ModeratorID:  &actor.ID,
+		Action:       action,
+		TargetID:     targetID,
+		TargetUserID: &targetUserID,
+	}
+}
+
+func logModeration(action *types.ModerationAction) {
+	if action == nil {
+		return
+	}
+	slog.Info("moderation action", "moderator_id", *action.ModeratorID, "action", action.Action, "target_id", action.TargetID, "target_user_id", *action.TargetUserID)
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	owner := uuid.New()
+	tests := []struct {
+		name      string
+		actor     types.Actor
+		perm      Permission
+		moderated bool
+		err       error
+	}{
+		{"owner", types.Actor{ID: owner, Role: types.RoleUser}, PermDeleteAnyPost, false, nil},
+		{"owner only", types.Actor{ID: owner, Role: types.RoleUser}, "", false, nil},
+		{"other user", types.Actor{ID: uuid.New(), Role: types.RoleUser}, PermDeleteAnyPost, false, ErrAccessDenied},
+		{"moderator", types.Actor{ID: uuid.New(), Role: types.RoleModerator}, PermDeleteAnyComment, true, nil},
+		{"admin", types.Actor{ID: uuid.New(), Role: types.RoleAdmin}, PermDeleteAnyPost, true, nil},
+		{"moderator owner only", types.Actor{ID: uuid.New(), Role: types.RoleModerator}, "", false, ErrAccessDenied},
+		{"unknown role", types.Actor{ID: uuid.New()}, PermDeleteAnyPost, false, ErrAccessDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moderated, err := authorize(tt.actor, owner, tt.perm)
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.moderated, moderated)
+		})
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	require.False(t, HasPermission(types.RoleUser, PermDeleteAnyPost))
+	require.True(t, HasPermission(types.RoleModerator, PermDeleteAnyPost))
+	require.False(t, HasPermission(types.RoleModerator, PermManageRoles))
+	require.True(t, HasPermission(types.RoleAdmin, PermManageRoles))
+}
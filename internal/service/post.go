@@ -4,43 +4,65 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/escoutdoor/social/internal/cache"
 	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
 	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/validator"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 type PostService struct {
-	repo  repository.Post
-	cache cache.Repository
+	repo       repository.Post
+	files      repository.File
+	cache      cache.Repository
+	moderation *ModerationService
+	feed       *FeedService
+	follows    *FollowService
 }
 
-func NewPostService(repo repository.Post, cache cache.Repository) *PostService {
+func NewPostService(repo repository.Post, files repository.File, cache cache.Repository, moderation *ModerationService, feed *FeedService, follows *FollowService) *PostService {
 	return &PostService{
-		repo:  repo,
-		cache: cache,
+		repo:       repo,
+		files:      files,
+		cache:      cache,
+		moderation: moderation,
+		feed:       feed,
+		follows:    follows,
 	}
 }
 
 func (s *PostService) Create(ctx context.Context, userID uuid.UUID, input types.CreatePostReq) (*types.Post, error) {
-	post, err := s.repo.Create(ctx, userID, input)
+	if err := s.checkMedia(ctx, userID, input.Media); err != nil {
+		return nil, err
+	}
+	post, err := s.repo.Create(ctx, userID, input, mentionedUsernames(input.Content))
 	if err != nil {
 		return nil, err
 	}
 
+	// the post is saved already, a timeline missing it is not worth
+	// failing the request for
+	if err := s.feed.PostCreated(ctx, *post); err != nil {
+		slog.Error("PostService.Create - FeedService.PostCreated", "error", err)
+	}
+
 	key := generatePostKey(post.ID)
-	if err := s.cache.Set(ctx, key, post, time.Minute*1).Err(); err != nil {
+	if err := s.cache.SetPost(ctx, key, postAudience(post, userID), *post, time.Minute*1); err != nil {
 		return nil, fmt.Errorf("failed to cache data: %w", err)
 	}
 	return post, nil
 }
 
-func (s *PostService) Update(ctx context.Context, postID, userID uuid.UUID, input types.UpdatePostReq) (*types.Post, error) {
+func (s *PostService) Update(ctx context.Context, postID uuid.UUID, actor types.Actor, input types.UpdatePostReq) (*types.Post, error) {
 	key := generatePostKey(postID)
-	p, err := s.cache.GetPost(ctx, key)
+	p, err := s.cache.GetPost(ctx, key, publicAudience, actor.ID.String())
 	if errors.Is(err, redis.Nil) {
 		p, err = s.repo.GetByID(ctx, postID)
 		if err != nil {
@@ -50,53 +72,77 @@ func (s *PostService) Update(ctx context.Context, postID, userID uuid.UUID, inpu
 	if err != nil {
 		return nil, err
 	}
-	if p.UserID != userID {
-		return nil, ErrAccessDenied
+	if _, err := authorize(actor, p.UserID, ""); err != nil {
+		return nil, err
 	}
 
 	if input.Content != nil {
 		p.Content = *input.Content
 	}
-	if input.PhotoURL != nil {
-		p.PhotoURL = input.PhotoURL
+	if input.Media != nil {
+		if err := s.checkMedia(ctx, p.UserID, *input.Media); err != nil {
+			return nil, err
+		}
+		p.Media = make([]types.PostMedia, len(*input.Media))
+		for i, m := range *input.Media {
+			p.Media[i] = types.PostMedia{FileID: m.FileID, AltText: m.AltText}
+		}
+	}
+	if input.Visibility != nil {
+		p.Visibility = *input.Visibility
 	}
 
-	post, err := s.repo.Update(ctx, postID, *p)
+	post, err := s.repo.Update(ctx, postID, *p, mentionedUsernames(p.Content))
 	if err != nil {
 		return nil, err
 	}
-	if err := s.cache.Set(ctx, key, post, time.Minute*1).Err(); err != nil {
+	// copies cached for other viewers may show what they can no longer see
+	if err := s.cache.Del(ctx, key).Err(); err != nil {
+		return nil, fmt.Errorf("failed to delete item from cache: %w", err)
+	}
+	if err := s.cache.SetPost(ctx, key, postAudience(post, actor.ID), *post, time.Minute*1); err != nil {
 		return nil, fmt.Errorf("failed to cache data: %w", err)
 	}
 	return post, nil
 }
 
-func (s *PostService) GetByID(ctx context.Context, id uuid.UUID) (*types.Post, error) {
+// GetByID returns the post if the viewer may see it. A public post is cached
+// once for every viewer, a restricted one only for the viewers it was shown
+// to, so a lookup of anyone else never finds it in the cache. Access is still
+// checked on cache hits, as follows and privacy change in the meantime.
+func (s *PostService) GetByID(ctx context.Context, id uuid.UUID, viewerID uuid.UUID) (*types.Post, error) {
 	key := generatePostKey(id)
-	post, err := s.cache.GetPost(ctx, key)
+	post, err := s.cache.GetPost(ctx, key, publicAudience, viewerID.String())
+	cached := err == nil
 	if errors.Is(err, redis.Nil) {
 		post, err = s.repo.GetByID(ctx, id)
-		if err != nil {
-			return nil, err
-		}
-		if err := s.cache.Set(ctx, key, post, time.Minute*1).Err(); err != nil {
-			return nil, fmt.Errorf("failed to cache data: %w", err)
-		}
 	}
 	if err != nil {
 		return nil, err
 	}
 
+	visible, err := s.follows.canViewPost(ctx, s.repo, viewerID, post)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, repoerrs.ErrPostNotFound
+	}
+	if !cached {
+		if err := s.cache.SetPost(ctx, key, postAudience(post, viewerID), *post, time.Minute*1); err != nil {
+			return nil, fmt.Errorf("failed to cache data: %w", err)
+		}
+	}
 	return post, nil
 }
 
-func (s *PostService) GetAll(ctx context.Context) ([]types.Post, error) {
-	return s.repo.GetAll(ctx)
+func (s *PostService) GetAll(ctx context.Context, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.Post], error) {
+	return s.repo.GetAll(ctx, viewerID, page)
 }
 
-func (s *PostService) Delete(ctx context.Context, postID uuid.UUID, userID uuid.UUID) error {
+func (s *PostService) Delete(ctx context.Context, postID uuid.UUID, actor types.Actor) error {
 	key := generatePostKey(postID)
-	p, err := s.cache.GetPost(ctx, key)
+	p, err := s.cache.GetPost(ctx, key, publicAudience, actor.ID.String())
 	if errors.Is(err, redis.Nil) {
 		p, err = s.repo.GetByID(ctx, postID)
 		if err != nil {
@@ -106,14 +152,23 @@ func (s *PostService) Delete(ctx context.Context, postID uuid.UUID, userID uuid.
 	if err != nil {
 		return err
 	}
-	if p.UserID != userID {
-		return ErrAccessDenied
+	moderated, err := authorize(actor, p.UserID, PermDeleteAnyPost)
+	if err != nil {
+		return err
 	}
 
-	err = s.repo.Delete(ctx, postID)
+	var action *types.ModerationAction
+	if moderated {
+		action = s.moderation.action(actor, types.ModerationDeletePost, postID, p.UserID)
+	}
+	err = s.repo.Delete(ctx, postID, action)
 	if err != nil {
 		return err
 	}
+	logModeration(action)
+	if err := s.feed.PostDeleted(ctx, *p); err != nil {
+		slog.Error("PostService.Delete - FeedService.PostDeleted", "error", err)
+	}
 
 	err = s.cache.Del(ctx, key).Err()
 	if err != nil {
@@ -122,6 +177,76 @@ func (s *PostService) Delete(ctx context.Context, postID uuid.UUID, userID uuid.
 	return nil
 }
 
+// checkMedia makes sure the media fit on a post and are files the user
+// uploaded. Files of others are reported as missing, not to tell what IDs
+// exist.
+func (s *PostService) checkMedia(ctx context.Context, userID uuid.UUID, media []types.PostMediaReq) error {
+	if len(media) == 0 {
+		return nil
+	}
+	if len(media) > types.MaxPostMedia {
+		return ErrTooManyMedia
+	}
+	ids := make([]uuid.UUID, len(media))
+	seen := make(map[uuid.UUID]bool, len(media))
+	for i, m := range media {
+		if seen[m.FileID] {
+			return ErrDuplicateMedia
+		}
+		seen[m.FileID] = true
+		ids[i] = m.FileID
+	}
+
+	files, err := s.files.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if len(files) != len(ids) {
+		return ErrMediaNotFound
+	}
+	for _, f := range files {
+		if f.UserID != userID {
+			return ErrMediaNotFound
+		}
+	}
+	return nil
+}
+
+// generatePostKey returns the key of the Redis hash holding the cached copies
+// of a post, one per audience, see postAudience.
 func generatePostKey(id uuid.UUID) string {
 	return fmt.Sprintf("post%s", id)
 }
+
+// publicAudience is the field of the copy of a public post every viewer is
+// served.
+const publicAudience = "public"
+
+// postAudience returns the field the post is cached under once it was shown
+// to the viewer. Restricted posts are cached per viewer.
+func postAudience(post *types.Post, viewerID uuid.UUID) string {
+	if post.Visibility == types.PostVisibilityPublic {
+		return publicAudience
+	}
+	return viewerID.String()
+}
+
+// mentionRe matches @username mentions. The character before the @ keeps
+// email addresses from counting as mentions.
+var mentionRe = regexp.MustCompile(`(?:^|[^a-zA-Z0-9_@])@([a-zA-Z0-9_]+)`)
+
+// mentionedUsernames returns the distinct usernames mentioned in the content,
+// lowercased.
+func mentionedUsernames(content string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, m := range mentionRe.FindAllStringSubmatch(content, -1) {
+		username := strings.ToLower(m[1])
+		if seen[username] || validator.ValidateUsername(username) != nil {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+	return usernames
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"slices"
 	"testing"
 
 	"github.com/brianvoe/gofakeit/v7"
@@ -18,6 +19,7 @@ type postServiceSuite struct {
 	suite.Suite
 	container      testcontainers.Container
 	redisContainer testcontainers.Container
+	repo           *repository.Repository
 	svc            Post
 	authSvc        Auth
 }
@@ -37,8 +39,9 @@ func (st *postServiceSuite) SetupSuite() {
 
 	st.container = container
 	st.redisContainer = redisContainer
-	st.svc = NewPostService(repo.Post, c)
-	st.authSvc = NewAuthService(repo.Auth, repo.User, signKey)
+	st.repo = repo
+	st.svc = NewPostService(repo.Post, repo.File, c, NewModerationService(repo.Moderation), nil, NewFollowService(repo.Follow, repo.User, nil, nil))
+	st.authSvc = newAuthService(repo, NewMemoryMailer())
 }
 
 func (st *postServiceSuite) TearDownSuite() {
@@ -53,6 +56,7 @@ func (st *postServiceSuite) TestGetByIDExistingPost() {
 	ctx := context.Background()
 
 	signupIn := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -63,22 +67,128 @@ func (st *postServiceSuite) TestGetByIDExistingPost() {
 	st.NotEmpty(userID, "expected to get user id")
 
 	postIn := types.CreatePostReq{
-		Content:  gofakeit.Dessert(),
-		PhotoURL: gofakeit.URL(),
+		Content: gofakeit.Dessert(),
 	}
 	post, err := st.svc.Create(ctx, userID, postIn)
 	st.NoError(err, "failed to create post")
 	st.NotEmpty(post, "expected to get post")
 
-	p, err := st.svc.GetByID(ctx, post.ID)
+	p, err := st.svc.GetByID(ctx, post.ID, userID)
 	st.NoError(err, "failed to get post")
 	st.NotEmpty(p, "expected to get post")
 }
 
+func (st *postServiceSuite) TestGetByIDPrivateAccount() {
+	ctx := context.Background()
+
+	var ids []uuid.UUID
+	for i := 0; i < 2; i++ {
+		id, err := st.authSvc.SignUp(ctx, types.CreateUserReq{
+			Username:  randomUsername(),
+			FirstName: gofakeit.FirstName(),
+			LastName:  gofakeit.LastName(),
+			Email:     gofakeit.Email(),
+			Password:  randomPw(),
+		})
+		st.Require().NoError(err, "failed to signup")
+		ids = append(ids, id)
+	}
+	owner, stranger := ids[0], ids[1]
+
+	user, err := st.repo.User.GetByID(ctx, owner)
+	st.Require().NoError(err, "failed to get user")
+	user.IsPrivate = true
+	_, err = st.repo.User.Update(ctx, *user)
+	st.Require().NoError(err, "failed to make account private")
+
+	post, err := st.svc.Create(ctx, owner, types.CreatePostReq{Content: gofakeit.Dessert()})
+	st.Require().NoError(err, "failed to create post")
+
+	// the owner reading the post puts it into the cache
+	_, err = st.svc.GetByID(ctx, post.ID, owner)
+	st.Require().NoError(err, "expected the owner to see the post")
+
+	_, err = st.svc.GetByID(ctx, post.ID, stranger)
+	st.ErrorIs(err, repoerrs.ErrPostNotFound, "expected cached private post to be hidden")
+
+	posts, err := st.svc.GetAll(ctx, stranger, types.CursorPage{Limit: 100})
+	st.Require().NoError(err, "failed to get posts")
+	for _, p := range posts.Items {
+		st.NotEqual(post.ID, p.ID, "expected private post to be left out")
+	}
+}
+
+func (st *postServiceSuite) TestVisibility() {
+	ctx := context.Background()
+
+	var (
+		ids       []uuid.UUID
+		usernames []string
+	)
+	for i := 0; i < 3; i++ {
+		in := types.CreateUserReq{
+			Username:  randomUsername(),
+			FirstName: gofakeit.FirstName(),
+			LastName:  gofakeit.LastName(),
+			Email:     gofakeit.Email(),
+			Password:  randomPw(),
+		}
+		id, err := st.authSvc.SignUp(ctx, in)
+		st.Require().NoError(err, "failed to signup")
+		ids = append(ids, id)
+		usernames = append(usernames, in.Username)
+	}
+	author, follower, stranger := ids[0], ids[1], ids[2]
+	st.Require().NoError(st.repo.Follow.Create(ctx, follower, author), "failed to follow")
+
+	followersOnly, err := st.svc.Create(ctx, author, types.CreatePostReq{
+		Content:    gofakeit.Dessert(),
+		Visibility: types.PostVisibilityFollowers,
+	})
+	st.Require().NoError(err, "failed to create post")
+	mentionedOnly, err := st.svc.Create(ctx, author, types.CreatePostReq{
+		Content:    "hello @" + usernames[2],
+		Visibility: types.PostVisibilityMentioned,
+	})
+	st.Require().NoError(err, "failed to create post")
+	st.Equal([]uuid.UUID{stranger}, mentionedOnly.MentionIDs)
+
+	// the follower reading the post puts it into the cache
+	_, err = st.svc.GetByID(ctx, followersOnly.ID, follower)
+	st.Require().NoError(err, "expected the follower to see the post")
+	_, err = st.svc.GetByID(ctx, followersOnly.ID, stranger)
+	st.ErrorIs(err, repoerrs.ErrPostNotFound, "expected followers-only post to be hidden")
+
+	_, err = st.svc.GetByID(ctx, mentionedOnly.ID, stranger)
+	st.Require().NoError(err, "expected the mentioned user to see the post")
+	_, err = st.svc.GetByID(ctx, mentionedOnly.ID, follower)
+	st.ErrorIs(err, repoerrs.ErrPostNotFound, "expected mentioned-only post to be hidden")
+
+	visible := func(viewerID uuid.UUID) map[uuid.UUID]bool {
+		posts, err := st.svc.GetAll(ctx, viewerID, types.CursorPage{Limit: 100})
+		st.Require().NoError(err, "failed to get posts")
+		ids := make(map[uuid.UUID]bool)
+		for _, p := range posts.Items {
+			ids[p.ID] = true
+		}
+		return ids
+	}
+	st.True(visible(follower)[followersOnly.ID])
+	st.False(visible(follower)[mentionedOnly.ID])
+	st.False(visible(stranger)[followersOnly.ID])
+	st.True(visible(stranger)[mentionedOnly.ID])
+
+	public := types.PostVisibilityPublic
+	_, err = st.svc.Update(ctx, followersOnly.ID, types.Actor{ID: author, Role: types.RoleUser}, types.UpdatePostReq{Visibility: &public})
+	st.Require().NoError(err, "failed to update post")
+	_, err = st.svc.GetByID(ctx, followersOnly.ID, stranger)
+	st.NoError(err, "expected the post to be public now")
+}
+
 func (st *postServiceSuite) TestGetByIDNotFound() {
 	ctx := context.Background()
 
-	p, err := st.svc.GetByID(ctx, uuid.New())
+	p, err := st.svc.GetByID(ctx, uuid.New(), uuid.New())
 	st.Error(err, "expected to get error: post not found")
 	st.ErrorIs(err, repoerrs.ErrPostNotFound, "expected to get post not found error")
 	st.Empty(p, "expected to get no data")
@@ -87,7 +197,7 @@ func (st *postServiceSuite) TestGetByIDNotFound() {
 func (st *postServiceSuite) TestDeleteNotFound() {
 	ctx := context.Background()
 
-	err := st.svc.Delete(ctx, uuid.New(), uuid.New())
+	err := st.svc.Delete(ctx, uuid.New(), types.Actor{ID: uuid.New(), Role: types.RoleUser})
 	st.Error(err, "expected to get error: post not found")
 	st.ErrorIs(err, repoerrs.ErrPostNotFound, "expected to get post not found error")
 }
@@ -96,6 +206,7 @@ func (st *postServiceSuite) TestDeleteExistingPost() {
 	ctx := context.Background()
 
 	in := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -106,21 +217,61 @@ func (st *postServiceSuite) TestDeleteExistingPost() {
 	st.NotEmpty(userID, "expected to get user id")
 
 	postIn := types.CreatePostReq{
-		Content:  gofakeit.Dessert(),
-		PhotoURL: gofakeit.URL(),
+		Content: gofakeit.Dessert(),
 	}
 	post, err := st.svc.Create(ctx, userID, postIn)
 	st.NoError(err, "failed to create post")
 	st.NotEmpty(post, "expected to get post")
 
-	err = st.svc.Delete(ctx, post.ID, userID)
+	err = st.svc.Delete(ctx, post.ID, types.Actor{ID: userID, Role: types.RoleUser})
 	st.NoError(err, "failed to delete post")
 }
 
+func (st *postServiceSuite) TestDeleteOthersPost() {
+	ctx := context.Background()
+
+	in := types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     gofakeit.Email(),
+		Password:  randomPw(),
+	}
+	userID, err := st.authSvc.SignUp(ctx, in)
+	st.NoError(err, "failed to signup")
+
+	post, err := st.svc.Create(ctx, userID, types.CreatePostReq{Content: gofakeit.Dessert()})
+	st.NoError(err, "failed to create post")
+
+	err = st.svc.Delete(ctx, post.ID, types.Actor{ID: uuid.New(), Role: types.RoleUser})
+	st.ErrorIs(err, ErrAccessDenied, "expected users not to delete posts of others")
+
+	_, err = st.svc.Update(ctx, post.ID, types.Actor{ID: uuid.New(), Role: types.RoleModerator}, types.UpdatePostReq{Content: strToPtr(gofakeit.CarModel())})
+	st.ErrorIs(err, ErrAccessDenied, "expected moderators not to edit posts of others")
+
+	in.Email = gofakeit.Email()
+	in.Username = randomUsername()
+	moderatorID, err := st.authSvc.SignUp(ctx, in)
+	st.NoError(err, "failed to signup")
+
+	err = st.svc.Delete(ctx, post.ID, types.Actor{ID: moderatorID, Role: types.RoleModerator})
+	st.NoError(err, "expected moderators to delete posts of others")
+
+	actions, err := st.repo.Moderation.GetAll(ctx)
+	st.Require().NoError(err, "failed to get moderation actions")
+	st.True(slices.ContainsFunc(actions, func(a types.ModerationAction) bool {
+		return a.TargetID == post.ID && *a.ModeratorID == moderatorID && a.Action == types.ModerationDeletePost
+	}), "expected the delete to be attributed to the moderator")
+
+	_, err = st.svc.GetByID(ctx, post.ID, userID)
+	st.ErrorIs(err, repoerrs.ErrPostNotFound, "expected post to be deleted")
+}
+
 func (st *postServiceSuite) TestUpdateExistingPost() {
 	ctx := context.Background()
 
 	in := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -131,26 +282,71 @@ func (st *postServiceSuite) TestUpdateExistingPost() {
 	st.NotEmpty(userID, "expected to get user id")
 
 	postIn := types.CreatePostReq{
-		Content:  gofakeit.Dessert(),
-		PhotoURL: gofakeit.URL(),
+		Content: gofakeit.Dessert(),
 	}
 	post, err := st.svc.Create(ctx, userID, postIn)
 	st.NoError(err, "failed to create post")
 	st.NotEmpty(post, "expected to get post")
 
+	file, err := st.repo.File.Create(ctx, newFileRecord(userID))
+	st.Require().NoError(err, "failed to create file")
+
 	updateIn := types.UpdatePostReq{
-		Content:  strToPtr(gofakeit.CarModel()),
-		PhotoURL: strToPtr(gofakeit.URL()),
+		Content: strToPtr(gofakeit.CarModel()),
+		Media:   &[]types.PostMediaReq{{FileID: file.ID, AltText: gofakeit.Sentence(3)}},
 	}
-	updatedPost, err := st.svc.Update(ctx, post.ID, userID, updateIn)
+	updatedPost, err := st.svc.Update(ctx, post.ID, types.Actor{ID: userID, Role: types.RoleUser}, updateIn)
 	st.NoError(err, "failed to update post")
 	st.NotEmpty(updatedPost, "expected to get post")
 
 	st.Equal(*updateIn.Content, updatedPost.Content, "post content: expected %s, got %s", *updateIn.Content, updatedPost.Content)
-	if updatedPost.PhotoURL != nil {
-		st.Equal(*updateIn.PhotoURL, *updatedPost.PhotoURL, "post photo url: expected %s, got %s", *updateIn.PhotoURL, *updatedPost.PhotoURL)
-	} else {
-		st.Fail("updatedPost photo url is nil, but expected a value")
+	st.Equal([]types.PostMedia{{FileID: file.ID, URL: file.URL, AltText: (*updateIn.Media)[0].AltText}}, updatedPost.Media)
+}
+
+func (st *postServiceSuite) TestCreateWithMedia() {
+	ctx := context.Background()
+
+	var ids []uuid.UUID
+	for i := 0; i < 2; i++ {
+		id, err := st.authSvc.SignUp(ctx, types.CreateUserReq{
+			Username:  randomUsername(),
+			FirstName: gofakeit.FirstName(),
+			LastName:  gofakeit.LastName(),
+			Email:     gofakeit.Email(),
+			Password:  randomPw(),
+		})
+		st.Require().NoError(err, "failed to signup")
+		ids = append(ids, id)
+	}
+	author, other := ids[0], ids[1]
+
+	var media []types.PostMediaReq
+	for i := 0; i < types.MaxPostMedia+1; i++ {
+		file, err := st.repo.File.Create(ctx, newFileRecord(author))
+		st.Require().NoError(err, "failed to create file")
+		media = append(media, types.PostMediaReq{FileID: file.ID, AltText: gofakeit.Sentence(3)})
+	}
+	othersFile, err := st.repo.File.Create(ctx, newFileRecord(other))
+	st.Require().NoError(err, "failed to create file")
+
+	_, err = st.svc.Create(ctx, author, types.CreatePostReq{Content: gofakeit.Dessert(), Media: media})
+	st.ErrorIs(err, ErrTooManyMedia)
+	_, err = st.svc.Create(ctx, author, types.CreatePostReq{Content: gofakeit.Dessert(), Media: []types.PostMediaReq{media[0], media[0]}})
+	st.ErrorIs(err, ErrDuplicateMedia)
+	_, err = st.svc.Create(ctx, author, types.CreatePostReq{Content: gofakeit.Dessert(), Media: []types.PostMediaReq{{FileID: othersFile.ID}}})
+	st.ErrorIs(err, ErrMediaNotFound, "expected files of others to be rejected")
+
+	// attachments keep the order they were given in
+	attached := []types.PostMediaReq{media[2], media[0], media[1]}
+	post, err := st.svc.Create(ctx, author, types.CreatePostReq{Content: gofakeit.Dessert(), Media: attached})
+	st.Require().NoError(err, "failed to create post")
+
+	got, err := st.repo.Post.GetByID(ctx, post.ID)
+	st.Require().NoError(err, "failed to get post")
+	st.Require().Len(got.Media, len(attached))
+	for i, m := range attached {
+		st.Equal(m.FileID, got.Media[i].FileID)
+		st.Equal(m.AltText, got.Media[i].AltText)
 	}
 }
 
@@ -158,15 +354,67 @@ func (st *postServiceSuite) TestUpdateNotFoundPost() {
 	ctx := context.Background()
 
 	updateIn := types.UpdatePostReq{
-		Content:  strToPtr(gofakeit.CarModel()),
-		PhotoURL: strToPtr(gofakeit.URL()),
+		Content: strToPtr(gofakeit.CarModel()),
 	}
-	updatedPost, err := st.svc.Update(ctx, uuid.New(), uuid.New(), updateIn)
+	updatedPost, err := st.svc.Update(ctx, uuid.New(), types.Actor{ID: uuid.New(), Role: types.RoleUser}, updateIn)
 	st.Error(err, "expected to get error: post not found")
 	st.ErrorIs(err, repoerrs.ErrPostNotFound, "expected to get post not found error")
 	st.Empty(updatedPost, "expected to get no post data")
 }
 
+func (st *postServiceSuite) TestGetAllPages() {
+	ctx := context.Background()
+
+	userID, err := st.authSvc.SignUp(ctx, types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     gofakeit.Email(),
+		Password:  randomPw(),
+	})
+	st.Require().NoError(err, "failed to signup")
+
+	var created []uuid.UUID
+	for i := 0; i < 5; i++ {
+		post, err := st.svc.Create(ctx, userID, types.CreatePostReq{Content: gofakeit.Dessert()})
+		st.Require().NoError(err, "failed to create post")
+		created = append([]uuid.UUID{post.ID}, created...)
+	}
+
+	var (
+		ids  []uuid.UUID
+		seen = make(map[uuid.UUID]bool)
+		page = types.CursorPage{Limit: 2}
+	)
+	for {
+		posts, err := st.svc.GetAll(ctx, userID, page)
+		st.Require().NoError(err, "failed to get posts")
+		st.LessOrEqual(len(posts.Items), 2, "expected the page size to be respected")
+		for _, p := range posts.Items {
+			st.False(seen[p.ID], "expected every post once")
+			seen[p.ID] = true
+			if p.UserID == userID {
+				ids = append(ids, p.ID)
+			}
+		}
+		if posts.NextCursor == nil {
+			break
+		}
+		page.After = posts.NextCursor
+	}
+	st.Equal(created, ids, "expected posts newest first")
+}
+
+func newFileRecord(userID uuid.UUID) types.FileRecord {
+	return types.FileRecord{
+		UserID:      userID,
+		ObjectKey:   uuid.NewString(),
+		URL:         gofakeit.URL(),
+		ContentType: "image/png",
+		Size:        1024,
+	}
+}
+
 func TestPostService(t *testing.T) {
 	suite.Run(t, new(postServiceSuite))
 }
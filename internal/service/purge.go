@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/s3"
+	"github.com/escoutdoor/social/internal/types"
+)
+
+type PurgeOpts struct {
+	// Grace is how long a deleted account can be restored by signing in
+	// before it is purged.
+	Grace time.Duration
+	// Interval is how often the purge job looks for accounts to purge.
+	Interval time.Duration
+	// BatchSize is the number of accounts purged per query.
+	BatchSize int
+}
+
+var DefaultPurgeOpts = PurgeOpts{
+	Grace:     time.Hour * 24 * 30,
+	Interval:  time.Hour,
+	BatchSize: 100,
+}
+
+// AccountPurger removes accounts for good once their grace period is over,
+// together with the objects they stored in S3.
+type AccountPurger struct {
+	userRepo repository.User
+	fileRepo repository.File
+	s3       s3.Repository
+	opts     PurgeOpts
+}
+
+func NewAccountPurger(userRepo repository.User, fileRepo repository.File, s3 s3.Repository, opts PurgeOpts) *AccountPurger {
+	return &AccountPurger{
+		userRepo: userRepo,
+		fileRepo: fileRepo,
+		s3:       s3,
+		opts:     opts,
+	}
+}
+
+// Run purges accounts every interval until the context is cancelled.
+func (p *AccountPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := p.Purge(ctx)
+		if err != nil {
+			slog.Error("AccountPurger.Run - AccountPurger.Purge", "error", err)
+		} else if n > 0 {
+			slog.Info("purged deleted accounts", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes every account whose grace period is over and returns how
+// many were removed. An account that fails to purge is left for the next
+// run.
+func (p *AccountPurger) Purge(ctx context.Context) (int, error) {
+	var purged int
+	for {
+		users, err := p.userRepo.GetDeactivated(ctx, time.Now().Add(-p.opts.Grace), p.opts.BatchSize)
+		if err != nil {
+			return purged, err
+		}
+		var failed bool
+		for _, user := range users {
+			if err := p.purge(ctx, user); err != nil {
+				slog.Error("AccountPurger.Purge - AccountPurger.purge", "user_id", user.ID, "error", err)
+				failed = true
+				continue
+			}
+			purged++
+		}
+		// a failed account would come back in the next batch
+		if failed || len(users) < p.opts.BatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// purge deletes the objects of the user before the rows, which still tell
+// what objects there are. If it fails halfway, the next run starts over.
+//
+// Only objects of the files the user uploaded are deleted. The avatar and
+// media URLs are not to be trusted, they could name an object of someone
+// else.
+func (p *AccountPurger) purge(ctx context.Context, user types.User) error {
+	files, err := p.fileRepo.GetByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	urls := make([]string, 0, len(files))
+	for _, f := range files {
+		urls = append(urls, f.URL)
+		for _, url := range f.Renditions {
+			urls = append(urls, url)
+		}
+	}
+	for _, url := range urls {
+		id, ok := p.s3.ObjectID(url)
+		if !ok {
+			continue
+		}
+		if err := p.s3.Delete(id); err != nil {
+			return err
+		}
+	}
+	if err := p.s3.RemoveExports(ctx, user.ID.String()+"/"); err != nil {
+		return err
+	}
+	return p.userRepo.Delete(ctx, user.ID)
+}
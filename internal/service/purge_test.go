@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/s3"
+	"github.com/escoutdoor/social/internal/testutils"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/stretchr/testify/suite"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+type purgeSuite struct {
+	suite.Suite
+	container      testcontainers.Container
+	minioContainer testcontainers.Container
+	repo           *repository.Repository
+	s3             s3.Repository
+	svc            *AccountPurger
+	authSvc        *AuthService
+	userSvc        User
+}
+
+func (st *purgeSuite) SetupSuite() {
+	container, db, err := testutils.NewPostgresContainer()
+	st.Require().NoError(err, "failed to run postgres container")
+	st.Require().NotEmpty(db, "expected to get db connection")
+
+	minioContainer, s3, err := testutils.NewMinIOContainer()
+	st.Require().NoError(err, "failed to run minio container")
+	st.Require().NotEmpty(s3, "expected to get minio connection")
+
+	st.container = container
+	st.minioContainer = minioContainer
+	st.repo = repository.New(db)
+	st.s3 = s3
+
+	// without a grace period every deleted account is due right away
+	opts := DefaultPurgeOpts
+	opts.Grace = 0
+	st.svc = NewAccountPurger(st.repo.User, st.repo.File, s3, opts)
+	authOpts := newAuthServiceOpts(st.repo, NewMemoryMailer())
+	authOpts.DeletionGrace = 0
+	st.authSvc = NewAuthService(authOpts)
+	st.userSvc = NewUserService(st.repo.User, st.repo.Follow, st.repo.File, nil, nil, nil, DefaultUsernameOpts)
+}
+
+func (st *purgeSuite) TearDownSuite() {
+	err := st.container.Terminate(context.Background())
+	st.Require().NoError(err, "failed to terminate postgres container")
+
+	err = st.minioContainer.Terminate(context.Background())
+	st.Require().NoError(err, "failed to terminate minio container")
+}
+
+func (st *purgeSuite) TestPurge() {
+	ctx := context.Background()
+
+	in := types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     gofakeit.Email(),
+		Password:  randomPw(),
+	}
+	id, err := st.authSvc.SignUp(ctx, in)
+	st.Require().NoError(err, "failed to signup")
+
+	photo := []byte("photo")
+	photoURL, err := st.s3.Create(types.File{Name: "photo.png", Payload: bytes.NewReader(photo), Size: int64(len(photo))})
+	st.Require().NoError(err, "failed to upload photo")
+	objectID, _ := st.s3.ObjectID(photoURL)
+	file, err := st.repo.File.Create(ctx, types.FileRecord{
+		UserID:      id,
+		ObjectKey:   objectID,
+		URL:         photoURL,
+		ContentType: "image/png",
+		Size:        int64(len(photo)),
+	})
+	st.Require().NoError(err, "failed to record photo")
+	_, err = st.repo.Post.Create(ctx, id, types.CreatePostReq{Content: gofakeit.Sentence(5), Media: []types.PostMediaReq{{FileID: file.ID}}}, nil)
+	st.Require().NoError(err, "failed to create post")
+
+	// an upload that never made it onto a post, with a smaller copy of it
+	thumb := []byte("thumb")
+	thumbURL, err := st.s3.Create(types.File{Name: "thumb.jpg", Payload: bytes.NewReader(thumb), Size: int64(len(thumb))})
+	st.Require().NoError(err, "failed to upload rendition")
+	thumbID, _ := st.s3.ObjectID(thumbURL)
+	draftURL, err := st.s3.Create(types.File{Name: "draft.png", Payload: bytes.NewReader(photo), Size: int64(len(photo))})
+	st.Require().NoError(err, "failed to upload draft")
+	draftID, _ := st.s3.ObjectID(draftURL)
+	_, err = st.repo.File.Create(ctx, types.FileRecord{
+		UserID:      id,
+		ObjectKey:   draftID,
+		URL:         draftURL,
+		ContentType: "image/png",
+		Size:        int64(len(photo)),
+		Renditions:  types.Renditions{"thumb": thumbURL},
+	})
+	st.Require().NoError(err, "failed to record draft")
+
+	// an avatar can be any URL, including an object of someone else
+	other := []byte("other")
+	otherURL, err := st.s3.Create(types.File{Name: "other.png", Payload: bytes.NewReader(other), Size: int64(len(other))})
+	st.Require().NoError(err, "failed to upload object of another user")
+	otherID, _ := st.s3.ObjectID(otherURL)
+	user, err := st.repo.User.GetByID(ctx, id)
+	st.Require().NoError(err, "failed to get user")
+	_, err = st.userSvc.Update(ctx, *user, types.UpdateUserReq{AvatarURL: &otherURL})
+	st.Require().NoError(err, "failed to set avatar")
+
+	st.Require().NoError(st.userSvc.Delete(ctx, id), "failed to delete user")
+	_, err = st.authSvc.SignIn(ctx, types.LoginReq{Email: in.Email, Password: in.Password}, types.SessionMeta{})
+	st.ErrorIs(err, ErrAccountDeleted, "expected sign in to fail after the grace period")
+
+	n, err := st.svc.Purge(ctx)
+	st.Require().NoError(err, "failed to purge")
+	st.GreaterOrEqual(n, 1)
+
+	_, err = st.repo.User.GetByID(ctx, id)
+	st.ErrorIs(err, repoerrs.ErrUserNotFound, "expected the user to be purged")
+	_, err = st.s3.Open(ctx, objectID)
+	st.ErrorIs(err, s3.ErrObjectNotFound, "expected the photo to be removed")
+	_, err = st.s3.Open(ctx, draftID)
+	st.ErrorIs(err, s3.ErrObjectNotFound, "expected the unattached upload to be removed")
+	_, err = st.s3.Open(ctx, thumbID)
+	st.ErrorIs(err, s3.ErrObjectNotFound, "expected the rendition of the upload to be removed")
+	obj, err := st.s3.Open(ctx, otherID)
+	st.Require().NoError(err, "expected an object the user did not upload to be kept")
+	obj.Close()
+}
+
+func TestAccountPurger(t *testing.T) {
+	suite.Run(t, new(purgeSuite))
+}
@@ -9,35 +9,98 @@ import (
 	"github.com/escoutdoor/social/internal/repository"
 	"github.com/escoutdoor/social/internal/s3"
 	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/jwtkeys"
+	"github.com/escoutdoor/social/pkg/oidc"
 	"github.com/escoutdoor/social/pkg/validator"
 	"github.com/google/uuid"
 )
 
 type Auth interface {
-	ParseToken(jwtToken string) (uuid.UUID, error)
-	SignIn(ctx context.Context, input types.LoginReq) (string, error)
+	ParseToken(ctx context.Context, jwtToken string) (*AuthTokenClaims, error)
+	SignIn(ctx context.Context, input types.LoginReq, meta types.SessionMeta) (*types.SignInResult, error)
 	SignUp(ctx context.Context, input types.CreateUserReq) (uuid.UUID, error)
+	Refresh(ctx context.Context, refreshToken string) (*types.Tokens, error)
+	Logout(ctx context.Context, refreshToken string) error
+	GetSessions(ctx context.Context, userID uuid.UUID, currentSessionID uuid.UUID) ([]types.Session, error)
+	RevokeSession(ctx context.Context, userID uuid.UUID, sessionID uuid.UUID) error
+	RevokeOtherSessions(ctx context.Context, userID uuid.UUID, currentSessionID uuid.UUID) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, input types.ResetPasswordReq) error
+	RequestMagicLink(ctx context.Context, email, ip string) error
+	SignInWithMagicLink(ctx context.Context, token string, meta types.SessionMeta) (*types.SignInResult, error)
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, user types.User) error
+	SetupTwoFactor(ctx context.Context, user types.User) (*types.TwoFactorSetup, error)
+	ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	DisableTwoFactor(ctx context.Context, userID uuid.UUID, code string) error
+	VerifyTwoFactor(ctx context.Context, input types.TwoFactorVerifyReq, meta types.SessionMeta) (*types.Tokens, error)
+	JWKS() jwtkeys.JWKS
+	StartOIDC(ctx context.Context, providerName string) (string, error)
+	FinishOIDC(ctx context.Context, providerName string, input types.OIDCCallbackReq, meta types.SessionMeta) (*types.SignInResult, error)
+}
+
+type APIKey interface {
+	Create(ctx context.Context, userID uuid.UUID, input types.CreateAPIKeyReq) (*types.CreatedAPIKey, error)
+	GetAll(ctx context.Context, userID uuid.UUID) ([]types.APIKey, error)
+	Revoke(ctx context.Context, userID uuid.UUID, id uuid.UUID) error
+	Authenticate(ctx context.Context, key string) (*types.APIKey, error)
+}
+
+type Lockout interface {
+	GetLockouts(ctx context.Context) ([]types.Lockout, error)
+	Unlock(ctx context.Context, kind types.LockoutKind, subject string, adminID uuid.UUID) error
+}
+
+type Moderation interface {
+	GetActions(ctx context.Context) ([]types.ModerationAction, error)
 }
 
 type User interface {
 	GetByID(ctx context.Context, id uuid.UUID) (*types.User, error)
+	GetProfile(ctx context.Context, id uuid.UUID, viewerID uuid.UUID) (*types.User, error)
+	GetByUsername(ctx context.Context, username string, viewerID uuid.UUID) (*types.User, error)
+	Search(ctx context.Context, query string, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
 	Update(ctx context.Context, user types.User, input types.UpdateUserReq) (*types.User, error)
+	UpdateRole(ctx context.Context, id uuid.UUID, role types.Role, adminID uuid.UUID) (*types.User, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
+type Follow interface {
+	Follow(ctx context.Context, followerID uuid.UUID, followeeID uuid.UUID) (types.FollowStatus, error)
+	Unfollow(ctx context.Context, followerID uuid.UUID, followeeID uuid.UUID) error
+	GetFollowers(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
+	GetFollowing(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
+	GetRequests(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
+	ApproveRequest(ctx context.Context, userID uuid.UUID, requesterID uuid.UUID) error
+	RejectRequest(ctx context.Context, userID uuid.UUID, requesterID uuid.UUID) error
+}
+
+type Block interface {
+	Block(ctx context.Context, blockerID uuid.UUID, blockedID uuid.UUID) error
+	Unblock(ctx context.Context, blockerID uuid.UUID, blockedID uuid.UUID) error
+	GetBlocked(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
+	Mute(ctx context.Context, muterID uuid.UUID, mutedID uuid.UUID) error
+	Unmute(ctx context.Context, muterID uuid.UUID, mutedID uuid.UUID) error
+	GetMuted(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
+}
+
+type Feed interface {
+	Get(ctx context.Context, userID uuid.UUID, page types.CursorPage) (types.Page[types.Post], error)
+}
+
 type Post interface {
 	Create(ctx context.Context, userID uuid.UUID, input types.CreatePostReq) (*types.Post, error)
-	Update(ctx context.Context, postID uuid.UUID, userID uuid.UUID, input types.UpdatePostReq) (*types.Post, error)
-	GetByID(ctx context.Context, id uuid.UUID) (*types.Post, error)
-	GetAll(ctx context.Context) ([]types.Post, error)
-	Delete(ctx context.Context, postID uuid.UUID, userID uuid.UUID) error
+	Update(ctx context.Context, postID uuid.UUID, actor types.Actor, input types.UpdatePostReq) (*types.Post, error)
+	GetByID(ctx context.Context, id uuid.UUID, viewerID uuid.UUID) (*types.Post, error)
+	GetAll(ctx context.Context, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.Post], error)
+	Delete(ctx context.Context, postID uuid.UUID, actor types.Actor) error
 }
 
 type Comment interface {
 	Create(ctx context.Context, userID uuid.UUID, postID uuid.UUID, input types.CreateCommentReq) (uuid.UUID, error)
-	GetByID(ctx context.Context, id uuid.UUID) (*types.Comment, error)
-	GetAll(ctx context.Context, postID uuid.UUID) ([]types.Comment, error)
-	Delete(ctx context.Context, commentID uuid.UUID, userID uuid.UUID) error
+	GetByID(ctx context.Context, id uuid.UUID, viewerID uuid.UUID) (*types.Comment, error)
+	GetAll(ctx context.Context, postID uuid.UUID, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.Comment], error)
+	Delete(ctx context.Context, commentID uuid.UUID, actor types.Actor) error
 }
 
 type Like interface {
@@ -45,10 +108,20 @@ type Like interface {
 	LikeComment(ctx context.Context, commentID uuid.UUID, userID uuid.UUID) error
 	RemoveLikeFromPost(ctx context.Context, postID uuid.UUID, userID uuid.UUID) error
 	RemoveLikeFromComment(ctx context.Context, commentID uuid.UUID, userID uuid.UUID) error
+	GetPostLikers(ctx context.Context, postID uuid.UUID, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
+	GetCommentLikers(ctx context.Context, commentID uuid.UUID, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.User], error)
+}
+
+type Export interface {
+	Request(ctx context.Context, user types.User) (*types.DataExport, error)
+	Get(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*types.DataExport, error)
 }
 
 type File interface {
-	Create(ctx context.Context, src io.Reader, hdr *multipart.FileHeader) (string, error)
+	Create(ctx context.Context, userID uuid.UUID, src io.ReadSeeker, hdr *multipart.FileHeader) (*types.FileRecord, error)
+	GetByID(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*types.FileRecord, error)
+	Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	MaxSize() int64
 }
 
 type Opts struct {
@@ -56,26 +129,75 @@ type Opts struct {
 	Cache      cache.Repository
 	S3         s3.Repository
 	Validator  *validator.Validator
+	Mailer     Mailer
 
-	SignKey string
+	Keys       *jwtkeys.KeySet
+	AppURL     string
+	TOTPIssuer string
+	LoginGuard LoginGuardOpts
+	Feed       FeedOpts
+	Username   UsernameOpts
+	Export     ExportOpts
+	Purge      PurgeOpts
+	Upload     UploadOpts
+	Providers  map[string]*oidc.Provider
 }
 
 func NewServices(opts Opts) *Services {
+	verifier := NewEmailVerifier(opts.Repository.EmailVerification, opts.Mailer, opts.AppURL)
+	guard := NewLoginGuard(opts.Cache, opts.LoginGuard)
+	moderation := NewModerationService(opts.Repository.Moderation)
+	feed := NewFeedService(opts.Repository.Post, opts.Repository.Follow, opts.Cache, opts.Feed)
+	blocks := NewBlockService(opts.Repository.Block, opts.Repository.Follow, feed)
+	follows := NewFollowService(opts.Repository.Follow, opts.Repository.User, blocks, feed)
 	return &Services{
-		Auth:    NewAuthService(opts.Repository.Auth, opts.Repository.User, opts.SignKey),
-		User:    NewUserService(opts.Repository.User, opts.Validator),
-		Post:    NewPostService(opts.Repository.Post, opts.Cache),
-		Comment: NewCommentService(opts.Repository.Comment, opts.Repository.Post),
-		Like:    NewLikeService(opts.Repository.Like, opts.Cache),
-		File:    NewFileService(opts.S3),
+		Auth: NewAuthService(AuthServiceOpts{
+			Repo:          opts.Repository.Auth,
+			UserRepo:      opts.Repository.User,
+			TokenRepo:     opts.Repository.RefreshToken,
+			SessionRepo:   opts.Repository.Session,
+			ResetRepo:     opts.Repository.PasswordReset,
+			MagicLinkRepo: opts.Repository.MagicLink,
+			TwoFactorRepo: opts.Repository.TwoFactor,
+			IdentityRepo:  opts.Repository.Identity,
+			Providers:     opts.Providers,
+			Guard:         guard,
+			Verifier:      verifier,
+			Mailer:        opts.Mailer,
+			Keys:          opts.Keys,
+			AppURL:        opts.AppURL,
+			TOTPIssuer:    opts.TOTPIssuer,
+			DeletionGrace: opts.Purge.Grace,
+		}),
+		User:       NewUserService(opts.Repository.User, opts.Repository.Follow, opts.Repository.File, blocks, verifier, opts.Validator, opts.Username),
+		Follow:     follows,
+		Block:      blocks,
+		Feed:       feed,
+		Post:       NewPostService(opts.Repository.Post, opts.Repository.File, opts.Cache, moderation, feed, follows),
+		Comment:    NewCommentService(opts.Repository.Comment, opts.Repository.Post, moderation, blocks, follows),
+		Like:       NewLikeService(opts.Repository.Like, opts.Repository.Post, opts.Repository.Comment, blocks, follows, opts.Cache),
+		File:       NewFileService(opts.Repository.File, opts.S3, opts.Upload),
+		Export:     NewExportService(opts.Repository.Export, opts.Repository.File, opts.S3, opts.Mailer, opts.Export),
+		Purger:     NewAccountPurger(opts.Repository.User, opts.Repository.File, opts.S3, opts.Purge),
+		APIKey:     NewAPIKeyService(opts.Repository.APIKey),
+		Lockout:    guard,
+		Moderation: moderation,
 	}
 }
 
 type Services struct {
 	Auth
 	User
+	Follow
+	Block
+	Feed
 	Post
 	Comment
 	Like
 	File
+	Export
+	APIKey
+	Lockout
+	Moderation
+	Purger *AccountPurger
 }
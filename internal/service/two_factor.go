@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/hasher"
+	"github.com/escoutdoor/social/pkg/totp"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	challengeTokenTTL = time.Minute * 5
+	challengeAudience = "2fa-challenge"
+
+	recoveryCodesCount = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func (s *AuthService) SetupTwoFactor(ctx context.Context, user types.User) (*types.TwoFactorSetup, error) {
+	enabled, err := s.isTwoFactorEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+	if err := s.twoFactorRepo.Upsert(ctx, user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	return &types.TwoFactorSetup{
+		Secret: secret,
+		URI:    totp.URI(s.totpIssuer, user.Email, secret, totp.DefaultOpts),
+	}, nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the user proves
+// the authenticator app is set up, and returns one-time recovery codes.
+func (s *AuthService) ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	tf, err := s.twoFactorRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf.EnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	step, ok, err := validateTOTP(tf.Secret, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.Enable(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	// the code that confirmed the setup can't sign in as well
+	if err := s.twoFactorRepo.UseStep(ctx, userID, step); err != nil && !errors.Is(err, repoerrs.ErrTOTPStepUsed) {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *AuthService) DisableTwoFactor(ctx context.Context, userID uuid.UUID, code string) error {
+	enabled, err := s.isTwoFactorEnabled(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return repoerrs.ErrTwoFactorNotFound
+	}
+	if err := s.guardSecondFactor(ctx, userID, "", code); err != nil {
+		return err
+	}
+	return s.twoFactorRepo.Delete(ctx, userID)
+}
+
+// VerifyTwoFactor completes a sign in started with SignIn for an account with
+// two-factor authentication enabled.
+func (s *AuthService) VerifyTwoFactor(ctx context.Context, input types.TwoFactorVerifyReq, meta types.SessionMeta) (*types.Tokens, error) {
+	userID, err := s.parseChallengeToken(input.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.guardSecondFactor(ctx, userID, meta.IP, input.Code); err != nil {
+		return nil, err
+	}
+	return s.startSession(ctx, userID, meta)
+}
+
+// guardSecondFactor checks a second factor with the attempts counted by the
+// login guard, the same way as passwords. Without that a six digit code
+// could be guessed within the lifetime of a challenge.
+func (s *AuthService) guardSecondFactor(ctx context.Context, userID uuid.UUID, ip string, code string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.guard.Check(ctx, user.Email, ip); err != nil {
+		return err
+	}
+	if err := s.checkSecondFactor(ctx, userID, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			if err := s.guard.Fail(ctx, user.Email, ip); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+	return s.guard.Succeed(ctx, user.Email)
+}
+
+func (s *AuthService) isTwoFactorEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	tf, err := s.twoFactorRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrTwoFactorNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return tf.EnabledAt != nil, nil
+}
+
+// checkSecondFactor accepts either a current TOTP code that was not used
+// before or an unused recovery code. Either is consumed.
+func (s *AuthService) checkSecondFactor(ctx context.Context, userID uuid.UUID, code string) error {
+	tf, err := s.twoFactorRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrTwoFactorNotFound) {
+			return ErrInvalidTwoFactorCode
+		}
+		return err
+	}
+	step, ok, err := validateTOTP(tf.Secret, code)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if err := s.twoFactorRepo.UseStep(ctx, userID, step); err != nil {
+			if errors.Is(err, repoerrs.ErrTOTPStepUsed) {
+				return ErrInvalidTwoFactorCode
+			}
+			return err
+		}
+		return nil
+	}
+
+	codes, err := s.twoFactorRepo.GetRecoveryCodes(ctx, userID)
+	if err != nil {
+		return err
+	}
+	normalized := normalizeRecoveryCode(code)
+	for _, rc := range codes {
+		if !hasher.ComparePw(normalized, rc.CodeHash) {
+			continue
+		}
+		if err := s.twoFactorRepo.UseRecoveryCode(ctx, rc.ID); err != nil {
+			if errors.Is(err, repoerrs.ErrRecoveryCodeNotFound) {
+				return ErrInvalidTwoFactorCode
+			}
+			return err
+		}
+		return nil
+	}
+	return ErrInvalidTwoFactorCode
+}
+
+type challengeClaims struct {
+	UserID uuid.UUID `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+func (s *AuthService) generateChallengeToken(userID uuid.UUID) (string, error) {
+	return s.keys.Sign(&challengeClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{challengeAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(challengeTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		UserID: userID,
+	})
+}
+
+func (s *AuthService) parseChallengeToken(challengeToken string) (uuid.UUID, error) {
+	token, err := jwt.ParseWithClaims(challengeToken, &challengeClaims{}, s.keys.Keyfunc, jwt.WithAudience(challengeAudience))
+	if err != nil {
+		return uuid.Nil, ErrInvalidToken
+	}
+	claims, ok := token.Claims.(*challengeClaims)
+	if !ok {
+		return uuid.Nil, ErrInvalidToken
+	}
+	return claims.UserID, nil
+}
+
+// validateTOTP reports whether code is valid now, along with the time step
+// it belongs to.
+func validateTOTP(secret, code string) (uint64, bool, error) {
+	key, err := totp.DecodeSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+	step, ok := totp.ValidateStep(strings.TrimSpace(code), key, time.Now(), totp.DefaultOpts)
+	return step, ok, nil
+}
+
+// generateRecoveryCodes returns codes formatted for the user along with their
+// hashes for storage.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+	for i := 0; i < recoveryCodesCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+		hash, err := hasher.HashPw(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to hash recovery code: %w", err)
+		}
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hash)
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
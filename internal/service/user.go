@@ -2,24 +2,52 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
 	"github.com/escoutdoor/social/internal/types"
 	"github.com/escoutdoor/social/pkg/hasher"
 	"github.com/escoutdoor/social/pkg/validator"
 	"github.com/google/uuid"
 )
 
+type UsernameOpts struct {
+	// ChangeCooldown is the minimum time between two renames of a user.
+	ChangeCooldown time.Duration
+	// RedirectTTL is how long an old username keeps pointing at its user
+	// before anyone else can claim it.
+	RedirectTTL time.Duration
+}
+
+var DefaultUsernameOpts = UsernameOpts{
+	ChangeCooldown: time.Hour * 24 * 30,
+	RedirectTTL:    time.Hour * 24 * 14,
+}
+
 type UserService struct {
-	repo      repository.User
-	validator *validator.Validator
+	repo       repository.User
+	followRepo repository.Follow
+	files      repository.File
+	blocks     *BlockService
+	verifier   *EmailVerifier
+	validator  *validator.Validator
+	opts       UsernameOpts
 }
 
-func NewUserService(repo repository.User, validator *validator.Validator) *UserService {
+func NewUserService(repo repository.User, followRepo repository.Follow, files repository.File, blocks *BlockService, verifier *EmailVerifier, validator *validator.Validator, opts UsernameOpts) *UserService {
 	return &UserService{
-		repo:      repo,
-		validator: validator,
+		repo:       repo,
+		followRepo: followRepo,
+		files:      files,
+		blocks:     blocks,
+		verifier:   verifier,
+		validator:  validator,
+		opts:       opts,
 	}
 }
 
@@ -27,17 +55,79 @@ func (s *UserService) GetByID(ctx context.Context, id uuid.UUID) (*types.User, e
 	return s.repo.GetByID(ctx, id)
 }
 
+// GetProfile returns the user together with the follower and following
+// counts. Deactivated users and users who share a block with the viewer are
+// reported as not found.
+func (s *UserService) GetProfile(ctx context.Context, id uuid.UUID, viewerID uuid.UUID) (*types.User, error) {
+	if err := s.blocks.check(ctx, viewerID, id); err != nil {
+		if errors.Is(err, ErrBlocked) {
+			return nil, repoerrs.ErrUserNotFound
+		}
+		return nil, err
+	}
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsDeactivated() {
+		return nil, repoerrs.ErrUserNotFound
+	}
+	followers, following, err := s.followRepo.Counts(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	user.FollowersCount = &followers
+	user.FollowingCount = &following
+	return user, nil
+}
+
+// GetByUsername returns the profile of the user holding the username. An old
+// username that still redirects resolves to the user who gave it up, so the
+// caller can tell by comparing the returned username.
+func (s *UserService) GetByUsername(ctx context.Context, username string, viewerID uuid.UUID) (*types.User, error) {
+	user, err := s.repo.GetByUsername(ctx, username)
+	if errors.Is(err, repoerrs.ErrUserNotFound) {
+		user, err = s.repo.GetByOldUsername(ctx, username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.GetProfile(ctx, user.ID, viewerID)
+}
+
+// Search finds users by name or username, best matches first.
+func (s *UserService) Search(ctx context.Context, query string, viewerID uuid.UUID, page types.CursorPage) (types.Page[types.User], error) {
+	return s.repo.Search(ctx, strings.TrimSpace(query), viewerID, page)
+}
+
 func (s *UserService) Update(ctx context.Context, user types.User, input types.UpdateUserReq) (*types.User, error) {
 	var err error
 
+	// the rename goes first so that a rejected username leaves the rest of
+	// the profile untouched
+	if input.Username != nil && *input.Username != user.Username {
+		if err := s.rename(ctx, user, *input.Username); err != nil {
+			return nil, err
+		}
+	}
 	if input.FirstName != nil {
 		user.FirstName = *input.FirstName
 	}
 	if input.LastName != nil {
 		user.LastName = *input.LastName
 	}
-	if input.Email != nil {
-		user.Email = *input.Email
+	// a new email only replaces the current one once it is confirmed
+	var emailChanged bool
+	if input.Email != nil && *input.Email != user.Email {
+		_, err := s.repo.GetByEmail(ctx, *input.Email)
+		if err == nil {
+			return nil, repoerrs.ErrEmailAlreadyExists
+		}
+		if !errors.Is(err, repoerrs.ErrUserNotFound) {
+			return nil, err
+		}
+		user.PendingEmail = input.Email
+		emailChanged = true
 	}
 	if input.Password != nil && !hasher.ComparePw(*input.Password, user.Password) {
 		user.Password, err = hasher.HashPw(*input.Password)
@@ -57,11 +147,64 @@ func (s *UserService) Update(ctx context.Context, user types.User, input types.U
 	}
 	if input.AvatarURL != nil {
 		user.AvatarURL = input.AvatarURL
+		if user.AvatarRenditions, err = s.avatarRenditions(ctx, user.ID, *input.AvatarURL); err != nil {
+			return nil, err
+		}
+	}
+	if input.IsPrivate != nil {
+		user.IsPrivate = *input.IsPrivate
+	}
+
+	updated, err := s.repo.Update(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if emailChanged {
+		if err := s.verifier.Send(ctx, user.ID, *user.PendingEmail); err != nil {
+			return nil, fmt.Errorf("failed to send verification email: %w", err)
+		}
+	}
+	return updated, nil
+}
+
+// avatarRenditions returns the renditions of the avatar when it is a file the
+// user uploaded. Avatars linked from elsewhere have none.
+func (s *UserService) avatarRenditions(ctx context.Context, userID uuid.UUID, url string) (types.Renditions, error) {
+	file, err := s.files.GetByURL(ctx, userID, url)
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrFileNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return file.Renditions, nil
+}
+
+func (s *UserService) rename(ctx context.Context, user types.User, username string) error {
+	// fixing the letter case is always allowed
+	if !strings.EqualFold(user.Username, username) && user.UsernameChangedAt != nil &&
+		time.Since(*user.UsernameChangedAt) < s.opts.ChangeCooldown {
+		return ErrUsernameCooldown
 	}
+	return s.repo.UpdateUsername(ctx, user.ID, username, time.Now().Add(s.opts.RedirectTTL))
+}
 
-	return s.repo.Update(ctx, user)
+// UpdateRole changes the role of a user. Admins cannot change their own role
+// so that the last admin cannot lock everyone out by accident.
+func (s *UserService) UpdateRole(ctx context.Context, id uuid.UUID, role types.Role, adminID uuid.UUID) (*types.User, error) {
+	if id == adminID {
+		return nil, ErrCannotDemoteSelf
+	}
+	if err := s.repo.UpdateRole(ctx, id, role); err != nil {
+		return nil, err
+	}
+	slog.Info("user role changed", "user_id", id, "role", role, "admin_id", adminID)
+	return s.repo.GetByID(ctx, id)
 }
 
+// Delete deactivates the account. Its content is hidden right away, but the
+// user can restore everything by signing in again before the account is
+// purged.
 func (s *UserService) Delete(ctx context.Context, id uuid.UUID) error {
-	return s.repo.Delete(ctx, id)
+	return s.repo.Deactivate(ctx, id)
 }
@@ -2,11 +2,13 @@ package service
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/brianvoe/gofakeit/v7"
 	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
 	"github.com/escoutdoor/social/internal/testutils"
 	"github.com/escoutdoor/social/internal/types"
 	"github.com/escoutdoor/social/pkg/validator"
@@ -18,8 +20,10 @@ import (
 type userServiceSuite struct {
 	suite.Suite
 	container testcontainers.Container
+	repo      *repository.Repository
 	svc       User
 	authSvc   Auth
+	mailer    *MemoryMailer
 }
 
 func (st *userServiceSuite) SetupSuite() {
@@ -31,8 +35,10 @@ func (st *userServiceSuite) SetupSuite() {
 	repo := repository.New(db)
 
 	st.container = container
-	st.svc = NewUserService(repo.User, validator.New())
-	st.authSvc = NewAuthService(repo.Auth, repo.User, signKey)
+	st.repo = repo
+	st.mailer = NewMemoryMailer()
+	st.svc = NewUserService(repo.User, repo.Follow, repo.File, nil, NewEmailVerifier(repo.EmailVerification, st.mailer, "http://localhost"), validator.New(), DefaultUsernameOpts)
+	st.authSvc = newAuthService(repo, st.mailer)
 }
 
 func (st *userServiceSuite) TearDownSuite() {
@@ -43,6 +49,7 @@ func (st *userServiceSuite) TearDownSuite() {
 func (st *userServiceSuite) TestGetByIDExistingUser() {
 	ctx := context.Background()
 	in := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -76,6 +83,7 @@ func (st *userServiceSuite) TestDeleteNotFound() {
 func (st *userServiceSuite) TestDeleteExistingUser() {
 	ctx := context.Background()
 	in := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -88,6 +96,16 @@ func (st *userServiceSuite) TestDeleteExistingUser() {
 
 	err = st.svc.Delete(ctx, id)
 	st.NoError(err, "failed to delete user")
+
+	_, err = st.svc.GetProfile(ctx, id, uuid.New())
+	st.ErrorIs(err, repoerrs.ErrUserNotFound, "expected deleted users to be hidden")
+	err = st.svc.Delete(ctx, id)
+	st.ErrorIs(err, repoerrs.ErrUserNotFound, "expected the user to be deleted already")
+
+	_, err = st.authSvc.SignIn(ctx, types.LoginReq{Email: in.Email, Password: in.Password}, types.SessionMeta{})
+	st.Require().NoError(err, "expected to sign in during the grace period")
+	_, err = st.svc.GetProfile(ctx, id, uuid.New())
+	st.NoError(err, "expected signing in to restore the account")
 }
 
 func (st *userServiceSuite) TestUpdateNotFound() {
@@ -118,6 +136,7 @@ func (st *userServiceSuite) TestUpdateNotFound() {
 func (st *userServiceSuite) TestGetByIDUpdate() {
 	ctx := context.Background()
 	in := types.CreateUserReq{
+		Username:  randomUsername(),
 		FirstName: gofakeit.FirstName(),
 		LastName:  gofakeit.LastName(),
 		Email:     gofakeit.Email(),
@@ -143,7 +162,144 @@ func (st *userServiceSuite) TestGetByIDUpdate() {
 
 	st.Equal(*updateIn.FirstName, u.FirstName, "user first name: expected %s, got %s", *updateIn.FirstName, u.FirstName)
 	st.Equal(*updateIn.LastName, u.LastName, "user last name: expected %s, got %s", *updateIn.LastName, u.LastName)
+	st.Equal(in.Email, u.Email, "user email should not change before confirmation")
+	st.Require().NotNil(u.PendingEmail, "expected to get pending email")
+	st.Equal(*updateIn.Email, *u.PendingEmail, "user pending email: expected %s, got %s", *updateIn.Email, *u.PendingEmail)
+
+	// the link sent on sign up still confirms the current address
+	mail, ok := st.mailer.Last(in.Email)
+	st.Require().True(ok, "expected to get sign up mail")
+	err = st.authSvc.VerifyEmail(ctx, tokenFromMail(mail))
+	st.NoError(err, "failed to confirm current email")
+	u, err = st.svc.GetByID(ctx, id)
+	st.NoError(err, "failed to get user")
+	st.Equal(in.Email, u.Email)
+	st.True(u.IsEmailVerified(), "expected the current email to be verified")
+	st.Require().NotNil(u.PendingEmail, "expected the email change to survive")
+	st.Equal(*updateIn.Email, *u.PendingEmail)
+
+	mail, ok = st.mailer.Last(*updateIn.Email)
+	st.Require().True(ok, "expected to get verification mail")
+	err = st.authSvc.VerifyEmail(ctx, tokenFromMail(mail))
+	st.NoError(err, "failed to confirm new email")
+
+	u, err = st.svc.GetByID(ctx, id)
+	st.NoError(err, "failed to get user")
 	st.Equal(*updateIn.Email, u.Email, "user email: expected %s, got %s", *updateIn.Email, u.Email)
+	st.Nil(u.PendingEmail, "expected pending email to be cleared")
+}
+
+func (st *userServiceSuite) TestUpdateAvatar() {
+	ctx := context.Background()
+	id, err := st.authSvc.SignUp(ctx, types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     gofakeit.Email(),
+		Password:  randomPw(),
+	})
+	st.Require().NoError(err, "failed to signup")
+	user, err := st.svc.GetByID(ctx, id)
+	st.Require().NoError(err, "failed to get user")
+
+	renditions := types.Renditions{"avatar": "http://localhost/media/avatar.jpg"}
+	file, err := st.repo.File.Create(ctx, types.FileRecord{
+		UserID:      id,
+		ObjectKey:   "photo.png",
+		URL:         "http://localhost/media/photo.png",
+		ContentType: "image/png",
+		Size:        1,
+		Renditions:  renditions,
+	})
+	st.Require().NoError(err, "failed to record file")
+
+	u, err := st.svc.Update(ctx, *user, types.UpdateUserReq{AvatarURL: strToPtr(file.URL)})
+	st.Require().NoError(err, "failed to update avatar")
+	st.Equal(renditions, u.AvatarRenditions, "expected the renditions of the uploaded avatar")
+
+	u, err = st.svc.Update(ctx, *u, types.UpdateUserReq{AvatarURL: strToPtr(gofakeit.URL())})
+	st.Require().NoError(err, "failed to update avatar")
+	st.Nil(u.AvatarRenditions, "expected no renditions for an avatar from elsewhere")
+
+	u, err = st.svc.GetByID(ctx, id)
+	st.Require().NoError(err, "failed to get user")
+	st.Nil(u.AvatarRenditions, "expected the renditions to be cleared")
+}
+
+func (st *userServiceSuite) TestRenameUsername() {
+	ctx := context.Background()
+	in := types.CreateUserReq{
+		Username:  randomUsername(),
+		FirstName: gofakeit.FirstName(),
+		LastName:  gofakeit.LastName(),
+		Email:     gofakeit.Email(),
+		Password:  randomPw(),
+	}
+	id, err := st.authSvc.SignUp(ctx, in)
+	st.Require().NoError(err, "failed to signup")
+	user, err := st.svc.GetByID(ctx, id)
+	st.Require().NoError(err, "failed to get user")
+
+	found, err := st.svc.GetByUsername(ctx, strings.ToUpper(in.Username), id)
+	st.Require().NoError(err, "failed to get user by username")
+	st.Equal(id, found.ID, "expected username lookup to be case-insensitive")
+
+	newUsername := randomUsername()
+	u, err := st.svc.Update(ctx, *user, types.UpdateUserReq{Username: &newUsername})
+	st.Require().NoError(err, "failed to rename user")
+	st.Equal(newUsername, u.Username, "expected to get the new username")
+
+	found, err = st.svc.GetByUsername(ctx, in.Username, id)
+	st.Require().NoError(err, "expected the old username to redirect")
+	st.Equal(newUsername, found.Username, "expected the old username to resolve to the current one")
+
+	other := in
+	other.Email = gofakeit.Email()
+	_, err = st.authSvc.SignUp(ctx, other)
+	st.ErrorIs(err, repoerrs.ErrUsernameTaken, "expected the old username to stay reserved")
+
+	again := randomUsername()
+	_, err = st.svc.Update(ctx, *u, types.UpdateUserReq{Username: &again})
+	st.ErrorIs(err, ErrUsernameCooldown, "expected renames to be rate limited")
+
+	upper := strings.ToUpper(newUsername)
+	u, err = st.svc.Update(ctx, *u, types.UpdateUserReq{Username: &upper})
+	st.Require().NoError(err, "expected a change of letter case to be allowed")
+	st.Equal(upper, u.Username)
+}
+
+func (st *userServiceSuite) TestSearch() {
+	ctx := context.Background()
+
+	base := "Quinlan" + strings.ToLower(gofakeit.LetterN(6))
+	var ids []uuid.UUID
+	for _, suffix := range []string{"a", "b"} {
+		id, err := st.authSvc.SignUp(ctx, types.CreateUserReq{
+			Username:  randomUsername(),
+			FirstName: base + suffix,
+			LastName:  gofakeit.LastName(),
+			Email:     gofakeit.Email(),
+			Password:  randomPw(),
+		})
+		st.Require().NoError(err, "failed to signup")
+		ids = append(ids, id)
+	}
+	viewerID := uuid.New()
+
+	first, err := st.svc.Search(ctx, strings.ToUpper(base), viewerID, types.CursorPage{Limit: 1})
+	st.Require().NoError(err, "failed to search users")
+	st.Require().Len(first.Items, 1)
+	st.Require().NotNil(first.NextCursor, "expected to get a second page")
+	second, err := st.svc.Search(ctx, base, viewerID, types.CursorPage{Limit: 1, After: first.NextCursor})
+	st.Require().NoError(err, "failed to search users")
+	st.Require().Len(second.Items, 1)
+	st.ElementsMatch(ids, []uuid.UUID{first.Items[0].ID, second.Items[0].ID}, "expected prefix matches on both pages")
+
+	typo := base[:len(base)-2] + "zz" + "a"
+	res, err := st.svc.Search(ctx, typo, viewerID, types.CursorPage{Limit: 10})
+	st.Require().NoError(err, "failed to search users")
+	st.Require().NotEmpty(res.Items, "expected a fuzzy match")
+	st.Equal(ids[0], res.Items[0].ID, "expected the closest name to rank first")
 }
 
 func TestUserService(t *testing.T) {
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/escoutdoor/social/internal/repository"
+	"github.com/escoutdoor/social/internal/repository/repoerrs"
+	"github.com/escoutdoor/social/internal/types"
+	"github.com/escoutdoor/social/pkg/hasher"
+	"github.com/google/uuid"
+)
+
+const verifyEmailTTL = time.Hour * 24
+
+// EmailVerifier issues and consumes single-use tokens proving that a user
+// owns an email address.
+type EmailVerifier struct {
+	repo   repository.EmailVerification
+	mailer Mailer
+	appURL string
+}
+
+func NewEmailVerifier(repo repository.EmailVerification, mailer Mailer, appURL string) *EmailVerifier {
+	return &EmailVerifier{
+		repo:   repo,
+		mailer: mailer,
+		appURL: appURL,
+	}
+}
+
+func (v *EmailVerifier) Send(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate verification token: %w", err)
+	}
+	err = v.repo.Create(ctx, types.EmailVerificationToken{
+		UserID:    userID,
+		Email:     email,
+		TokenHash: hasher.HashToken(token),
+		ExpiresAt: time.Now().Add(verifyEmailTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return v.mailer.Send(ctx, Mail{
+		To:      email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"Use the link below to confirm your email address. It expires in %s.\n\n%s/verify-email?token=%s\n",
+			verifyEmailTTL, v.appURL, token,
+		),
+	})
+}
+
+// Consume validates the token and marks it as used.
+func (v *EmailVerifier) Consume(ctx context.Context, token string) (*types.EmailVerificationToken, error) {
+	vt, err := v.repo.GetByHash(ctx, hasher.HashToken(token))
+	if err != nil {
+		if errors.Is(err, repoerrs.ErrVerifyTokenNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if vt.UsedAt != nil || time.Now().After(vt.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	if err := v.repo.MarkUsed(ctx, vt.ID); err != nil {
+		if errors.Is(err, repoerrs.ErrVerifyTokenNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	return vt, nil
+}
@@ -48,13 +48,14 @@ func NewMinIOContainer() (testcontainers.Container, s3.Repository, error) {
 
 	endpoint := fmt.Sprintf("%s:%s", host, port.Port())
 	s3, err := s3.New(s3.Opts{
-		MinIOBucketName: "testbucket",
-		MinIOEndpoint:   endpoint,
-		MinIOHost:       endpoint,
-		MinIOUser:       minioUser,
-		MinIOPw:         minioPw,
-		MinIOUseSSL:     false,
-		MinIORegion:     "auto",
+		MinIOBucketName:       "testbucket",
+		MinIOExportBucketName: "testexports",
+		MinIOEndpoint:         endpoint,
+		MinIOHost:             endpoint,
+		MinIOUser:             minioUser,
+		MinIOPw:               minioPw,
+		MinIOUseSSL:           false,
+		MinIORegion:           "auto",
 	})
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to connect to s3: %w", err)
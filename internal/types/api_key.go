@@ -0,0 +1,51 @@
+package types
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every API key, which tells them apart from JWT access
+// tokens in the Authorization header.
+const APIKeyPrefix = "sk_"
+
+const (
+	ScopePostsWrite    = "posts:write"
+	ScopeLikesWrite    = "likes:write"
+	ScopeCommentsWrite = "comments:write"
+	ScopeUsersWrite    = "users:write"
+	ScopeFilesWrite    = "files:write"
+)
+
+type APIKey struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"-"`
+	Name   string    `json:"name"`
+	// Prefix is the beginning of the key, shown so users can recognize it.
+	Prefix     string     `json:"prefix"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+type CreateAPIKeyReq struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=posts:write likes:write comments:write users:write files:write"`
+	ExpiresAt *time.Time `json:"expires_at" validate:"omitempty"`
+}
+
+// CreatedAPIKey is returned once, on creation. The key itself is not stored
+// and can't be shown again.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
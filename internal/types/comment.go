@@ -22,3 +22,7 @@ type CreateCommentReq struct {
 	Content         string     `json:"content" validate:"required,min=3"`
 	ParentCommentID *uuid.UUID `json:"parent_comment_id" validate:"omitempty,uuid"`
 }
+
+func (c Comment) Cursor() Cursor {
+	return Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+}
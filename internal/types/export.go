@@ -0,0 +1,38 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ExportStatus string
+
+const (
+	ExportStatusPending ExportStatus = "pending"
+	ExportStatusReady   ExportStatus = "ready"
+	ExportStatusFailed  ExportStatus = "failed"
+	// ExportStatusExpired is never stored. A ready export is reported as
+	// expired once its download link stops working.
+	ExportStatusExpired ExportStatus = "expired"
+)
+
+// DataExport is an archive of everything a user has stored with us. It is
+// built in the background and downloaded through a time-limited link.
+type DataExport struct {
+	ID          uuid.UUID    `json:"id"`
+	UserID      uuid.UUID    `json:"-"`
+	Status      ExportStatus `json:"status"`
+	ObjectKey   *string      `json:"-"`
+	DownloadURL *string      `json:"download_url,omitempty"`
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// ExportedLike is a like of a post or a comment in a data export.
+type ExportedLike struct {
+	PostID    *uuid.UUID `json:"post_id,omitempty"`
+	CommentID *uuid.UUID `json:"comment_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -1,9 +1,64 @@
 package types
 
-import "io"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type File struct {
-	Name    string
-	Payload io.Reader
-	Size    int64
+	Name        string
+	Payload     io.Reader
+	Size        int64
+	ContentType string
+	// Ext is appended to the key of the object, e.g. ".png".
+	Ext string
+}
+
+// Renditions maps the names of the scaled down copies of an image, such as
+// "avatar", to their URLs. It is stored as JSON.
+type Renditions map[string]string
+
+func (r Renditions) Value() (driver.Value, error) {
+	if r == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (r *Renditions) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	}
+	return fmt.Errorf("cannot scan %T into renditions", src)
+}
+
+// FileRecord is a file a user uploaded to the media bucket. Width and height
+// are only known for images, which also come with renditions.
+type FileRecord struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"-"`
+	ObjectKey   string     `json:"-"`
+	URL         string     `json:"url"`
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"`
+	SHA256      *string    `json:"sha256,omitempty"`
+	Width       *int       `json:"width,omitempty"`
+	Height      *int       `json:"height,omitempty"`
+	Renditions  Renditions `json:"renditions,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
 }
@@ -0,0 +1,10 @@
+package types
+
+// FollowStatus tells whether following a user took effect right away or
+// waits for a private account to approve the request.
+type FollowStatus string
+
+const (
+	FollowStatusFollowing FollowStatus = "following"
+	FollowStatusRequested FollowStatus = "requested"
+)
@@ -0,0 +1,33 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Identity links an account at an external OpenID Connect provider to a
+// user.
+type Identity struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"-"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"-"`
+	Email     *string   `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OIDCState keeps what is needed to finish a login started at a provider.
+type OIDCState struct {
+	StateHash string
+	Provider  string
+	Verifier  string
+	Nonce     string
+	ExpiresAt time.Time
+}
+
+type OIDCCallbackReq struct {
+	Code   string `json:"code" validate:"required"`
+	State  string `json:"state" validate:"required"`
+	Device string `json:"device" validate:"omitempty,max=255"`
+}
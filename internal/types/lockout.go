@@ -0,0 +1,20 @@
+package types
+
+import "time"
+
+type LockoutKind string
+
+const (
+	LockoutKindAccount LockoutKind = "account"
+	LockoutKindIP      LockoutKind = "ip"
+)
+
+// Lockout is a temporary ban on sign in attempts for an account (identified
+// by email) or for a client IP.
+type Lockout struct {
+	Kind      LockoutKind `json:"kind"`
+	Subject   string      `json:"subject"`
+	Failures  int64       `json:"failures"`
+	LockedAt  time.Time   `json:"locked_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
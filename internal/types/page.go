@@ -0,0 +1,35 @@
+package types
+
+// CursorPage selects up to Limit items after the cursor, or the first page
+// when After is nil.
+type CursorPage struct {
+	Limit int
+	After *Cursor
+}
+
+// Page is one page of a list ordered by creation time and ID, newest first.
+// NextCursor is nil on the last page.
+type Page[T any] struct {
+	Items      []T
+	NextCursor *Cursor
+}
+
+// NewPage builds a page from items fetched with a limit of one more than the
+// page size, the extra item only telling whether there is a next page.
+// cursor returns the cursor of the item at index i.
+func NewPage[T any](items []T, limit int, cursor func(i int) Cursor) Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	if len(items) <= limit {
+		return Page[T]{Items: items}
+	}
+	next := cursor(limit - 1)
+	return Page[T]{Items: items[:limit], NextCursor: &next}
+}
+
+// Query returns the page to ask the database for, one item larger than the
+// page size.
+func (p CursorPage) Query() CursorPage {
+	return CursorPage{Limit: p.Limit + 1, After: p.After}
+}
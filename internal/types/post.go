@@ -7,14 +7,29 @@ import (
 	"github.com/google/uuid"
 )
 
+// PostVisibility is the audience of a post. It narrows what the privacy of
+// the account allows and never widens it.
+type PostVisibility string
+
+const (
+	PostVisibilityPublic    PostVisibility = "public"
+	PostVisibilityFollowers PostVisibility = "followers"
+	// PostVisibilityMentioned shows the post only to the users mentioned in
+	// its content with an @username.
+	PostVisibilityMentioned PostVisibility = "mentioned"
+)
+
 type Post struct {
-	ID        uuid.UUID `json:"id"`
-	Content   string    `json:"content"`
-	UserID    uuid.UUID `json:"user_id"`
-	PhotoURL  *string   `json:"photo_url,omitempty"`
-	Likes     int       `json:"likes"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         uuid.UUID      `json:"id"`
+	Content    string         `json:"content"`
+	UserID     uuid.UUID      `json:"user_id"`
+	Media      []PostMedia    `json:"media"`
+	Visibility PostVisibility `json:"visibility"`
+	Likes      int            `json:"likes"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	// MentionIDs are only filled in when the post is created or updated.
+	MentionIDs []uuid.UUID `json:"-"`
 }
 
 func (p Post) MarshalBinary() ([]byte, error) {
@@ -35,12 +50,47 @@ func (p *Posts) UnmarshalBinary(data []byte) error {
 	return json.Unmarshal(data, p)
 }
 
+// MaxPostMedia is the number of files that can be attached to a post.
+const MaxPostMedia = 4
+
+// PostMedia is an uploaded file attached to a post. The media of a post are
+// kept in the order they were attached in.
+type PostMedia struct {
+	FileID     uuid.UUID  `json:"file_id"`
+	URL        string     `json:"url"`
+	Renditions Renditions `json:"renditions,omitempty"`
+	AltText    string     `json:"alt_text"`
+}
+
+type PostMediaReq struct {
+	FileID  uuid.UUID `json:"file_id"`
+	AltText string    `json:"alt_text" validate:"max=1000"`
+}
+
 type CreatePostReq struct {
-	Content  string `json:"content" validate:"required,min=3"`
-	PhotoURL string `json:"photo_url" validate:"omitempty,url"`
+	Content    string         `json:"content" validate:"required,min=3"`
+	Media      []PostMediaReq `json:"media" validate:"dive"`
+	Visibility PostVisibility `json:"visibility" validate:"omitempty,oneof=public followers mentioned"`
 }
 
+// UpdatePostReq changes the fields that are set. Media replace every
+// attachment of the post, an empty list removes them.
 type UpdatePostReq struct {
-	Content  *string `json:"content" validate:"omitempty,min=3"`
-	PhotoURL *string `json:"photo_url" validate:"omitempty,url"`
+	Content    *string         `json:"content" validate:"omitempty,min=3"`
+	Media      *[]PostMediaReq `json:"media" validate:"omitempty,dive"`
+	Visibility *PostVisibility `json:"visibility" validate:"omitempty,oneof=public followers mentioned"`
+}
+
+// TimelineEntry is a post in a precomputed home timeline.
+type TimelineEntry struct {
+	PostID    uuid.UUID
+	CreatedAt time.Time
+}
+
+func (p Post) TimelineEntry() TimelineEntry {
+	return TimelineEntry{PostID: p.ID, CreatedAt: p.CreatedAt}
+}
+
+func (p Post) Cursor() Cursor {
+	return Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
 }
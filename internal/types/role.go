@@ -0,0 +1,42 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Actor is who performs an action, as seen by the authorization policy.
+type Actor struct {
+	ID   uuid.UUID
+	Role Role
+}
+
+type UpdateRoleReq struct {
+	Role Role `json:"role" validate:"required,oneof=user moderator admin"`
+}
+
+type ModerationActionType string
+
+const (
+	ModerationDeletePost    ModerationActionType = "delete_post"
+	ModerationDeleteComment ModerationActionType = "delete_comment"
+)
+
+// ModerationAction records a moderator acting on content of another user.
+type ModerationAction struct {
+	ID           uuid.UUID            `json:"id"`
+	ModeratorID  *uuid.UUID           `json:"moderator_id"`
+	Action       ModerationActionType `json:"action"`
+	TargetID     uuid.UUID            `json:"target_id"`
+	TargetUserID *uuid.UUID           `json:"target_user_id"`
+	CreatedAt    time.Time            `json:"created_at"`
+}
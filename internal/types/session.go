@@ -0,0 +1,26 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Session struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"-"`
+	Device     *string    `json:"device,omitempty"`
+	UserAgent  *string    `json:"user_agent,omitempty"`
+	IP         *string    `json:"ip,omitempty"`
+	Current    bool       `json:"current"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// SessionMeta describes the client a session is opened from.
+type SessionMeta struct {
+	Device    string
+	UserAgent string
+	IP        string
+}
@@ -0,0 +1,44 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TwoFactor struct {
+	UserID    uuid.UUID
+	Secret    string
+	EnabledAt *time.Time
+	CreatedAt time.Time
+}
+
+type RecoveryCode struct {
+	ID       uuid.UUID
+	UserID   uuid.UUID
+	CodeHash string
+	UsedAt   *time.Time
+}
+
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type TwoFactorCodeReq struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorVerifyReq struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+	Device         string `json:"device" validate:"omitempty,max=255"`
+}
+
+// SignInResult holds either a token pair or, for accounts with two-factor
+// authentication, a challenge that must be completed with a code.
+type SignInResult struct {
+	*Tokens
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+}
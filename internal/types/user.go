@@ -10,19 +10,92 @@ import (
 )
 
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	DOB       *DOB      `json:"date_of_birth,omitempty"`
-	Bio       *string   `json:"bio,omitempty"`
-	AvatarURL *string   `json:"avatar_url,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID               uuid.UUID  `json:"id"`
+	Username         string     `json:"username"`
+	FirstName        string     `json:"first_name"`
+	LastName         string     `json:"last_name"`
+	Email            string     `json:"email"`
+	Password         string     `json:"-"`
+	DOB              *DOB       `json:"date_of_birth,omitempty"`
+	Bio              *string    `json:"bio,omitempty"`
+	AvatarURL        *string    `json:"avatar_url,omitempty"`
+	AvatarRenditions Renditions `json:"avatar_renditions,omitempty"`
+	// EmailVerifiedAt and PendingEmail are only shown to the user
+	// themselves, see Account.
+	EmailVerifiedAt *time.Time `json:"-"`
+	PendingEmail    *string    `json:"-"`
+	Role            Role       `json:"role"`
+	IsPrivate       bool       `json:"is_private"`
+	// UsernameChangedAt is nil until the user renames themselves for the
+	// first time.
+	UsernameChangedAt *time.Time `json:"-"`
+	// DeactivatedAt is set while the account waits to be purged after the
+	// user deleted it.
+	DeactivatedAt  *time.Time `json:"-"`
+	FollowersCount *int       `json:"followers_count,omitempty"`
+	FollowingCount *int       `json:"following_count,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Account is the user as the user themselves sees it, with the state of
+// their email address.
+type Account struct {
+	User
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	PendingEmail    *string    `json:"pending_email,omitempty"`
+}
+
+func NewAccount(u User) Account {
+	return Account{
+		User:            u,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		PendingEmail:    u.PendingEmail,
+	}
+}
+
+// PublicUser is a user as lists of users show them. Unlike a single profile
+// a list can be paged through, so it leaves out the email address.
+type PublicUser struct {
+	ID               uuid.UUID  `json:"id"`
+	Username         string     `json:"username"`
+	FirstName        string     `json:"first_name"`
+	LastName         string     `json:"last_name"`
+	Bio              *string    `json:"bio,omitempty"`
+	AvatarURL        *string    `json:"avatar_url,omitempty"`
+	AvatarRenditions Renditions `json:"avatar_renditions,omitempty"`
+	IsPrivate        bool       `json:"is_private"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+func (u User) Public() PublicUser {
+	return PublicUser{
+		ID:               u.ID,
+		Username:         u.Username,
+		FirstName:        u.FirstName,
+		LastName:         u.LastName,
+		Bio:              u.Bio,
+		AvatarURL:        u.AvatarURL,
+		AvatarRenditions: u.AvatarRenditions,
+		IsPrivate:        u.IsPrivate,
+		CreatedAt:        u.CreatedAt,
+	}
+}
+
+func (u User) IsDeactivated() bool {
+	return u.DeactivatedAt != nil
+}
+
+func (u User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u User) Actor() Actor {
+	return Actor{ID: u.ID, Role: u.Role}
 }
 
 type CreateUserReq struct {
+	Username  string `json:"username" validate:"required,username"`
 	FirstName string `json:"first_name" validate:"required,min=2"`
 	LastName  string `json:"last_name" validate:"required,min=2"`
 	Email     string `json:"email" validate:"required,email"`
@@ -32,9 +105,11 @@ type CreateUserReq struct {
 type LoginReq struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=6"`
+	Device   string `json:"device" validate:"omitempty,max=255"`
 }
 
 type UpdateUserReq struct {
+	Username  *string `json:"username" validate:"omitempty,username"`
 	FirstName *string `json:"first_name" validate:"omitempty,min=2"`
 	LastName  *string `json:"last_name" validate:"omitempty,min=2"`
 	Email     *string `json:"email" validate:"omitempty,email"`
@@ -42,6 +117,7 @@ type UpdateUserReq struct {
 	DOB       *string `json:"date_of_birth" validate:"omitempty"`
 	Bio       *string `json:"bio" validate:"omitempty"`
 	AvatarURL *string `json:"avatar_url" validate:"omitempty,url"`
+	IsPrivate *bool   `json:"is_private"`
 }
 
 type DOB time.Time
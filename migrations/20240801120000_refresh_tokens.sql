@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE REFRESH_TOKENS (
+    id UUID PRIMARY KEY default gen_random_uuid(),
+    user_id UUID NOT NULL,
+    family_id UUID NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL default now(),
+    FOREIGN KEY("user_id") REFERENCES USERS("id") ON DELETE CASCADE
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON REFRESH_TOKENS(family_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE REFRESH_TOKENS;
+-- +goose StatementEnd
@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE SESSIONS (
+    id UUID PRIMARY KEY default gen_random_uuid(),
+    user_id UUID NOT NULL,
+    device VARCHAR(255),
+    user_agent TEXT,
+    ip VARCHAR(64),
+    last_seen_at TIMESTAMP NOT NULL default now(),
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL default now(),
+    FOREIGN KEY("user_id") REFERENCES USERS("id") ON DELETE CASCADE
+);
+
+CREATE INDEX sessions_user_id_idx ON SESSIONS(user_id);
+
+INSERT INTO SESSIONS(id, user_id, revoked_at, last_seen_at, created_at)
+SELECT family_id, user_id, MAX(revoked_at), MAX(created_at), MIN(created_at)
+FROM REFRESH_TOKENS
+GROUP BY family_id, user_id;
+
+DROP INDEX refresh_tokens_family_id_idx;
+ALTER TABLE REFRESH_TOKENS RENAME COLUMN family_id TO session_id;
+ALTER TABLE REFRESH_TOKENS DROP COLUMN revoked_at;
+ALTER TABLE REFRESH_TOKENS DROP COLUMN user_id;
+ALTER TABLE REFRESH_TOKENS
+    ADD CONSTRAINT refresh_tokens_session_id_fkey
+    FOREIGN KEY("session_id") REFERENCES SESSIONS("id") ON DELETE CASCADE;
+CREATE INDEX refresh_tokens_session_id_idx ON REFRESH_TOKENS(session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE REFRESH_TOKENS DROP CONSTRAINT refresh_tokens_session_id_fkey;
+DROP INDEX refresh_tokens_session_id_idx;
+ALTER TABLE REFRESH_TOKENS ADD COLUMN user_id UUID;
+ALTER TABLE REFRESH_TOKENS ADD COLUMN revoked_at TIMESTAMP;
+UPDATE REFRESH_TOKENS rt SET user_id = s.user_id, revoked_at = s.revoked_at
+FROM SESSIONS s WHERE s.id = rt.session_id;
+ALTER TABLE REFRESH_TOKENS ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE REFRESH_TOKENS
+    ADD CONSTRAINT refresh_tokens_user_id_fkey
+    FOREIGN KEY("user_id") REFERENCES USERS("id") ON DELETE CASCADE;
+ALTER TABLE REFRESH_TOKENS RENAME COLUMN session_id TO family_id;
+CREATE INDEX refresh_tokens_family_id_idx ON REFRESH_TOKENS(family_id);
+DROP TABLE SESSIONS;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE PASSWORD_RESET_TOKENS (
+    id UUID PRIMARY KEY default gen_random_uuid(),
+    user_id UUID NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL default now(),
+    FOREIGN KEY("user_id") REFERENCES USERS("id") ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE PASSWORD_RESET_TOKENS;
+-- +goose StatementEnd
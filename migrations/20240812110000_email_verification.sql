@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE USERS ADD COLUMN email_verified_at TIMESTAMP;
+ALTER TABLE USERS ADD COLUMN pending_email VARCHAR(255);
+
+-- accounts created before verification existed are considered verified
+UPDATE USERS SET email_verified_at = created_at;
+
+CREATE TABLE EMAIL_VERIFICATION_TOKENS (
+    id UUID PRIMARY KEY default gen_random_uuid(),
+    user_id UUID NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL default now(),
+    FOREIGN KEY("user_id") REFERENCES USERS("id") ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE EMAIL_VERIFICATION_TOKENS;
+ALTER TABLE USERS DROP COLUMN pending_email;
+ALTER TABLE USERS DROP COLUMN email_verified_at;
+-- +goose StatementEnd
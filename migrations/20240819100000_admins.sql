@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE USERS ADD COLUMN is_admin BOOLEAN NOT NULL default false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE USERS DROP COLUMN is_admin;
+-- +goose StatementEnd
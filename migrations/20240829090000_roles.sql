@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE USERS ADD COLUMN role TEXT NOT NULL default 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
+UPDATE USERS SET role = 'admin' WHERE is_admin;
+ALTER TABLE USERS DROP COLUMN is_admin;
+
+CREATE TABLE MODERATION_ACTIONS (
+    id UUID PRIMARY KEY default gen_random_uuid(),
+    moderator_id UUID,
+    action TEXT NOT NULL,
+    target_id UUID NOT NULL,
+    target_user_id UUID,
+    created_at TIMESTAMP NOT NULL default now(),
+    FOREIGN KEY("moderator_id") REFERENCES USERS("id") ON DELETE SET NULL,
+    FOREIGN KEY("target_user_id") REFERENCES USERS("id") ON DELETE SET NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE MODERATION_ACTIONS;
+ALTER TABLE USERS ADD COLUMN is_admin BOOLEAN NOT NULL default false;
+UPDATE USERS SET is_admin = true WHERE role = 'admin';
+ALTER TABLE USERS DROP COLUMN role;
+-- +goose StatementEnd
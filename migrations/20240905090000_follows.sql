@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE FOLLOWS (
+    follower_id UUID NOT NULL,
+    followee_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL default now(),
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id),
+    FOREIGN KEY("follower_id") REFERENCES USERS("id") ON DELETE CASCADE,
+    FOREIGN KEY("followee_id") REFERENCES USERS("id") ON DELETE CASCADE
+);
+CREATE INDEX follows_followee_id_idx ON FOLLOWS (followee_id, created_at DESC);
+CREATE INDEX follows_follower_id_idx ON FOLLOWS (follower_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE FOLLOWS;
+-- +goose StatementEnd
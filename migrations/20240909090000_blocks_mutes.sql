@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE BLOCKS (
+    blocker_id UUID NOT NULL,
+    blocked_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL default now(),
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id),
+    FOREIGN KEY("blocker_id") REFERENCES USERS("id") ON DELETE CASCADE,
+    FOREIGN KEY("blocked_id") REFERENCES USERS("id") ON DELETE CASCADE
+);
+CREATE INDEX blocks_blocked_id_idx ON BLOCKS (blocked_id);
+
+CREATE TABLE MUTES (
+    muter_id UUID NOT NULL,
+    muted_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL default now(),
+    PRIMARY KEY (muter_id, muted_id),
+    CHECK (muter_id <> muted_id),
+    FOREIGN KEY("muter_id") REFERENCES USERS("id") ON DELETE CASCADE,
+    FOREIGN KEY("muted_id") REFERENCES USERS("id") ON DELETE CASCADE
+);
+CREATE INDEX mutes_muted_id_idx ON MUTES (muted_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE MUTES;
+DROP TABLE BLOCKS;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE USERS ADD COLUMN is_private BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE FOLLOW_REQUESTS (
+    requester_id UUID NOT NULL,
+    target_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL default now(),
+    PRIMARY KEY (requester_id, target_id),
+    CHECK (requester_id <> target_id),
+    FOREIGN KEY("requester_id") REFERENCES USERS("id") ON DELETE CASCADE,
+    FOREIGN KEY("target_id") REFERENCES USERS("id") ON DELETE CASCADE
+);
+CREATE INDEX follow_requests_target_id_idx ON FOLLOW_REQUESTS (target_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE FOLLOW_REQUESTS;
+ALTER TABLE USERS DROP COLUMN is_private;
+-- +goose StatementEnd
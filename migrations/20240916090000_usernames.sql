@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE USERS ADD COLUMN username TEXT;
+ALTER TABLE USERS ADD COLUMN username_changed_at TIMESTAMP;
+UPDATE USERS SET username = 'user_' || left(replace(id::text, '-', ''), 12);
+ALTER TABLE USERS ALTER COLUMN username SET NOT NULL;
+CREATE UNIQUE INDEX users_username_idx ON USERS (LOWER(username));
+
+-- old handles keep pointing at their user until they expire
+CREATE TABLE USERNAME_HISTORY (
+    username TEXT NOT NULL,
+    user_id UUID NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    FOREIGN KEY("user_id") REFERENCES USERS("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX username_history_username_idx ON USERNAME_HISTORY (LOWER(username));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE USERNAME_HISTORY;
+DROP INDEX users_username_idx;
+ALTER TABLE USERS DROP COLUMN username_changed_at;
+ALTER TABLE USERS DROP COLUMN username;
+-- +goose StatementEnd
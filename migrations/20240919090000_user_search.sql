@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX users_first_name_trgm_idx ON USERS USING GIN (LOWER(first_name) gin_trgm_ops);
+CREATE INDEX users_last_name_trgm_idx ON USERS USING GIN (LOWER(last_name) gin_trgm_ops);
+CREATE INDEX users_full_name_trgm_idx ON USERS USING GIN (LOWER(first_name || ' ' || last_name) gin_trgm_ops);
+CREATE INDEX users_username_trgm_idx ON USERS USING GIN (LOWER(username) gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX users_username_trgm_idx;
+DROP INDEX users_full_name_trgm_idx;
+DROP INDEX users_last_name_trgm_idx;
+DROP INDEX users_first_name_trgm_idx;
+DROP EXTENSION IF EXISTS pg_trgm;
+-- +goose StatementEnd
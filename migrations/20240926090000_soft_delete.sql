@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE USERS ADD COLUMN deactivated_at TIMESTAMP;
+CREATE INDEX users_deactivated_at_idx ON USERS (deactivated_at) WHERE deactivated_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX users_deactivated_at_idx;
+ALTER TABLE USERS DROP COLUMN deactivated_at;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE POSTS ADD COLUMN visibility TEXT NOT NULL default 'public'
+    CHECK (visibility IN ('public', 'followers', 'mentioned'));
+
+CREATE TABLE POST_MENTIONS (
+    post_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    PRIMARY KEY (post_id, user_id),
+    FOREIGN KEY("post_id") REFERENCES POSTS("id") ON DELETE CASCADE,
+    FOREIGN KEY("user_id") REFERENCES USERS("id") ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE POST_MENTIONS;
+ALTER TABLE POSTS DROP COLUMN visibility;
+-- +goose StatementEnd
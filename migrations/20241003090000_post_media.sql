@@ -0,0 +1,62 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE FILES (
+    id UUID PRIMARY KEY default gen_random_uuid(),
+    user_id UUID NOT NULL,
+    object_key TEXT NOT NULL,
+    url TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL default now(),
+    FOREIGN KEY("user_id") REFERENCES USERS("id") ON DELETE CASCADE
+);
+CREATE INDEX files_user_id_idx ON FILES (user_id, created_at DESC);
+
+CREATE TABLE POST_MEDIA (
+    post_id UUID NOT NULL,
+    file_id UUID NOT NULL,
+    position SMALLINT NOT NULL,
+    alt_text TEXT NOT NULL default '',
+    PRIMARY KEY (post_id, file_id),
+    UNIQUE (post_id, position),
+    FOREIGN KEY("post_id") REFERENCES POSTS("id") ON DELETE CASCADE,
+    FOREIGN KEY("file_id") REFERENCES FILES("id") ON DELETE CASCADE
+);
+CREATE INDEX post_media_file_id_idx ON POST_MEDIA (file_id);
+
+-- the photo of a post becomes its first attachment, uploaded by its author.
+-- Only photos in our media bucket are moved: posts used to take any URL, and
+-- the key taken from a foreign one could name an object of another user.
+-- Foreign photos are dropped with the column. The URL of the bucket comes
+-- from MINIO_SERVER_URL and MINIO_BUCKET_NAME, without them nothing is moved.
+-- +goose ENVSUB ON
+WITH BUCKET AS (
+    SELECT '${MINIO_SERVER_URL}/${MINIO_BUCKET_NAME}/' AS prefix
+    WHERE '${MINIO_SERVER_URL}' != '' AND '${MINIO_BUCKET_NAME}' != ''
+), MOVED AS (
+    SELECT post_id, user_id, photo_url, object_key, gen_random_uuid() AS file_id
+    FROM (
+        SELECT p.id AS post_id, p.user_id, p.photo_url, substr(p.photo_url, length(b.prefix) + 1) AS object_key
+        FROM POSTS p, BUCKET b
+        WHERE starts_with(p.photo_url, b.prefix)
+    ) OURS
+    WHERE object_key != '' AND position('/' in object_key) = 0
+), MOVED_FILES AS (
+    INSERT INTO FILES(id, user_id, object_key, url)
+    SELECT file_id, user_id, object_key, photo_url FROM MOVED
+)
+INSERT INTO POST_MEDIA(post_id, file_id, position)
+SELECT post_id, file_id, 0 FROM MOVED;
+-- +goose ENVSUB OFF
+
+ALTER TABLE POSTS DROP COLUMN photo_url;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE POSTS ADD COLUMN photo_url TEXT;
+UPDATE POSTS p SET photo_url = f.url
+FROM POST_MEDIA m JOIN FILES f ON f.id = m.file_id
+WHERE m.post_id = p.id AND m.position = 0;
+
+DROP TABLE POST_MEDIA;
+DROP TABLE FILES;
+-- +goose StatementEnd
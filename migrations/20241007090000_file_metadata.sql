@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- files moved over from the photo URLs of posts predate the metadata, so
+-- they get placeholders and no checksum
+ALTER TABLE FILES
+    ADD COLUMN content_type TEXT NOT NULL default 'application/octet-stream',
+    ADD COLUMN size BIGINT NOT NULL default 0,
+    ADD COLUMN sha256 TEXT,
+    ADD COLUMN width INTEGER,
+    ADD COLUMN height INTEGER;
+ALTER TABLE FILES
+    ALTER COLUMN content_type DROP DEFAULT,
+    ALTER COLUMN size DROP DEFAULT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE FILES
+    DROP COLUMN content_type,
+    DROP COLUMN size,
+    DROP COLUMN sha256,
+    DROP COLUMN width,
+    DROP COLUMN height;
+-- +goose StatementEnd
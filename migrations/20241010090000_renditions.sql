@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- renditions map their names to the URLs of the scaled down copies of an
+-- image; files uploaded before have none
+ALTER TABLE FILES ADD COLUMN renditions JSONB;
+ALTER TABLE USERS ADD COLUMN avatar_renditions JSONB;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE USERS DROP COLUMN avatar_renditions;
+ALTER TABLE FILES DROP COLUMN renditions;
+-- +goose StatementEnd
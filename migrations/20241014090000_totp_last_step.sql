@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the time step of the last accepted code, so that a code works only once
+ALTER TABLE USER_TOTP ADD COLUMN last_used_step BIGINT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE USER_TOTP DROP COLUMN last_used_step;
+-- +goose StatementEnd
@@ -1,6 +1,9 @@
 package hasher
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -18,3 +21,8 @@ func ComparePw(pw string, hashedPw string) bool {
 	}
 	return true
 }
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
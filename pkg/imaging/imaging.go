@@ -0,0 +1,343 @@
+// Package imaging prepares uploaded images to be served: it strips the
+// metadata they were taken with and makes smaller renditions of them.
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrMalformed         = errors.New("malformed image")
+)
+
+// Orientations of EXIF, telling how the stored pixels have to be turned to
+// be shown upright. Values 5 to 8 swap the width and the height.
+const (
+	OrientationNormal = 1
+	OrientationMax    = 8
+)
+
+// StripMetadata returns the image encoded in data without the metadata
+// cameras and phones store alongside the pixels, such as EXIF with the GPS
+// position, XMP, IPTC and comments. The pixels are left untouched. Format is
+// the name image.DecodeConfig reports, i.e. "jpeg", "png", "webp" or "gif".
+//
+// A JPEG keeps its EXIF orientation in an EXIF segment of its own, so it is
+// still shown upright. GIFs carry no such metadata and are returned as is.
+func StripMetadata(format string, data []byte) ([]byte, error) {
+	switch format {
+	case "jpeg":
+		return stripJPEG(data)
+	case "png":
+		return stripPNG(data)
+	case "webp":
+		return stripWebP(data)
+	case "gif":
+		return data, nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// Orientation returns the EXIF orientation of a JPEG, or OrientationNormal
+// when it has none.
+func Orientation(data []byte) int {
+	orientation := OrientationNormal
+	_, _ = walkJPEG(data, func(marker byte, segment []byte) bool {
+		if marker == 0xE1 {
+			if o, ok := exifOrientation(segment[4:]); ok {
+				orientation = o
+				return false
+			}
+		}
+		return true
+	})
+	return orientation
+}
+
+// Fit scales img down so that neither side is longer than size, keeping its
+// aspect ratio. Images that already fit are copied at their size.
+func Fit(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/w)
+		} else {
+			w, h = max(1, w*size/h), size
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, xdraw.Src, nil)
+	return dst
+}
+
+// Orient turns img upright according to an EXIF orientation. Unknown
+// orientations leave it as it is.
+func Orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= OrientationNormal || orientation > OrientationMax {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // flip horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotate by 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // flip vertically
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate clockwise
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate counterclockwise
+				dx, dy = y, w-1-x
+			}
+			si := img.PixOffset(b.Min.X+x, b.Min.Y+y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], img.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// EncodeJPEG writes img as a JPEG of the given quality. JPEG has no
+// transparency, so transparent pixels are put on a white background.
+func EncodeJPEG(w io.Writer, img *image.RGBA, quality int) error {
+	if !img.Opaque() {
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+		img = flat
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
+
+// walkJPEG calls fn with every marker segment of a JPEG up to the image
+// data, as long as fn returns true. A segment starts with its marker and
+// length. It returns the offset of the image data.
+func walkJPEG(data []byte, fn func(marker byte, segment []byte) bool) (int, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0, ErrMalformed
+	}
+	for i := 2; ; {
+		if i+4 > len(data) || data[i] != 0xFF {
+			return 0, ErrMalformed
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			// fill byte
+			i++
+			continue
+		}
+		if marker == 0xDA {
+			// start of scan, the image data follows
+			return i, nil
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			return 0, ErrMalformed
+		}
+		if !fn(marker, data[i:i+2+n]) {
+			return i, nil
+		}
+		i += 2 + n
+	}
+}
+
+// jpegMetadata reports whether a JPEG marker holds metadata rather than
+// something needed to decode the image. APP0 (JFIF), APP2 (ICC profile) and
+// APP14 (Adobe color transform) are needed.
+func jpegMetadata(marker byte) bool {
+	switch {
+	case marker == 0xE0, marker == 0xE2, marker == 0xEE:
+		return false
+	case marker >= 0xE1 && marker <= 0xEF:
+		return true
+	case marker == 0xFE:
+		// comment
+		return true
+	}
+	return false
+}
+
+func stripJPEG(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	if o := Orientation(data); o != OrientationNormal {
+		out = append(out, orientationSegment(o)...)
+	}
+
+	scan, err := walkJPEG(data, func(marker byte, segment []byte) bool {
+		if !jpegMetadata(marker) {
+			out = append(out, segment...)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the image data cannot hold metadata
+	return append(out, data[scan:]...), nil
+}
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	tiffBig    = []byte("MM\x00\x2A")
+	tiffLittle = []byte("II\x2A\x00")
+)
+
+// exifOrientation reads the orientation tag of the first IFD of an APP1
+// segment.
+func exifOrientation(segment []byte) (int, bool) {
+	tiff, ok := bytes.CutPrefix(segment, exifHeader)
+	if !ok || len(tiff) < 8 {
+		return 0, false
+	}
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(tiff, tiffBig):
+		order = binary.BigEndian
+	case bytes.HasPrefix(tiff, tiffLittle):
+		order = binary.LittleEndian
+	default:
+		return 0, false
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 0, false
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0, false
+		}
+		// a SHORT, stored in the first bytes of the value
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o < OrientationNormal || o > OrientationMax {
+				return 0, false
+			}
+			return o, true
+		}
+	}
+	return 0, false
+}
+
+// orientationSegment returns an APP1 segment with EXIF that holds nothing but
+// the orientation.
+func orientationSegment(orientation int) []byte {
+	var tiff []byte
+	tiff = append(tiff, tiffBig...)
+	tiff = binary.BigEndian.AppendUint32(tiff, 8)
+	// one entry: the orientation, a single SHORT, padded to four bytes
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(orientation))
+	tiff = binary.BigEndian.AppendUint16(tiff, 0)
+	// no next IFD
+	tiff = binary.BigEndian.AppendUint32(tiff, 0)
+
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(2+len(exifHeader)+len(tiff)))
+	segment = append(segment, exifHeader...)
+	return append(segment, tiff...)
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadata holds the types of PNG chunks with metadata.
+var pngMetadata = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, ErrMalformed
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	for i := len(pngSignature); i < len(data); {
+		// length, type, data and CRC
+		if i+12 > len(data) {
+			return nil, ErrMalformed
+		}
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + n
+		if n < 0 || end > len(data) {
+			return nil, ErrMalformed
+		}
+		if !pngMetadata[string(data[i+4:i+8])] {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return out, nil
+}
+
+// Flags of the VP8X chunk of a WebP telling that it has metadata chunks.
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrMalformed
+	}
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	for i := 12; i < len(data); {
+		// type, length and data padded to an even length
+		if i+8 > len(data) {
+			return nil, ErrMalformed
+		}
+		n := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + n + n%2
+		if n < 0 || end > len(data) {
+			return nil, ErrMalformed
+		}
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			start := len(out)
+			out = append(out, data[i:end]...)
+			if n > 0 {
+				out[start+8] &^= webpFlagEXIF | webpFlagXMP
+			}
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
@@ -0,0 +1,190 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// gpsExif is an APP1 segment with an orientation and a GPS position, the
+// kind phones store.
+func gpsExif(orientation int) []byte {
+	var tiff []byte
+	tiff = append(tiff, tiffLittle...)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 8)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 2)
+	// orientation
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, uint16(orientation))
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0)
+	// pointer to the GPS IFD, which is never read here
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x8825)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 4)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 38)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 0)
+	tiff = append(tiff, "GPS 50.4501N 30.5234E"...)
+
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(2+len(exifHeader)+len(tiff)))
+	segment = append(segment, exifHeader...)
+	return append(segment, tiff...)
+}
+
+func newJPEG(t *testing.T, w, h int, segments ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil))
+	data := buf.Bytes()
+
+	out := append([]byte{}, data[:2]...)
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+	return append(out, data[2:]...)
+}
+
+func TestStripJPEG(t *testing.T) {
+	comment := append([]byte{0xFF, 0xFE, 0x00, 0x07}, "hello"...)
+	data := newJPEG(t, 4, 2, gpsExif(6), comment)
+	require.Equal(t, 6, Orientation(data))
+
+	stripped, err := StripMetadata("jpeg", data)
+	require.NoError(t, err)
+	require.NotContains(t, string(stripped), "GPS")
+	require.NotContains(t, string(stripped), "hello")
+	require.Equal(t, 6, Orientation(stripped), "expected the orientation to be kept")
+
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(stripped))
+	require.NoError(t, err, "expected the image to still decode")
+	require.Equal(t, 4, cfg.Width)
+	require.Equal(t, 2, cfg.Height)
+
+	plain := newJPEG(t, 1, 1)
+	stripped, err = StripMetadata("jpeg", plain)
+	require.NoError(t, err)
+	require.Equal(t, plain, stripped, "expected an image without metadata to stay the same")
+	require.Equal(t, OrientationNormal, Orientation(plain))
+}
+
+func TestStripPNG(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 3, 2))))
+	data := buf.Bytes()
+
+	// a tEXt chunk goes right after IHDR, which is 25 bytes long
+	text := []byte("Comment\x00taken at home")
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)))
+	chunk = append(chunk, "tEXt"...)
+	chunk = append(chunk, text...)
+	chunk = append(chunk, 0, 0, 0, 0)
+	at := len(pngSignature) + 25
+	withText := append(append(append([]byte{}, data[:at]...), chunk...), data[at:]...)
+
+	stripped, err := StripMetadata("png", withText)
+	require.NoError(t, err)
+	require.Equal(t, data, stripped)
+
+	_, err = StripMetadata("png", withText[:at+4])
+	require.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestStripWebP(t *testing.T) {
+	chunk := func(typ string, payload []byte) []byte {
+		c := append([]byte(typ), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+		c = append(c, payload...)
+		if len(payload)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+	riff := func(chunks ...[]byte) []byte {
+		body := []byte("WEBP")
+		for _, c := range chunks {
+			body = append(body, c...)
+		}
+		return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+	}
+
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpFlagEXIF | webpFlagXMP
+	bitstream := []byte("not really an image")
+	data := riff(chunk("VP8X", vp8x), chunk("VP8L", bitstream), chunk("EXIF", []byte("GPS")), chunk("XMP ", []byte("<x/>")))
+
+	stripped, err := StripMetadata("webp", data)
+	require.NoError(t, err)
+	require.Equal(t, riff(chunk("VP8X", make([]byte, 10)), chunk("VP8L", bitstream)), stripped)
+}
+
+func TestStripUnsupported(t *testing.T) {
+	_, err := StripMetadata("bmp", []byte("BM"))
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		w, h, size   int
+		wantW, wantH int
+	}{
+		{4000, 3000, 1280, 1280, 960},
+		{3000, 4000, 640, 480, 640},
+		{100, 50, 128, 100, 50},
+		{1000, 1, 10, 10, 1},
+	}
+	for _, tt := range tests {
+		got := Fit(image.NewRGBA(image.Rect(0, 0, tt.w, tt.h)), tt.size)
+		require.Equal(t, tt.wantW, got.Bounds().Dx())
+		require.Equal(t, tt.wantH, got.Bounds().Dy())
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// a 2x1 image, red on the left and blue on the right
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, red)
+	img.Set(1, 0, blue)
+
+	tests := []struct {
+		orientation int
+		w, h        int
+		red         image.Point
+	}{
+		{1, 2, 1, image.Pt(0, 0)},
+		{2, 2, 1, image.Pt(1, 0)},
+		{3, 2, 1, image.Pt(1, 0)},
+		{4, 2, 1, image.Pt(0, 0)},
+		{5, 1, 2, image.Pt(0, 0)},
+		{6, 1, 2, image.Pt(0, 0)},
+		{7, 1, 2, image.Pt(0, 1)},
+		{8, 1, 2, image.Pt(0, 1)},
+	}
+	for _, tt := range tests {
+		got := Orient(img, tt.orientation)
+		require.Equal(t, tt.w, got.Bounds().Dx(), "orientation %d", tt.orientation)
+		require.Equal(t, tt.h, got.Bounds().Dy(), "orientation %d", tt.orientation)
+		require.Equal(t, red, got.RGBAAt(tt.red.X, tt.red.Y), "orientation %d", tt.orientation)
+	}
+}
+
+func TestEncodeJPEGFlattens(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeJPEG(&buf, img, 90))
+	decoded, err := jpeg.Decode(&buf)
+	require.NoError(t, err)
+	r, g, b, _ := decoded.At(4, 4).RGBA()
+	require.Greater(t, r>>8, uint32(250), "expected transparency to become white")
+	require.Greater(t, g>>8, uint32(250))
+	require.Greater(t, b>>8, uint32(250))
+}
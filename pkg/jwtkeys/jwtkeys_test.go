@@ -0,0 +1,110 @@
+package jwtkeys
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func newClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "user",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func parse(t *testing.T, ks *KeySet, token string) error {
+	t.Helper()
+	_, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, ks.Keyfunc)
+	return err
+}
+
+func TestRotation(t *testing.T) {
+	oldKey, err := GenerateEd25519("2024-01")
+	require.NoError(t, err, "failed to generate key")
+	newKey, err := GenerateEd25519("2024-02")
+	require.NoError(t, err, "failed to generate key")
+
+	before, err := New(oldKey.ID, oldKey)
+	require.NoError(t, err, "failed to create key set")
+	oldToken, err := before.Sign(newClaims())
+	require.NoError(t, err, "failed to sign token")
+
+	after, err := New(newKey.ID, newKey, oldKey)
+	require.NoError(t, err, "failed to create key set")
+	newToken, err := after.Sign(newClaims())
+	require.NoError(t, err, "failed to sign token")
+
+	require.NoError(t, parse(t, after, oldToken), "expected token signed by retired key to be valid")
+	require.NoError(t, parse(t, after, newToken), "expected token signed by active key to be valid")
+	require.ErrorIs(t, parse(t, before, newToken), ErrUnknownKey)
+}
+
+func TestLegacyHMAC(t *testing.T) {
+	legacy := NewHMACKey("", []byte("secret"))
+	legacySet, err := New("", legacy)
+	require.NoError(t, err, "failed to create key set")
+	token, err := legacySet.Sign(newClaims())
+	require.NoError(t, err, "failed to sign token")
+
+	key, err := GenerateEd25519("current")
+	require.NoError(t, err, "failed to generate key")
+	ks, err := New(key.ID, key, legacy)
+	require.NoError(t, err, "failed to create key set")
+
+	require.NoError(t, parse(t, ks, token), "expected token without kid to be valid")
+	require.Len(t, ks.JWKS().Keys, 1, "expected hmac key not to be published")
+}
+
+func TestAlgorithmMismatch(t *testing.T) {
+	key, err := GenerateEd25519("key")
+	require.NoError(t, err, "failed to generate key")
+	ks, err := New(key.ID, key)
+	require.NoError(t, err, "failed to create key set")
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims())
+	forged.Header["kid"] = key.ID
+	token, err := forged.SignedString([]byte("whatever"))
+	require.NoError(t, err, "failed to sign token")
+
+	require.Error(t, parse(t, ks, token), "expected algorithm mismatch to be rejected")
+}
+
+func TestLoadDir(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err, "failed to generate rsa key")
+
+	dir := t.TempDir()
+	active := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "active.pem"), active, 0o600))
+
+	retiredPub, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	require.NoError(t, err, "failed to marshal public key")
+	retired := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: retiredPub})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "retired.pem"), retired, 0o600))
+
+	keys, err := LoadDir(dir)
+	require.NoError(t, err, "failed to load keys")
+	require.Len(t, keys, 2)
+
+	_, err = New("retired", keys...)
+	require.ErrorIs(t, err, ErrVerifyOnlyKey)
+
+	ks, err := New("active", keys...)
+	require.NoError(t, err, "failed to create key set")
+
+	jwks := ks.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	require.Equal(t, "active", jwks.Keys[0].Kid)
+	require.Equal(t, "RSA", jwks.Keys[0].Kty)
+	require.Equal(t, "RS256", jwks.Keys[0].Alg)
+	require.Equal(t, "AQAB", jwks.Keys[0].E)
+}
@@ -0,0 +1,106 @@
+package oidc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/escoutdoor/social/pkg/oidc"
+	"github.com/escoutdoor/social/pkg/oidc/oidctest"
+	"github.com/stretchr/testify/require"
+)
+
+const redirectURL = "http://localhost:3000/oauth/callback"
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	ctx := context.Background()
+	iss, err := oidctest.NewIssuer("client")
+	require.NoError(t, err, "failed to start issuer")
+	defer iss.Close()
+
+	iss.SetUser(oidctest.User{Subject: "42", Email: "jane@example.com", EmailVerified: true, GivenName: "Jane"})
+	p := oidc.NewProvider(iss.Config(redirectURL), nil)
+
+	verifier, err := oidc.GenerateVerifier()
+	require.NoError(t, err, "failed to generate verifier")
+
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", verifier)
+	require.NoError(t, err, "failed to build auth url")
+
+	code, state, err := iss.Authorize(authURL)
+	require.NoError(t, err, "failed to authorize")
+	require.Equal(t, "state", state)
+
+	claims, err := p.Exchange(ctx, code, verifier, "nonce")
+	require.NoError(t, err, "failed to exchange code")
+	require.Equal(t, "42", claims.Subject)
+	require.Equal(t, "jane@example.com", claims.Email)
+	require.True(t, claims.EmailVerified)
+	require.Equal(t, "Jane", claims.GivenName)
+
+	_, err = p.Exchange(ctx, code, verifier, "nonce")
+	require.ErrorIs(t, err, oidc.ErrExchangeFailed, "expected code to be single use")
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	ctx := context.Background()
+	iss, err := oidctest.NewIssuer("client")
+	require.NoError(t, err, "failed to start issuer")
+	defer iss.Close()
+
+	iss.SetUser(oidctest.User{Subject: "42"})
+	p := oidc.NewProvider(iss.Config(redirectURL), nil)
+
+	verifier, err := oidc.GenerateVerifier()
+	require.NoError(t, err, "failed to generate verifier")
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", verifier)
+	require.NoError(t, err, "failed to build auth url")
+	code, _, err := iss.Authorize(authURL)
+	require.NoError(t, err, "failed to authorize")
+
+	other, err := oidc.GenerateVerifier()
+	require.NoError(t, err, "failed to generate verifier")
+	_, err = p.Exchange(ctx, code, other, "nonce")
+	require.ErrorIs(t, err, oidc.ErrExchangeFailed)
+}
+
+func TestExchangeRejectsWrongNonce(t *testing.T) {
+	ctx := context.Background()
+	iss, err := oidctest.NewIssuer("client")
+	require.NoError(t, err, "failed to start issuer")
+	defer iss.Close()
+
+	iss.SetUser(oidctest.User{Subject: "42"})
+	p := oidc.NewProvider(iss.Config(redirectURL), nil)
+
+	verifier, err := oidc.GenerateVerifier()
+	require.NoError(t, err, "failed to generate verifier")
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", verifier)
+	require.NoError(t, err, "failed to build auth url")
+	code, _, err := iss.Authorize(authURL)
+	require.NoError(t, err, "failed to authorize")
+
+	_, err = p.Exchange(ctx, code, verifier, "other")
+	require.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+}
+
+func TestAudienceMismatch(t *testing.T) {
+	ctx := context.Background()
+	iss, err := oidctest.NewIssuer("client")
+	require.NoError(t, err, "failed to start issuer")
+	defer iss.Close()
+
+	iss.SetUser(oidctest.User{Subject: "42"})
+	p := oidc.NewProvider(iss.Config(redirectURL), nil)
+
+	verifier, err := oidc.GenerateVerifier()
+	require.NoError(t, err, "failed to generate verifier")
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", verifier)
+	require.NoError(t, err, "failed to build auth url")
+	code, _, err := iss.Authorize(authURL)
+	require.NoError(t, err, "failed to authorize")
+
+	cfg := iss.Config(redirectURL)
+	cfg.ClientID = "someone-else"
+	_, err = oidc.NewProvider(cfg, nil).Exchange(ctx, code, verifier, "nonce")
+	require.Error(t, err, "expected code issued to another client to be rejected")
+}
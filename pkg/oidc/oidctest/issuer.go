@@ -0,0 +1,228 @@
+// Package oidctest runs a minimal OpenID Connect issuer for tests. It
+// approves every authorization request on behalf of the configured user.
+package oidctest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/escoutdoor/social/pkg/jwtkeys"
+	"github.com/escoutdoor/social/pkg/oidc"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidAuthRequest = errors.New("invalid authorization request")
+)
+
+// User is who the issuer signs in.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+type grant struct {
+	user        User
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+type Issuer struct {
+	URL      string
+	ClientID string
+
+	server *httptest.Server
+	keys   *jwtkeys.KeySet
+
+	mu     sync.Mutex
+	user   User
+	grants map[string]grant
+}
+
+func NewIssuer(clientID string) (*Issuer, error) {
+	key, err := jwtkeys.GenerateEd25519("oidctest")
+	if err != nil {
+		return nil, err
+	}
+	keys, err := jwtkeys.New(key.ID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	iss := &Issuer{
+		ClientID: clientID,
+		keys:     keys,
+		grants:   make(map[string]grant),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", iss.handleDiscovery)
+	mux.HandleFunc("GET /jwks", iss.handleJWKS)
+	mux.HandleFunc("GET /authorize", iss.handleAuthorize)
+	mux.HandleFunc("POST /token", iss.handleToken)
+
+	iss.server = httptest.NewServer(mux)
+	iss.URL = iss.server.URL
+	return iss, nil
+}
+
+func (iss *Issuer) Close() {
+	iss.server.Close()
+}
+
+// Config returns a client config pointing at the issuer.
+func (iss *Issuer) Config(redirectURL string) oidc.Config {
+	return oidc.Config{
+		Issuer:      iss.URL,
+		ClientID:    iss.ClientID,
+		RedirectURL: redirectURL,
+		Scopes:      []string{"email", "profile"},
+	}
+}
+
+// SetUser sets who is signed in by the following authorization requests.
+func (iss *Issuer) SetUser(user User) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	iss.user = user
+}
+
+// Authorize follows the authorization URL like a browser would and returns
+// the code and state the provider redirects back with.
+func (iss *Issuer) Authorize(authURL string) (code, state string, err error) {
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		return "", "", ErrInvalidAuthRequest
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", "", err
+	}
+	q := location.Query()
+	return q.Get("code"), q.Get("state"), nil
+}
+
+func (iss *Issuer) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 iss.URL,
+		"authorization_endpoint": iss.URL + "/authorize",
+		"token_endpoint":         iss.URL + "/token",
+		"jwks_uri":               iss.URL + "/jwks",
+	})
+}
+
+func (iss *Issuer) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, iss.keys.JWKS())
+}
+
+func (iss *Issuer) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != iss.ClientID ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, ErrInvalidAuthRequest.Error(), http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("redirect_uri") == "" {
+		http.Error(w, ErrInvalidAuthRequest.Error(), http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+	iss.mu.Lock()
+	iss.grants[code] = grant{
+		user:        iss.user,
+		clientID:    q.Get("client_id"),
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+	}
+	iss.mu.Unlock()
+
+	v := redirectURI.Query()
+	v.Set("code", code)
+	v.Set("state", q.Get("state"))
+	redirectURI.RawQuery = v.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (iss *Issuer) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	iss.mu.Lock()
+	g, ok := iss.grants[code]
+	delete(iss.grants, code)
+	iss.mu.Unlock()
+
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("client_id") != g.clientID ||
+		r.PostForm.Get("redirect_uri") != g.redirectURI ||
+		oidc.S256Challenge(r.PostForm.Get("code_verifier")) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken, err := iss.keys.Sign(&oidc.Claims{
+		Email:         g.user.Email,
+		EmailVerified: g.user.EmailVerified,
+		GivenName:     g.user.GivenName,
+		FamilyName:    g.user.FamilyName,
+		Nonce:         g.nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    iss.URL,
+			Subject:   g.user.Subject,
+			Audience:  jwt.ClaimStrings{g.clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute * 5)),
+		},
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,156 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSecret = errors.New("invalid totp secret")
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type Algorithm string
+
+const (
+	AlgorithmSHA1   Algorithm = "SHA1"
+	AlgorithmSHA256 Algorithm = "SHA256"
+	AlgorithmSHA512 Algorithm = "SHA512"
+)
+
+func (a Algorithm) hash() func() hash.Hash {
+	switch a {
+	case AlgorithmSHA256:
+		return sha256.New
+	case AlgorithmSHA512:
+		return sha512.New
+	default:
+		return sha1.New
+	}
+}
+
+type Opts struct {
+	Digits    int
+	Period    time.Duration
+	Algorithm Algorithm
+	// Skew is the number of periods before and after the current one in
+	// which a code is still accepted.
+	Skew int
+}
+
+// DefaultOpts matches what authenticator apps assume when the otpauth URI
+// does not say otherwise.
+var DefaultOpts = Opts{
+	Digits:    6,
+	Period:    time.Second * 30,
+	Algorithm: AlgorithmSHA1,
+	Skew:      1,
+}
+
+// GenerateSecret returns a random 160-bit secret encoded as unpadded base32.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// DecodeSecret decodes a base32 secret, tolerating lowercase letters, spaces
+// and padding the way users tend to type it.
+func DecodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+// Generate returns the code for time t.
+func Generate(key []byte, t time.Time, opts Opts) string {
+	return hotp(key, counter(t, opts.Period), opts)
+}
+
+// Validate reports whether code is valid for time t within the configured
+// skew.
+func Validate(code string, key []byte, t time.Time, opts Opts) bool {
+	_, ok := ValidateStep(code, key, t, opts)
+	return ok
+}
+
+// ValidateStep is like Validate but also returns the time step the code
+// belongs to, which lets callers refuse a code that was already used.
+func ValidateStep(code string, key []byte, t time.Time, opts Opts) (uint64, bool) {
+	if len(code) != opts.Digits {
+		return 0, false
+	}
+
+	c := counter(t, opts.Period)
+	for i := -opts.Skew; i <= opts.Skew; i++ {
+		if int64(c)+int64(i) < 0 {
+			continue
+		}
+		step := uint64(int64(c) + int64(i))
+		expected := hotp(key, step, opts)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// URI builds an otpauth:// URI understood by authenticator apps.
+func URI(issuer, account, secret string, opts Opts) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", string(opts.Algorithm))
+	v.Set("digits", fmt.Sprint(opts.Digits))
+	v.Set("period", fmt.Sprint(int(opts.Period.Seconds())))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
+
+func counter(t time.Time, period time.Duration) uint64 {
+	return uint64(t.Unix()) / uint64(period.Seconds())
+}
+
+// hotp implements RFC 4226 section 5.3.
+func hotp(key []byte, counter uint64, opts Opts) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(opts.Algorithm.hash(), key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < opts.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", opts.Digits, bin%mod)
+}
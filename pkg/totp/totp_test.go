@@ -0,0 +1,82 @@
+package totp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test vectors from RFC 6238 Appendix B.
+var (
+	seedSHA1   = []byte("12345678901234567890")
+	seedSHA256 = []byte("12345678901234567890123456789012")
+	seedSHA512 = []byte("1234567890123456789012345678901234567890123456789012345678901234")
+)
+
+func TestGenerateRFC6238Vectors(t *testing.T) {
+	tests := []struct {
+		unix      int64
+		algorithm Algorithm
+		seed      []byte
+		code      string
+	}{
+		{59, AlgorithmSHA1, seedSHA1, "94287082"},
+		{59, AlgorithmSHA256, seedSHA256, "46119246"},
+		{59, AlgorithmSHA512, seedSHA512, "90693936"},
+		{1111111109, AlgorithmSHA1, seedSHA1, "07081804"},
+		{1111111109, AlgorithmSHA256, seedSHA256, "68084774"},
+		{1111111109, AlgorithmSHA512, seedSHA512, "25091201"},
+		{1111111111, AlgorithmSHA1, seedSHA1, "14050471"},
+		{1111111111, AlgorithmSHA256, seedSHA256, "67062674"},
+		{1111111111, AlgorithmSHA512, seedSHA512, "99943326"},
+		{1234567890, AlgorithmSHA1, seedSHA1, "89005924"},
+		{1234567890, AlgorithmSHA256, seedSHA256, "91819424"},
+		{1234567890, AlgorithmSHA512, seedSHA512, "93441116"},
+		{2000000000, AlgorithmSHA1, seedSHA1, "69279037"},
+		{2000000000, AlgorithmSHA256, seedSHA256, "90698825"},
+		{2000000000, AlgorithmSHA512, seedSHA512, "38618901"},
+		{20000000000, AlgorithmSHA1, seedSHA1, "65353130"},
+		{20000000000, AlgorithmSHA256, seedSHA256, "77737706"},
+		{20000000000, AlgorithmSHA512, seedSHA512, "47863826"},
+	}
+
+	for _, tt := range tests {
+		opts := Opts{Digits: 8, Period: time.Second * 30, Algorithm: tt.algorithm}
+		code := Generate(tt.seed, time.Unix(tt.unix, 0), opts)
+		require.Equal(t, tt.code, code, "time %d, algorithm %s", tt.unix, tt.algorithm)
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code := Generate(seedSHA1, now, DefaultOpts)
+
+	require.True(t, Validate(code, seedSHA1, now, DefaultOpts), "expected code to be valid now")
+	require.True(t, Validate(code, seedSHA1, now.Add(DefaultOpts.Period), DefaultOpts), "expected code to be valid one period later")
+	require.False(t, Validate(code, seedSHA1, now.Add(DefaultOpts.Period*3), DefaultOpts), "expected code to expire")
+	require.False(t, Validate("12345", seedSHA1, now, DefaultOpts), "expected short code to be rejected")
+}
+
+func TestValidateStep(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code := Generate(seedSHA1, now, DefaultOpts)
+
+	step, ok := ValidateStep(code, seedSHA1, now, DefaultOpts)
+	require.True(t, ok, "expected code to be valid now")
+	later, ok := ValidateStep(code, seedSHA1, now.Add(DefaultOpts.Period), DefaultOpts)
+	require.True(t, ok, "expected code to be valid one period later")
+	require.Equal(t, step, later, "expected the step the code was generated for")
+}
+
+func TestSecretRoundTrip(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err, "failed to generate secret")
+
+	key, err := DecodeSecret(secret)
+	require.NoError(t, err, "failed to decode secret")
+	require.Len(t, key, 20)
+
+	_, err = DecodeSecret("not base32!")
+	require.ErrorIs(t, err, ErrInvalidSecret)
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,13 +14,54 @@ import (
 
 var (
 	ErrInvalidDateFormat = errors.New("invalid date format")
+	ErrInvalidLimit      = errors.New("invalid limit")
+	ErrInvalidUsername   = fmt.Errorf("username must be %d to %d letters, digits or underscores", UsernameMinLength, UsernameMaxLength)
+	ErrReservedUsername  = errors.New("username is reserved")
 )
 
+const (
+	DefaultPageLimit = 20
+	DefaultMaxLimit  = 100
+
+	UsernameMinLength = 3
+	UsernameMaxLength = 30
+)
+
+var usernameRe = regexp.MustCompile(fmt.Sprintf(`^[a-zA-Z0-9_]{%d,%d}$`, UsernameMinLength, UsernameMaxLength))
+
+// reservedUsernames cannot be taken by anyone, either because they would be
+// mistaken for the staff or because they are route names.
+var reservedUsernames = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"anonymous":     true,
+	"api":           true,
+	"auth":          true,
+	"help":          true,
+	"moderator":     true,
+	"null":          true,
+	"root":          true,
+	"settings":      true,
+	"support":       true,
+	"system":        true,
+	"undefined":     true,
+}
+
 type Validator struct {
-	v *validator.Validate
+	v        *validator.Validate
+	maxLimit int
 }
 
-func New() *Validator {
+type Option func(*Validator)
+
+// WithMaxPageLimit sets the largest page size clients may ask for.
+func WithMaxPageLimit(max int) Option {
+	return func(vl *Validator) {
+		vl.maxLimit = max
+	}
+}
+
+func New(opts ...Option) *Validator {
 	validate := validator.New()
 	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
 		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
@@ -27,7 +70,14 @@ func New() *Validator {
 		}
 		return name
 	})
-	return &Validator{v: validate}
+	validate.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return ValidateUsername(fl.Field().String()) == nil
+	})
+	vl := &Validator{v: validate, maxLimit: DefaultMaxLimit}
+	for _, opt := range opts {
+		opt(vl)
+	}
+	return vl
 }
 
 func (vl *Validator) Validate(b interface{}) map[string]string {
@@ -52,6 +102,31 @@ func (vl *Validator) ValidateDate(dobStr string) (time.Time, error) {
 	return dob, nil
 }
 
+// ValidateLimit parses a page size. An empty value gives DefaultPageLimit,
+// capped at the configured maximum.
+func (vl *Validator) ValidateLimit(limitStr string) (int, error) {
+	if limitStr == "" {
+		return min(DefaultPageLimit, vl.maxLimit), nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > vl.maxLimit {
+		return 0, fmt.Errorf("%w: must be a number between 1 and %d", ErrInvalidLimit, vl.maxLimit)
+	}
+	return limit, nil
+}
+
+// ValidateUsername checks the charset and length of a username and that it
+// is not reserved. Usernames are case-insensitive, so is the reserved list.
+func ValidateUsername(username string) error {
+	if !usernameRe.MatchString(username) {
+		return ErrInvalidUsername
+	}
+	if reservedUsernames[strings.ToLower(username)] {
+		return ErrReservedUsername
+	}
+	return nil
+}
+
 func (vl *Validator) getValidationErr(err validator.FieldError) error {
 	var (
 		field = err.Field()
@@ -70,6 +145,10 @@ func (vl *Validator) getValidationErr(err validator.FieldError) error {
 		return fmt.Errorf("field %s must be a valid URL", field)
 	case "uuid":
 		return fmt.Errorf("field %s must be a valid UUID", field)
+	case "oneof":
+		return fmt.Errorf("field %s must be one of: %s", field, strings.ReplaceAll(param, " ", ", "))
+	case "username":
+		return fmt.Errorf("field %s: %w", field, ValidateUsername(err.Value().(string)))
 	default:
 		return fmt.Errorf("field %s is invalid", field)
 	}
@@ -0,0 +1,67 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateLimit(t *testing.T) {
+	vl := New(WithMaxPageLimit(50))
+
+	limit, err := vl.ValidateLimit("")
+	require.NoError(t, err)
+	require.Equal(t, DefaultPageLimit, limit)
+
+	limit, err = vl.ValidateLimit("50")
+	require.NoError(t, err)
+	require.Equal(t, 50, limit)
+
+	for _, v := range []string{"0", "-1", "51", "ten"} {
+		_, err := vl.ValidateLimit(v)
+		require.ErrorIs(t, err, ErrInvalidLimit, v)
+	}
+
+	limit, err = New(WithMaxPageLimit(10)).ValidateLimit("")
+	require.NoError(t, err)
+	require.Equal(t, 10, limit, "expected the default to be capped by the maximum")
+}
+
+func TestValidateUsername(t *testing.T) {
+	for _, v := range []string{"abc", "John_Doe", "user_42", "a23456789012345678901234567890"} {
+		require.NoError(t, ValidateUsername(v), v)
+	}
+	for _, v := range []string{"", "ab", "john doe", "john-doe", "jöhn", "a234567890123456789012345678901"} {
+		require.ErrorIs(t, ValidateUsername(v), ErrInvalidUsername, v)
+	}
+	for _, v := range []string{"admin", "Admin", "ROOT", "support"} {
+		require.ErrorIs(t, ValidateUsername(v), ErrReservedUsername, v)
+	}
+}
+
+func TestValidateUsernameTag(t *testing.T) {
+	type req struct {
+		Username string `json:"username" validate:"required,username"`
+	}
+	vl := New()
+
+	require.Nil(t, vl.Validate(req{Username: "jane_doe"}))
+
+	errs := vl.Validate(req{Username: "root"})
+	require.Contains(t, errs["username"], ErrReservedUsername.Error())
+	errs = vl.Validate(req{Username: "no spaces"})
+	require.Contains(t, errs["username"], "letters, digits or underscores")
+}
+
+func TestValidateOneOfTag(t *testing.T) {
+	type req struct {
+		Visibility string `json:"visibility" validate:"omitempty,oneof=public followers"`
+	}
+	vl := New()
+
+	require.Nil(t, vl.Validate(req{}))
+	require.Nil(t, vl.Validate(req{Visibility: "followers"}))
+
+	errs := vl.Validate(req{Visibility: "friends"})
+	require.Equal(t, "field visibility must be one of: public, followers", errs["visibility"])
+}